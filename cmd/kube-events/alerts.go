@@ -0,0 +1,43 @@
+package main
+
+import (
+	"github.com/containerum/kube-events/pkg/alert"
+	"github.com/containerum/kube-events/pkg/stream"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/urfave/cli.v2"
+)
+
+var (
+	alertConfigFlag = cli.StringFlag{
+		Name:    "alert_config",
+		EnvVars: []string{"ALERT_CONFIG"},
+		Usage:   "Alert rules and notifiers config file (YAML or JSON). Alerting is disabled if not specified.",
+	}
+
+	alertWorkersFlag = cli.IntFlag{
+		Name:    "alert_workers",
+		EnvVars: []string{"ALERT_WORKERS"},
+		Usage:   "Number of concurrently sent alert notifications.",
+		Value:   4,
+	}
+)
+
+// setupAlerts starts alert engine fed by transformed records.
+func setupAlerts(ctx *cli.Context, hub *stream.Hub, stop <-chan struct{}) error {
+	path := ctx.String(alertConfigFlag.Name)
+	if path == "" {
+		log.Info("Alerting is disabled")
+		return nil
+	}
+	cfg, err := alert.LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	engine, err := alert.NewEngine(cfg, ctx.Int(alertWorkersFlag.Name))
+	if err != nil {
+		return err
+	}
+	log.WithField("rules", len(cfg.Rules)).Info("Alerting is enabled")
+	go engine.Run(hub, stream.Filter{}, stop)
+	return nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/httpapi"
+	"github.com/containerum/kube-events/pkg/storage"
+	"github.com/containerum/kube-events/pkg/storage/mongodb"
+	"github.com/containerum/kube-events/pkg/stream"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/urfave/cli.v2"
+)
+
+var (
+	apiListenFlag = cli.StringFlag{
+		Name:    "api_listen",
+		EnvVars: []string{"API_LISTEN"},
+		Usage: "HTTP API listen address. API is disabled if empty. " +
+			"Set --api_token if API is reachable from other pods, API exposes resources of all namespaces.",
+		Value: ":8080",
+	}
+
+	apiTokensFlag = cli.StringSliceFlag{
+		Name:    "api_token",
+		EnvVars: []string{"API_TOKENS"},
+		Usage: "Bearer token accepted by query, stream, subscriptions, admin and gRPC APIs. " +
+			"APIs are available without token if no tokens specified, except subscriptions API which is disabled.",
+	}
+
+	streamHistoryFlag = cli.IntFlag{
+		Name:    "stream_history",
+		EnvVars: []string{"STREAM_HISTORY"},
+		Usage:   "Number of last records kept in memory for resuming streaming subscriptions.",
+		Value:   1000,
+	}
+
+	streamSubscriberBufferFlag = cli.IntFlag{
+		Name:    "stream_subscriber_buffer",
+		EnvVars: []string{"STREAM_SUBSCRIBER_BUFFER"},
+		Usage:   "Number of records queued for streaming subscriber before it will be disconnected as too slow.",
+		Value:   256,
+	}
+
+	ingestTokensFlag = cli.StringSliceFlag{
+		Name:    "ingest_token",
+		EnvVars: []string{"INGEST_TOKENS"},
+		Usage:   "Bearer token accepted by events ingestion API. Ingestion is disabled if no tokens specified.",
+	}
+
+	ingestTimeoutFlag = cli.DurationFlag{
+		Name:    "ingest_timeout",
+		EnvVars: []string{"INGEST_TIMEOUT"},
+		Usage:   "Maximum time of waiting for record buffer in events ingestion API.",
+		Value:   5 * time.Second,
+	}
+
+	streamKeepAliveFlag = cli.DurationFlag{
+		Name:    "stream_keepalive",
+		EnvVars: []string{"STREAM_KEEPALIVE"},
+		Usage:   "Period of keep-alive messages in streaming subscriptions.",
+		Value:   30 * time.Second,
+	}
+)
+
+const (
+	apiReadHeaderTimeout = 10 * time.Second
+	// apiReadTimeout limits reading of request body (i.e. ingested events)
+	apiReadTimeout = time.Minute
+	apiIdleTimeout = 2 * time.Minute
+	// write timeout is not set because streaming responses are not limited, websocket frames have own timeout
+)
+
+type apiServer struct {
+	mux    *http.ServeMux
+	srv    *http.Server
+	tokens []string
+}
+
+// handle registers handler which requires API token.
+func (s *apiServer) handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, httpapi.RequireToken(s.tokens, handler))
+}
+
+func setupStreamHub(ctx *cli.Context) *stream.Hub {
+	return stream.NewHub(stream.HubConfig{
+		History:          ctx.Int(streamHistoryFlag.Name),
+		SubscriberBuffer: ctx.Int(streamSubscriberBufferFlag.Name),
+	})
+}
+
+func setupAPIServer(ctx *cli.Context, querier storage.EventQuerier, hub *stream.Hub) *apiServer {
+	mux := http.NewServeMux()
+	s := &apiServer{
+		mux: mux,
+		srv: &http.Server{
+			Addr:              ctx.String(apiListenFlag.Name),
+			Handler:           mux,
+			ReadHeaderTimeout: apiReadHeaderTimeout,
+			ReadTimeout:       apiReadTimeout,
+			IdleTimeout:       apiIdleTimeout,
+		},
+		tokens: ctx.StringSlice(apiTokensFlag.Name),
+	}
+	if host, _, err := net.SplitHostPort(s.srv.Addr); err == nil && len(s.tokens) == 0 &&
+		host != "127.0.0.1" && host != "localhost" && host != "::1" {
+		log.WithField("addr", s.srv.Addr).Warn("HTTP API is exposed without token")
+	}
+	if querier != nil {
+		s.handle("/events", httpapi.NewQueryHandler(querier))
+	}
+	s.handle("/events/stream", httpapi.NewStreamHandler(httpapi.StreamHandlerConfig{
+		Hub:         hub,
+		Querier:     querier,
+		Collections: mongodb.Collections,
+		KeepAlive:   ctx.Duration(streamKeepAliveFlag.Name),
+	}))
+	return s
+}
+
+// HandleIngest enables events ingestion API if tokens are specified.
+func (s *apiServer) HandleIngest(ctx *cli.Context, collectors map[string]chan<- kubeClientModel.Event) {
+	tokens := ctx.StringSlice(ingestTokensFlag.Name)
+	if len(tokens) == 0 {
+		log.Info("Ingestion API is disabled")
+		return
+	}
+	s.mux.Handle("/events/ingest", httpapi.NewIngestHandler(httpapi.IngestHandlerConfig{
+		Tokens:     tokens,
+		Route:      RouteIngestedEvent,
+		Collectors: collectors,
+		Timeout:    ctx.Duration(ingestTimeoutFlag.Name),
+	}))
+}
+
+func (s *apiServer) Run() {
+	if s.srv.Addr == "" {
+		return
+	}
+	log.WithField("addr", s.srv.Addr).Info("Starting HTTP API")
+	if err := s.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.WithError(err).Error("HTTP API failed")
+	}
+}
+
+func (s *apiServer) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.srv.Shutdown(ctx); err != nil {
+		log.WithError(err).Error("HTTP API shutdown failed")
+	}
+}
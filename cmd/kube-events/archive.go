@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/containerum/kube-events/pkg/archive"
+	"github.com/containerum/kube-events/pkg/storage/mongodb"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/urfave/cli.v2"
+)
+
+// Archive targets
+const (
+	archiveTargetDir = "dir"
+	archiveTargetS3  = "s3"
+)
+
+var (
+	archiveTargetFlag = cli.StringFlag{
+		Name:    "archive_target",
+		EnvVars: []string{"ARCHIVE_TARGET"},
+		Usage: "Archive records nearing expiration to \"" + archiveTargetDir + "\" or \"" + archiveTargetS3 + "\". " +
+			"Records are not archived if empty.",
+	}
+
+	archiveDirFlag = cli.StringFlag{
+		Name:    "archive_dir",
+		EnvVars: []string{"ARCHIVE_DIR"},
+		Usage:   "Archive directory.",
+		Value:   "/var/lib/kube-events/archive",
+	}
+
+	archiveS3EndpointFlag = cli.StringFlag{
+		Name:    "archive_s3_endpoint",
+		EnvVars: []string{"ARCHIVE_S3_ENDPOINT"},
+		Usage:   "S3-compatible service URL, i.e. \"https://s3.eu-west-1.amazonaws.com\" or \"http://minio:9000\".",
+	}
+
+	archiveS3BucketFlag = cli.StringFlag{
+		Name:    "archive_s3_bucket",
+		EnvVars: []string{"ARCHIVE_S3_BUCKET"},
+		Usage:   "Archive S3 bucket.",
+	}
+
+	archiveS3RegionFlag = cli.StringFlag{
+		Name:    "archive_s3_region",
+		EnvVars: []string{"ARCHIVE_S3_REGION"},
+		Usage:   "Archive S3 region.",
+		Value:   "us-east-1",
+	}
+
+	archiveS3AccessKeyFlag = cli.StringFlag{
+		Name:    "archive_s3_access_key",
+		EnvVars: []string{"ARCHIVE_S3_ACCESS_KEY"},
+		Usage:   "Archive S3 access key.",
+	}
+
+	archiveS3SecretKeyFlag = cli.StringFlag{
+		Name:    "archive_s3_secret_key",
+		EnvVars: []string{"ARCHIVE_S3_SECRET_KEY"},
+		Usage:   "Archive S3 secret key.",
+	}
+
+	archiveS3PrefixFlag = cli.StringFlag{
+		Name:    "archive_s3_prefix",
+		EnvVars: []string{"ARCHIVE_S3_PREFIX"},
+		Usage:   "Prefix of archive S3 object keys, i.e. \"prod/\".",
+	}
+
+	archiveLeadTimeFlag = cli.DurationFlag{
+		Name:    "archive_lead_time",
+		EnvVars: []string{"ARCHIVE_LEAD_TIME"},
+		Usage:   "Time before expiration when records are archived. It must be shorter than shortest retention.",
+		Value:   48 * time.Hour,
+	}
+
+	archivePeriodFlag = cli.DurationFlag{
+		Name:    "archive_period",
+		EnvVars: []string{"ARCHIVE_PERIOD"},
+		Usage:   "Period of archiving records.",
+		Value:   time.Hour,
+	}
+
+	restoreCollectionFlag = cli.StringFlag{
+		Name:  "collection",
+		Usage: "Archived collection.",
+		Value: mongodb.EventsCollection,
+	}
+
+	restoreFromFlag = cli.StringFlag{
+		Name:  "from",
+		Usage: "Restore records added since time (RFC 3339).",
+	}
+
+	restoreToFlag = cli.StringFlag{
+		Name:  "to",
+		Usage: "Restore records added before time (RFC 3339).",
+	}
+
+	restoreTargetCollectionFlag = cli.StringFlag{
+		Name:  "target_collection",
+		Usage: "Collection records are restored to. Records are restored to archived collection if not specified.",
+	}
+)
+
+var archiveCommand = cli.Command{
+	Name:  "archive",
+	Usage: "Manage archived records.",
+	Subcommands: []*cli.Command{
+		{
+			Name:   "status",
+			Usage:  "Print archived time ranges.",
+			Action: archiveStatusAction,
+		},
+		{
+			Name:  "restore",
+			Usage: "Restore archived records to MongoDB. Restored records get current time of adding.",
+			Flags: []cli.Flag{
+				&restoreCollectionFlag,
+				&restoreFromFlag,
+				&restoreToFlag,
+				&restoreTargetCollectionFlag,
+			},
+			Action: archiveRestoreAction,
+		},
+	},
+}
+
+// archiveTarget returns selected archive target or nil if archiving is disabled.
+func archiveTarget(ctx *cli.Context) (archive.Target, error) {
+	switch target := ctx.String(archiveTargetFlag.Name); target {
+	case "":
+		return nil, nil
+	case archiveTargetDir:
+		return archive.DirTarget{Dir: ctx.String(archiveDirFlag.Name)}, nil
+	case archiveTargetS3:
+		if ctx.String(archiveS3EndpointFlag.Name) == "" || ctx.String(archiveS3BucketFlag.Name) == "" {
+			return nil, fmt.Errorf("archive S3 endpoint and bucket are required")
+		}
+		return archive.S3Target{
+			Endpoint:  ctx.String(archiveS3EndpointFlag.Name),
+			Bucket:    ctx.String(archiveS3BucketFlag.Name),
+			Region:    ctx.String(archiveS3RegionFlag.Name),
+			AccessKey: ctx.String(archiveS3AccessKeyFlag.Name),
+			SecretKey: ctx.String(archiveS3SecretKeyFlag.Name),
+			Prefix:    ctx.String(archiveS3PrefixFlag.Name),
+			Client:    &http.Client{Timeout: 5 * time.Minute},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown archive target %q", target)
+	}
+}
+
+func setupArchiver(ctx *cli.Context, mongoStorage *mongodb.Storage, stop <-chan struct{}) error {
+	target, err := archiveTarget(ctx)
+	if err != nil || target == nil {
+		return err
+	}
+	archiver, err := archive.NewArchiver(archive.Config{
+		Source:      mongoStorage,
+		Target:      target,
+		Collections: mongodb.Collections,
+		LeadTime:    ctx.Duration(archiveLeadTimeFlag.Name),
+		Period:      ctx.Duration(archivePeriodFlag.Name),
+	})
+	if err != nil {
+		return err
+	}
+	go archiver.Run(stop)
+	log.WithField("target", ctx.String(archiveTargetFlag.Name)).Info("Archiving is enabled")
+	return nil
+}
+
+func requiredArchiveTarget(ctx *cli.Context) (archive.Target, error) {
+	target, err := archiveTarget(ctx)
+	if err == nil && target == nil {
+		err = fmt.Errorf("archive target is not specified")
+	}
+	return target, err
+}
+
+func archiveStatusAction(ctx *cli.Context) error {
+	setupLogs(ctx)
+	target, err := requiredArchiveTarget(ctx)
+	if err != nil {
+		return err
+	}
+	for _, collection := range mongodb.Collections {
+		manifest, err := archive.ReadManifest(target, collection)
+		if err != nil {
+			return err
+		}
+		if len(manifest.Files) == 0 {
+			continue
+		}
+		records := 0
+		for _, file := range manifest.Files {
+			records += file.Records
+		}
+		fmt.Printf("%s: %d files, %d records, %s - %s\n", collection, len(manifest.Files), records,
+			manifest.Files[0].From.Format(time.RFC3339), manifest.ArchivedUntil.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func parseTimeFlag(ctx *cli.Context, name string) (time.Time, error) {
+	value := ctx.String(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s: %v", name, err)
+	}
+	return t, nil
+}
+
+func archiveRestoreAction(ctx *cli.Context) error {
+	target, err := requiredArchiveTarget(ctx)
+	if err != nil {
+		return err
+	}
+	from, err := parseTimeFlag(ctx, restoreFromFlag.Name)
+	if err != nil {
+		return err
+	}
+	to, err := parseTimeFlag(ctx, restoreToFlag.Name)
+	if err != nil {
+		return err
+	}
+	mongoStorage, err := dialMongo(ctx)
+	if err != nil {
+		return err
+	}
+	defer mongoStorage.Close()
+
+	restored, err := archive.Restore(target, mongoStorage, archive.RestoreOptions{
+		Collection:       ctx.String(restoreCollectionFlag.Name),
+		From:             from,
+		To:               to,
+		TargetCollection: ctx.String(restoreTargetCollectionFlag.Name),
+	})
+	fmt.Printf("Restored %d records\n", restored)
+	return err
+}
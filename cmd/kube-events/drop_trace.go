@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/containerum/kube-events/pkg/httpapi"
+	"github.com/containerum/kube-events/pkg/transform"
+	"gopkg.in/urfave/cli.v2"
+)
+
+var (
+	dropTraceSizeFlag = cli.IntFlag{
+		Name:    "drop_trace_size",
+		EnvVars: []string{"DROP_TRACE_SIZE"},
+		Usage:   "Number of last traces of events dropped by filters kept in memory for admin API.",
+		Value:   1000,
+	}
+
+	dropTraceLogSampleFlag = cli.IntFlag{
+		Name:    "drop_trace_log_sample",
+		EnvVars: []string{"DROP_TRACE_LOG_SAMPLE"},
+		Usage:   "Log every n-th trace of dropped event with debug level. Traces are not logged if 0.",
+		Value:   100,
+	}
+)
+
+func setupDropTracer(ctx *cli.Context) (*transform.DropTracer, error) {
+	size := ctx.Int(dropTraceSizeFlag.Name)
+	if size < 0 {
+		return nil, fmt.Errorf("invalid %s %d: must not be negative", dropTraceSizeFlag.Name, size)
+	}
+	return transform.NewDropTracer(transform.DropTracerConfig{
+		Size:      size,
+		LogSample: ctx.Int(dropTraceLogSampleFlag.Name),
+	}), nil
+}
+
+// HandleDropTraces enables admin API of events dropped by filters.
+func (s *apiServer) HandleDropTraces(tracer *transform.DropTracer) {
+	s.handle("/admin/drops", httpapi.NewDropTraceHandler(tracer.Traces))
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/storage"
+	"github.com/containerum/kube-events/pkg/transform"
+	"gopkg.in/urfave/cli.v2"
+)
+
+// Dry run output formats
+const (
+	dryRunFormatJSON  = "json"
+	dryRunFormatTable = "table"
+)
+
+var (
+	dryRunFlag = cli.BoolFlag{
+		Name:    "dry_run",
+		Aliases: []string{"dry-run"},
+		EnvVars: []string{"DRY_RUN"},
+		Usage: "Print records to stdout instead of writing them to storages. Storages are not connected, " +
+			"alerts, subscriptions and archiving are disabled.",
+	}
+
+	dryRunFormatFlag = cli.StringFlag{
+		Name:    "dry_run_format",
+		EnvVars: []string{"DRY_RUN_FORMAT"},
+		Usage:   "Format of printed records: \"" + dryRunFormatJSON + "\" or \"" + dryRunFormatTable + "\".",
+		Value:   dryRunFormatJSON,
+	}
+
+	dryRunShowDroppedFlag = cli.BoolFlag{
+		Name:    "dry_run_show_dropped",
+		EnvVars: []string{"DRY_RUN_SHOW_DROPPED"},
+		Usage:   "Also print watch events dropped by filters with name of predicate which dropped them.",
+	}
+)
+
+// dryRunPrinter prints records written to it and dropped watch events.
+type dryRunPrinter struct {
+	mu     sync.Mutex
+	out    io.Writer
+	format string
+	header bool
+}
+
+func newDryRunPrinter(ctx *cli.Context) (*dryRunPrinter, error) {
+	switch format := ctx.String(dryRunFormatFlag.Name); format {
+	case dryRunFormatJSON, dryRunFormatTable:
+		return &dryRunPrinter{out: os.Stdout, format: format}, nil
+	default:
+		return nil, fmt.Errorf("unknown dry run format %q", format)
+	}
+}
+
+func (p *dryRunPrinter) BulkInsert(records []kubeClientModel.Event, collection string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.format == dryRunFormatJSON {
+		encoder := json.NewEncoder(p.out)
+		for _, record := range records {
+			if err := encoder.Encode(newExportedRecord(record, collection)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, record := range records {
+		p.printRow(collection, string(record.Kind), record.Name, string(record.ResourceType),
+			resourcePath(record.ResourceNamespace, record.ResourceName), record.Message)
+	}
+	return nil
+}
+
+// OnDrop prints trace of event dropped by filter.
+func (p *dryRunPrinter) OnDrop(trace transform.DropTrace) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.format == dryRunFormatJSON {
+		json.NewEncoder(p.out).Encode(struct {
+			Dropped bool `json:"dropped"`
+			transform.DropTrace
+		}{true, trace})
+		return
+	}
+	p.printRow("(dropped)", string(trace.Verb), "", strings.ToLower(trace.Resource),
+		resourcePath(trace.Namespace, trace.Name), trace.Predicate+": "+trace.Reason)
+}
+
+// printRow prints table row with fixed column widths, so rows of different batches are aligned.
+// Header is printed before first row.
+func (p *dryRunPrinter) printRow(collection, kind, name, resource, path, message string) {
+	const row = "%-16s %-10s %-28s %-24s %-40s %s"
+	if !p.header {
+		fmt.Fprintf(p.out, row+"\n", "COLLECTION", "KIND", "NAME", "RESOURCE", "NAMESPACE/NAME", "MESSAGE")
+		p.header = true
+	}
+	fmt.Fprintln(p.out, strings.TrimRight(fmt.Sprintf(row, collection, kind, name, resource, path, message), " "))
+}
+
+func resourcePath(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+// setupDryRun returns storage which prints records.
+func setupDryRun(printer *dryRunPrinter) *storage.FanOut {
+	return storage.NewFanOut([]storage.FanOutSink{{
+		Name:    "dry_run",
+		Storage: printer,
+		Primary: true,
+	}})
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"regexp"
 
 	apiCore "k8s.io/api/core/v1"
@@ -123,17 +124,18 @@ var eventsWhitelist = wlblReasonsMessages{
 	kubeletEvents.NodeSelectorMismatching: nil,
 }
 
-func (errs wlblReasonsMessages) check(event *apiCore.Event) bool {
+// check returns false and reason if event reason is not whitelisted or message is blacklisted.
+func (errs wlblReasonsMessages) check(event *apiCore.Event) (bool, string) {
 	bl, inWl := errs[event.Reason]
 	if !inWl {
-		return false
+		return false, fmt.Sprintf("reason %q is not whitelisted", event.Reason)
 	}
 
 	for _, blEntry := range bl {
 		if match, _ := regexp.Match(blEntry, []byte(event.Message)); match {
-			return false
+			return false, fmt.Sprintf("message of reason %q matches blacklisted %q", event.Reason, blEntry)
 		}
 	}
 
-	return true
+	return true, ""
 }
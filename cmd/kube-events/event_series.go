@@ -0,0 +1,118 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/containerum/kube-events/pkg/eventsv1"
+	"github.com/containerum/kube-events/pkg/storage/mongodb"
+	apiCore "k8s.io/api/core/v1"
+	apiEvents "k8s.io/api/events/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Vendored client-go 9.0 (Kubernetes 1.12) provides events.k8s.io/v1beta1 only, events.k8s.io/v1 types are in pkg/eventsv1.
+const (
+	eventsAPICore          = "core/v1"
+	eventsAPIEventsV1beta1 = "events.k8s.io/v1beta1"
+	eventsAPIEventsV1      = "events.k8s.io/v1"
+)
+
+// Event details keys filled from event series. Mongo stores them in typed fields.
+const (
+	detailsCount     = mongodb.DetailsCount
+	detailsFirstSeen = mongodb.DetailsFirstSeen
+	detailsLastSeen  = mongodb.DetailsLastSeen
+)
+
+// kubeEventFromObject returns core/v1 representation of kubernetes Event.
+// events.k8s.io Events are converted to core/v1 Events so filters and transform rules can handle both APIs.
+func kubeEventFromObject(object runtime.Object) (*apiCore.Event, bool) {
+	switch event := object.(type) {
+	case *apiCore.Event:
+		return event, true
+	case *apiEvents.Event:
+		ret := &apiCore.Event{
+			ObjectMeta:          event.ObjectMeta,
+			InvolvedObject:      event.Regarding,
+			Reason:              event.Reason,
+			Message:             event.Note,
+			Source:              event.DeprecatedSource,
+			FirstTimestamp:      event.DeprecatedFirstTimestamp,
+			LastTimestamp:       event.DeprecatedLastTimestamp,
+			Count:               event.DeprecatedCount,
+			Type:                event.Type,
+			EventTime:           event.EventTime,
+			Action:              event.Action,
+			Related:             event.Related,
+			ReportingController: event.ReportingController,
+			ReportingInstance:   event.ReportingInstance,
+		}
+		if event.Series != nil {
+			ret.Series = &apiCore.EventSeries{
+				Count:            event.Series.Count,
+				LastObservedTime: event.Series.LastObservedTime,
+				State:            apiCore.EventSeriesState(event.Series.State),
+			}
+		}
+		return ret, true
+	case *eventsv1.Event:
+		ret := &apiCore.Event{
+			ObjectMeta:          event.ObjectMeta,
+			InvolvedObject:      event.Regarding,
+			Reason:              event.Reason,
+			Message:             event.Note,
+			Source:              event.DeprecatedSource,
+			FirstTimestamp:      event.DeprecatedFirstTimestamp,
+			LastTimestamp:       event.DeprecatedLastTimestamp,
+			Count:               event.DeprecatedCount,
+			Type:                event.Type,
+			EventTime:           event.EventTime,
+			Action:              event.Action,
+			Related:             event.Related,
+			ReportingController: event.ReportingController,
+			ReportingInstance:   event.ReportingInstance,
+		}
+		if event.Series != nil {
+			ret.Series = &apiCore.EventSeries{
+				Count:            event.Series.Count,
+				LastObservedTime: event.Series.LastObservedTime,
+			}
+		}
+		return ret, true
+	default:
+		return nil, false
+	}
+}
+
+// eventSeries returns occurrence count, first-seen and last-seen times of event.
+// Series has priority over deprecated Count and timestamps.
+func eventSeries(event *apiCore.Event) (count int32, firstSeen, lastSeen time.Time) {
+	count = event.Count
+	firstSeen = event.FirstTimestamp.Time
+	lastSeen = event.LastTimestamp.Time
+
+	if firstSeen.IsZero() {
+		firstSeen = event.EventTime.Time
+	}
+	if event.Series != nil {
+		count = event.Series.Count
+		if !event.Series.LastObservedTime.IsZero() {
+			lastSeen = event.Series.LastObservedTime.Time
+		}
+	}
+	if lastSeen.IsZero() {
+		lastSeen = firstSeen
+	}
+	if count < 1 {
+		count = 1
+	}
+	return count, firstSeen, lastSeen
+}
+
+func eventSeriesDetails(event *apiCore.Event, details map[string]string) {
+	count, firstSeen, lastSeen := eventSeries(event)
+	details[detailsCount] = strconv.Itoa(int(count))
+	details[detailsFirstSeen] = firstSeen.Format(time.RFC3339)
+	details[detailsLastSeen] = lastSeen.Format(time.RFC3339)
+}
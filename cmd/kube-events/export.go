@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/archive"
+	"github.com/containerum/kube-events/pkg/storage"
+	"github.com/containerum/kube-events/pkg/storage/mongodb"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/urfave/cli.v2"
+)
+
+// exportedRecord is a line of export file. Archived records have same format without collection.
+type exportedRecord struct {
+	Collection string `json:"collection,omitempty"`
+	archive.Line
+}
+
+func newExportedRecord(record kubeClientModel.Event, collection string) exportedRecord {
+	return exportedRecord{Collection: collection, Line: archive.Line{DateAdded: record.DateAdded, Event: record}}
+}
+
+var (
+	exportCollectionsFlag = cli.StringSliceFlag{
+		Name:  "collection",
+		Usage: "Exported collection. All collections are exported if not specified.",
+	}
+
+	exportNamespaceFlag = cli.StringFlag{
+		Name:  "namespace",
+		Usage: "Export records of resources in namespace.",
+	}
+
+	exportKindsFlag = cli.StringSliceFlag{
+		Name:  "kind",
+		Usage: "Export records of event kind: \"error\", \"warning\" or \"info\".",
+	}
+
+	exportSinceFlag = cli.StringFlag{
+		Name:  "since",
+		Usage: "Export records added since time (RFC 3339).",
+	}
+
+	exportUntilFlag = cli.StringFlag{
+		Name:  "until",
+		Usage: "Export records added before time (RFC 3339).",
+	}
+
+	exportOutputFlag = cli.StringFlag{
+		Name:  "output",
+		Usage: "Output file, \"-\" for stdout. Output is compressed with gzip if file name ends with \".gz\".",
+		Value: "-",
+	}
+
+	importInputFlag = cli.StringFlag{
+		Name:  "input",
+		Usage: "Input file, \"-\" for stdin. Gzipped input is detected automatically.",
+		Value: "-",
+	}
+
+	importCollectionFlag = cli.StringFlag{
+		Name:  "collection",
+		Usage: "Collection of records without collection, i.e. from archive files.",
+	}
+
+	importBatchSizeFlag = cli.IntFlag{
+		Name:  "batch_size",
+		Usage: "Number of records written to storage at once.",
+		Value: 500,
+	}
+
+	importAllowDuplicatesFlag = cli.BoolFlag{
+		Name: "allow_duplicates",
+		Usage: "Also import to storages which can't find stored records (all except MongoDB and Elasticsearch). " +
+			"Records imported to them again are duplicated.",
+	}
+)
+
+// deduplicatingStorages find records already written by their content, so records imported again are not duplicated.
+var deduplicatingStorages = []string{storageMongo, storageElasticsearch}
+
+var exportCommand = cli.Command{
+	Name:  "export",
+	Usage: "Export records from MongoDB as NDJSON.",
+	Flags: []cli.Flag{
+		&exportCollectionsFlag,
+		&exportNamespaceFlag,
+		&exportKindsFlag,
+		&exportSinceFlag,
+		&exportUntilFlag,
+		&exportOutputFlag,
+	},
+	Action: exportAction,
+}
+
+var importCommand = cli.Command{
+	Name: "import",
+	Usage: "Import NDJSON records to selected storages. Records already present in storage are not duplicated, " +
+		"so only MongoDB and Elasticsearch are allowed unless --" + importAllowDuplicatesFlag.Name + " is set.",
+	Flags: []cli.Flag{
+		&importInputFlag,
+		&importCollectionFlag,
+		&importBatchSizeFlag,
+		&importAllowDuplicatesFlag,
+	},
+	Action: importAction,
+}
+
+func exportAction(ctx *cli.Context) error {
+	collections := ctx.StringSlice(exportCollectionsFlag.Name)
+	if len(collections) == 0 {
+		collections = mongodb.Collections
+	}
+	var kinds []kubeClientModel.EventKind
+	for _, kind := range ctx.StringSlice(exportKindsFlag.Name) {
+		switch kind := kubeClientModel.EventKind(kind); kind {
+		case kubeClientModel.EventError, kubeClientModel.EventWarning, kubeClientModel.EventInfo:
+			kinds = append(kinds, kind)
+		default:
+			return fmt.Errorf("unknown event kind %q", kind)
+		}
+	}
+	since, err := parseTimeFlag(ctx, exportSinceFlag.Name)
+	if err != nil {
+		return err
+	}
+	until, err := parseTimeFlag(ctx, exportUntilFlag.Name)
+	if err != nil {
+		return err
+	}
+
+	mongoStorage, err := dialMongo(ctx)
+	if err != nil {
+		return err
+	}
+	defer mongoStorage.Close()
+
+	var out io.Writer = os.Stdout
+	output := ctx.String(exportOutputFlag.Name)
+	if output != "-" {
+		file, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	buf := bufio.NewWriter(out)
+	out = buf
+	var gz *gzip.Writer
+	if strings.HasSuffix(output, ".gz") {
+		gz = gzip.NewWriter(buf)
+		out = gz
+	}
+
+	encoder := json.NewEncoder(out)
+	exported := 0
+	for _, collection := range collections {
+		err := mongoStorage.IterateRecords(storage.EventQuery{
+			Collection:        collection,
+			ResourceNamespace: ctx.String(exportNamespaceFlag.Name),
+			Kinds:             kinds,
+			Since:             since,
+			Until:             until,
+		}, func(record kubeClientModel.Event) error {
+			exported++
+			return encoder.Encode(newExportedRecord(record, collection))
+		})
+		if err != nil {
+			return fmt.Errorf("unable to export %s: %v", collection, err)
+		}
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return err
+		}
+	}
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	log.WithField("records", exported).Info("Records exported")
+	return nil
+}
+
+// openInput opens file or stdin and decompresses gzipped input.
+func openInput(name string) (io.Reader, func() error, error) {
+	var in io.ReadCloser = os.Stdin
+	if name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		in = file
+	}
+	buf := bufio.NewReader(in)
+	if magic, err := buf.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buf)
+		if err != nil {
+			in.Close()
+			return nil, nil, err
+		}
+		return gz, in.Close, nil
+	}
+	return buf, in.Close, nil
+}
+
+func importAction(ctx *cli.Context) error {
+	setupLogs(ctx)
+	if !ctx.Bool(importAllowDuplicatesFlag.Name) {
+		for _, storageType := range ctx.StringSlice(storageFlag.Name) {
+			if !containsString(deduplicatingStorages, storageType) {
+				return fmt.Errorf("storage %q can't deduplicate imported records, set --%s to import anyway",
+					storageType, importAllowDuplicatesFlag.Name)
+			}
+		}
+	}
+	in, closeInput, err := openInput(ctx.String(importInputFlag.Name))
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+
+	recordStorage, _, err := setupStorage(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer recordStorage.Stop()
+
+	batchSize := ctx.Int(importBatchSizeFlag.Name)
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	batches := make(map[string][]storage.Upsert)
+	imported, duplicates := 0, 0
+	flush := func(collection string) error {
+		if err := recordStorage.BulkUpsert(batches[collection], collection); err != nil {
+			return fmt.Errorf("unable to import records to %s: %v", collection, err)
+		}
+		imported += len(batches[collection])
+		batches[collection] = batches[collection][:0]
+		return nil
+	}
+
+	seen := make(map[[sha256.Size]byte]struct{})
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var record exportedRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("invalid record on line %d: %v", line, err)
+		}
+		if record.Collection == "" {
+			record.Collection = ctx.String(importCollectionFlag.Name)
+		}
+		if record.Collection == "" {
+			return fmt.Errorf("record on line %d has no collection", line)
+		}
+		hash := sha256.Sum256(scanner.Bytes())
+		if _, ok := seen[hash]; ok {
+			duplicates++
+			continue
+		}
+		seen[hash] = struct{}{}
+
+		record.Event.DateAdded = record.DateAdded
+		if record.Event.DateAdded.IsZero() {
+			record.Event.DateAdded = time.Now()
+		}
+		// records imported again are not duplicated
+		batches[record.Collection] = append(batches[record.Collection], storage.Upsert{Set: record.Event, Unique: true})
+		if len(batches[record.Collection]) >= batchSize {
+			if err := flush(record.Collection); err != nil {
+				return err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	for collection := range batches {
+		if len(batches[collection]) > 0 {
+			if err := flush(collection); err != nil {
+				return err
+			}
+		}
+	}
+	log.WithFields(log.Fields{
+		"records":    imported,
+		"duplicates": duplicates,
+	}).Info("Records imported")
+	return nil
+}
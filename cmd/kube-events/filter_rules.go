@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"sync"
 
 	"k8s.io/api/apps/v1"
@@ -33,21 +34,21 @@ func (df *DeployFilter) compareAndSwapGeneration(uid types.UID, newGeneration in
 	return false
 }
 
-func (df *DeployFilter) Filter(event watch.Event) bool {
+func (df *DeployFilter) Filter(event watch.Event) (bool, string) {
 	deploy, ok := event.Object.(*v1.Deployment)
 	if !ok {
-		return false
+		return false, "object is not deployment"
 	}
-	if event.Type == watch.Modified {
-		return df.compareAndSwapGeneration(deploy.UID, deploy.Generation)
+	if event.Type == watch.Modified && !df.compareAndSwapGeneration(deploy.UID, deploy.Generation) {
+		return false, fmt.Sprintf("generation %d is not changed", deploy.Generation)
 	}
-	return true
+	return true, ""
 }
 
-func ResourceQuotaFilter(event watch.Event) bool {
+func ResourceQuotaFilter(event watch.Event) (bool, string) {
 	rq, ok := event.Object.(*apiCore.ResourceQuota)
 	if !ok {
-		return false
+		return false, "object is not resource quota"
 	}
 	if event.Type == watch.Modified {
 		specLimitsMemory := rq.Spec.Hard[apiCore.ResourceLimitsMemory]
@@ -60,50 +61,70 @@ func ResourceQuotaFilter(event watch.Event) bool {
 		statusRequestsMemory := rq.Status.Hard[apiCore.ResourceRequestsMemory]
 		statusRequestsCPU := rq.Status.Hard[apiCore.ResourceRequestsCPU]
 
-		return specLimitsMemory.Cmp(statusLimitsMemory) != 0 ||
-			specLimitsCPU.Cmp(statusLimitsCPU) != 0 ||
-			specRequestsMemory.Cmp(statusRequestsMemory) != 0 ||
-			specRequestsCPU.Cmp(statusRequestsCPU) != 0
+		if specLimitsMemory.Cmp(statusLimitsMemory) == 0 &&
+			specLimitsCPU.Cmp(statusLimitsCPU) == 0 &&
+			specRequestsMemory.Cmp(statusRequestsMemory) == 0 &&
+			specRequestsCPU.Cmp(statusRequestsCPU) == 0 {
+			return false, "quota limits are not changed"
+		}
 	}
-	return true
+	return true, ""
 }
 
-func EventsFilter(event watch.Event) bool {
+// EventTypeFilter drops event deletions.
+func EventTypeFilter(event watch.Event) (bool, string) {
 	switch event.Type {
-	case watch.Added, watch.Error:
-		//pass
+	case watch.Added, watch.Modified, watch.Error:
+		//pass, modifications are series updates (count and last timestamp bumps)
+		return true, ""
 	default:
-		return false
+		return false, "event deletions are not recorded"
 	}
+}
 
-	kubeEvent, ok := event.Object.(*apiCore.Event)
+// InvolvedKindFilter passes only events of pods, persistent volume claims and nodes.
+func InvolvedKindFilter(event watch.Event) (bool, string) {
+	kubeEvent, ok := kubeEventFromObject(event.Object)
 	if !ok {
-		return false
+		return false, "object is not event"
 	}
-
 	switch kubeEvent.InvolvedObject.Kind {
 	case "Pod", "PersistentVolumeClaim", "Node":
-		//pass
+		return true, ""
 	default:
-		return false
+		return false, "events of " + involvedObject(kubeEvent) + " are not recorded"
+	}
+}
+
+// EventReasonFilter allows events reasons only from whitelist with messages not in blacklist.
+func EventReasonFilter(event watch.Event) (bool, string) {
+	kubeEvent, ok := kubeEventFromObject(event.Object)
+	if !ok {
+		return false, "object is not event"
 	}
+	if ok, reason := eventsWhitelist.check(kubeEvent); !ok {
+		return false, reason + " (" + involvedObject(kubeEvent) + ")"
+	}
+	return true, ""
+}
 
-	//Allow events reasons only from whitelist with messages not in blacklist
-	return eventsWhitelist.check(kubeEvent)
+// involvedObject describes object of event in drop reasons.
+func involvedObject(kubeEvent *apiCore.Event) string {
+	return fmt.Sprintf("%s %s/%s", kubeEvent.InvolvedObject.Kind, kubeEvent.InvolvedObject.Namespace, kubeEvent.InvolvedObject.Name)
 }
 
-func PVCFilter(event watch.Event) bool {
+func PVCFilter(event watch.Event) (bool, string) {
 	pv, ok := event.Object.(*apiCore.PersistentVolumeClaim)
 	if !ok {
-		return false
+		return false, "object is not persistent volume claim"
 	}
 	if event.Type == watch.Modified {
 		if len(pv.Finalizers) == 0 {
-			return false
+			return false, "claim has no finalizers"
 		}
 		if pv.DeletionTimestamp != nil {
-			return false
+			return false, "claim is being deleted"
 		}
 	}
-	return true
+	return true, ""
 }
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net"
+	"time"
+
+	"github.com/containerum/kube-events/pkg/eventsapi"
+	"github.com/containerum/kube-events/pkg/storage"
+	"github.com/containerum/kube-events/pkg/stream"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"gopkg.in/urfave/cli.v2"
+)
+
+var grpcListenFlag = cli.StringFlag{
+	Name:    "grpc_listen",
+	EnvVars: []string{"GRPC_LISTEN"},
+	Usage:   "gRPC API listen address. gRPC API is disabled if empty. Calls are authorized with --api_token tokens.",
+}
+
+// grpcStopTimeout limits waiting for unary calls on shutdown, watch streams are never finished by clients
+const grpcStopTimeout = 5 * time.Second
+
+type grpcServer struct {
+	addr string
+	srv  *grpc.Server
+}
+
+func setupGRPCServer(ctx *cli.Context, querier storage.EventQuerier, hub *stream.Hub) *grpcServer {
+	s := &grpcServer{
+		addr: ctx.String(grpcListenFlag.Name),
+		srv:  grpc.NewServer(eventsapi.RequireToken(ctx.StringSlice(apiTokensFlag.Name))...),
+	}
+	eventsapi.RegisterKubeEventsServer(s.srv, eventsapi.NewServer(eventsapi.ServerConfig{
+		Hub:     hub,
+		Querier: querier,
+	}))
+	return s
+}
+
+func (s *grpcServer) Run() {
+	if s.addr == "" {
+		return
+	}
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		log.WithError(err).Error("gRPC API failed")
+		return
+	}
+	log.WithField("addr", s.addr).Info("Starting gRPC API")
+	if err := s.srv.Serve(listener); err != nil {
+		log.WithError(err).Error("gRPC API failed")
+	}
+}
+
+func (s *grpcServer) Stop() {
+	stopped := make(chan struct{})
+	go func() {
+		s.srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(grpcStopTimeout):
+		s.srv.Stop()
+	}
+}
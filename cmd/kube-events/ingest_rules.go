@@ -0,0 +1,43 @@
+package main
+
+import (
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/httpapi"
+	"github.com/containerum/kube-events/pkg/storage/mongodb"
+)
+
+var userEventNames = eventSet{
+	kubeClientModel.UserRegistered:       nil,
+	kubeClientModel.UserActivated:        nil,
+	kubeClientModel.UserDeleted:          nil,
+	kubeClientModel.GroupCreated:         nil,
+	kubeClientModel.GroupDeleted:         nil,
+	kubeClientModel.UserAddedToGroup:     nil,
+	kubeClientModel.UserRemovedFromGroup: nil,
+}
+
+var systemEventNames = eventSet{
+	kubeClientModel.ExternalIPAdded:     nil,
+	kubeClientModel.ExternalIPDeleted:   nil,
+	kubeClientModel.StorageClassAdded:   nil,
+	kubeClientModel.StorageClassDeleted: nil,
+}
+
+// RouteIngestedEvent routes events from other Containerum services by pre-defined name or by resource type.
+// Resource events are accepted only from kubernetes watches.
+func RouteIngestedEvent(event kubeClientModel.Event) (string, error) {
+	switch {
+	case userEventNames.check(event.Name):
+		return mongodb.UserCollection, nil
+	case systemEventNames.check(event.Name):
+		return mongodb.SystemCollection, nil
+	}
+	switch event.ResourceType {
+	case kubeClientModel.TypeUser:
+		return mongodb.UserCollection, nil
+	case kubeClientModel.TypeSystem, kubeClientModel.TypeStorage:
+		return mongodb.SystemCollection, nil
+	default:
+		return "", httpapi.RouteError("events with resource_type %q are not accepted", event.ResourceType)
+	}
+}
@@ -5,17 +5,27 @@ import (
 
 	"github.com/containerum/kube-events/pkg/transform"
 
+	"github.com/containerum/kube-events/pkg/eventsv1"
 	"github.com/containerum/kube-events/pkg/informerwatch"
+	"github.com/containerum/kube-events/pkg/watchrecord"
 	log "github.com/sirupsen/logrus"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 )
 
+func init() {
+	// client and watch recorder find events.k8s.io/v1 types in client-go scheme
+	utilruntime.Must(eventsv1.AddToScheme(scheme.Scheme))
+}
+
 type Kube struct {
 	*kubernetes.Clientset
-	config *rest.Config
+	eventsV1 rest.Interface
+	config   *rest.Config
 }
 
 type Watchers struct {
@@ -29,22 +39,31 @@ type Watchers struct {
 	PVCs           watch.Interface //Volumes
 }
 
-func (k *Kube) WatchSupportedResources() Watchers {
+// WatchSupportedResources starts informers and returns unfiltered watchers. onResync is called with resource name when informer relists resources.
+func (k *Kube) WatchSupportedResources(eventsAPI string, onResync func(resource string)) Watchers {
 	informerFactory := informers.NewSharedInformerFactory(k.Clientset, 0)
 
-	rqWatch := informerwatch.NewInformerWatch(informerFactory.Core().V1().ResourceQuotas().Informer())
-	deplWatch := informerwatch.NewInformerWatch(informerFactory.Apps().V1().Deployments().Informer())
-	eventWatch := informerwatch.NewInformerWatch(informerFactory.Core().V1().Events().Informer())
-	serviceWatch := informerwatch.NewInformerWatch(informerFactory.Core().V1().Services().Informer())
-	ingressWatch := informerwatch.NewInformerWatch(informerFactory.Extensions().V1beta1().Ingresses().Informer())
-	pvcWatch := informerwatch.NewInformerWatch(informerFactory.Core().V1().PersistentVolumeClaims().Informer())
-	secretWatch := informerwatch.NewInformerWatch(informerFactory.Core().V1().Secrets().Informer())
-	cmWatch := informerwatch.NewInformerWatch(informerFactory.Core().V1().ConfigMaps().Informer())
+	eventInformer := informerFactory.Core().V1().Events().Informer()
+	switch eventsAPI {
+	case eventsAPIEventsV1beta1:
+		eventInformer = informerFactory.Events().V1beta1().Events().Informer()
+	case eventsAPIEventsV1:
+		eventInformer = eventsv1.NewInformer(k.eventsV1)
+	}
+
+	rqWatch := informerwatch.NewInformerWatch(informerFactory.Core().V1().ResourceQuotas().Informer(), func() { onResync("ResourceQuota") })
+	deplWatch := informerwatch.NewInformerWatch(informerFactory.Apps().V1().Deployments().Informer(), func() { onResync("Deployment") })
+	eventWatch := informerwatch.NewInformerWatch(eventInformer, func() { onResync("Event") })
+	serviceWatch := informerwatch.NewInformerWatch(informerFactory.Core().V1().Services().Informer(), func() { onResync("Service") })
+	ingressWatch := informerwatch.NewInformerWatch(informerFactory.Extensions().V1beta1().Ingresses().Informer(), func() { onResync("Ingress") })
+	pvcWatch := informerwatch.NewInformerWatch(informerFactory.Core().V1().PersistentVolumeClaims().Informer(), func() { onResync("PersistentVolumeClaim") })
+	secretWatch := informerwatch.NewInformerWatch(informerFactory.Core().V1().Secrets().Informer(), func() { onResync("Secret") })
+	cmWatch := informerwatch.NewInformerWatch(informerFactory.Core().V1().ConfigMaps().Informer(), func() { onResync("ConfigMap") })
 
 	log.Infof("Watching for: %s", strings.Join([]string{
 		"ResourceQuota",
 		"Deployment",
-		"Event",
+		"Event (" + eventsAPI + ")",
 		"Service",
 		"Ingress",
 		"PersistentVolumeClaim",
@@ -53,13 +72,61 @@ func (k *Kube) WatchSupportedResources() Watchers {
 	}, ","))
 
 	return Watchers{
-		ResourceQuotas: transform.NewFilteredWatch(rqWatch, ResourceQuotaFilter),
-		Deployments:    transform.NewFilteredWatch(deplWatch, NewDeployFilter().Filter),
-		Events:         transform.NewFilteredWatch(eventWatch, EventsFilter),
-		Services:       transform.NewFilteredWatch(serviceWatch),
-		Ingresses:      transform.NewFilteredWatch(ingressWatch),
-		PVCs:           transform.NewFilteredWatch(pvcWatch, PVCFilter),
-		Secrets:        transform.NewFilteredWatch(secretWatch),
-		ConfigMaps:     transform.NewFilteredWatch(cmWatch),
+		ResourceQuotas: rqWatch,
+		Deployments:    deplWatch,
+		Events:         eventWatch,
+		Services:       serviceWatch,
+		Ingresses:      ingressWatch,
+		PVCs:           pvcWatch,
+		Secrets:        secretWatch,
+		ConfigMaps:     cmWatch,
+	}
+}
+
+// ReplayWatchers makes watchers of events replayed from recording.
+func ReplayWatchers(replay *watchrecord.Replay) Watchers {
+	return Watchers{
+		ResourceQuotas: replay.Watch("ResourceQuota"),
+		Deployments:    replay.Watch("Deployment"),
+		Events:         replay.Watch("Event"),
+		Services:       replay.Watch("Service"),
+		Ingresses:      replay.Watch("Ingress"),
+		PVCs:           replay.Watch("PersistentVolumeClaim"),
+		Secrets:        replay.Watch("Secret"),
+		ConfigMaps:     replay.Watch("ConfigMap"),
+	}
+}
+
+// Recorded returns watchers which record raw events with recorder.
+func (w Watchers) Recorded(recorder *watchrecord.Recorder) Watchers {
+	return Watchers{
+		ResourceQuotas: recorder.Tee("ResourceQuota", w.ResourceQuotas),
+		Deployments:    recorder.Tee("Deployment", w.Deployments),
+		Events:         recorder.Tee("Event", w.Events),
+		Services:       recorder.Tee("Service", w.Services),
+		Ingresses:      recorder.Tee("Ingress", w.Ingresses),
+		PVCs:           recorder.Tee("PersistentVolumeClaim", w.PVCs),
+		Secrets:        recorder.Tee("Secret", w.Secrets),
+		ConfigMaps:     recorder.Tee("ConfigMap", w.ConfigMaps),
+	}
+}
+
+// Filtered returns watchers which pass only events worth recording. onDrop (if not nil) is called for every dropped event.
+func (w Watchers) Filtered(onDrop transform.DropFunc) Watchers {
+	return Watchers{
+		ResourceQuotas: transform.NewTracedFilteredWatch(w.ResourceQuotas, "ResourceQuota", onDrop,
+			transform.NamedPredicate{Name: "resource_quota", Filter: ResourceQuotaFilter}),
+		Deployments: transform.NewTracedFilteredWatch(w.Deployments, "Deployment", onDrop,
+			transform.NamedPredicate{Name: "deployment_generation", Filter: NewDeployFilter().Filter}),
+		Events: transform.NewTracedFilteredWatch(w.Events, "Event", onDrop,
+			transform.NamedPredicate{Name: "event_type", Filter: EventTypeFilter},
+			transform.NamedPredicate{Name: "involved_kind", Filter: InvolvedKindFilter},
+			transform.NamedPredicate{Name: "event_reason", Filter: EventReasonFilter}),
+		Services:  transform.NewTracedFilteredWatch(w.Services, "Service", onDrop),
+		Ingresses: transform.NewTracedFilteredWatch(w.Ingresses, "Ingress", onDrop),
+		PVCs: transform.NewTracedFilteredWatch(w.PVCs, "PersistentVolumeClaim", onDrop,
+			transform.NamedPredicate{Name: "pvc_state", Filter: PVCFilter}),
+		Secrets:    transform.NewTracedFilteredWatch(w.Secrets, "Secret", onDrop),
+		ConfigMaps: transform.NewTracedFilteredWatch(w.ConfigMaps, "ConfigMap", onDrop),
 	}
 }
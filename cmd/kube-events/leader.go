@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/storage/mongodb"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/urfave/cli.v2"
+)
+
+var (
+	leaderElectionFlag = cli.BoolFlag{
+		Name:    "leader_election",
+		EnvVars: []string{"LEADER_ELECTION"},
+		Usage:   "Record events only while holding leader lease in MongoDB, so several replicas do not duplicate records.",
+	}
+
+	leaderLeaseTTLFlag = cli.DurationFlag{
+		Name:    "leader_lease_ttl",
+		EnvVars: []string{"LEADER_LEASE_TTL"},
+		Usage:   "Leader lease duration. Lease is renewed every third of it.",
+		Value:   15 * time.Second,
+	}
+)
+
+// leaderElector holds leader lease and records leadership changes.
+type leaderElector struct {
+	storage  *mongodb.Storage
+	system   *SystemRecorder
+	identity string
+	ttl      time.Duration
+	lost     chan struct{}
+	stop     chan struct{}
+	// renewed is closed when lease renewal is stopped
+	renewed chan struct{}
+
+	log *log.Entry
+}
+
+func setupLeaderElection(ctx *cli.Context, mongoStorage *mongodb.Storage, system *SystemRecorder) *leaderElector {
+	if !ctx.Bool(leaderElectionFlag.Name) || mongoStorage == nil {
+		return nil
+	}
+	host, _ := os.Hostname()
+	return &leaderElector{
+		// lease is released after record storages including Mongo are stopped
+		storage:  mongoStorage.Copy(),
+		system:   system,
+		identity: fmt.Sprintf("%s/%d", host, os.Getpid()),
+		ttl:      ctx.Duration(leaderLeaseTTLFlag.Name),
+		lost:     make(chan struct{}),
+		stop:     make(chan struct{}),
+		renewed:  make(chan struct{}),
+		log:      log.WithField("component", "leader_elector"),
+	}
+}
+
+// Acquire blocks until leader lease is taken or interrupt is closed. It returns false if interrupted.
+func (le *leaderElector) Acquire(interrupt <-chan os.Signal) bool {
+	ticker := time.NewTicker(le.ttl / 3)
+	defer ticker.Stop()
+	for {
+		acquired, err := le.storage.AcquireLeadership(le.identity, le.ttl)
+		if err != nil {
+			le.log.WithError(err).Error("Unable to acquire leader lease")
+		}
+		if acquired {
+			le.log.WithField("identity", le.identity).Info("Became leader")
+			le.system.Record(kubeClientModel.EventInfo, LeaderElected, "",
+				map[string]string{"identity": le.identity})
+			go le.renew()
+			return true
+		}
+		if leader, err := le.storage.Leader(); err == nil && leader != "" {
+			le.log.WithField("leader", leader).Info("Waiting for leadership")
+		}
+		select {
+		case <-interrupt:
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// renew prolongs leader lease. Lost is closed when lease can not be renewed before it expires.
+func (le *leaderElector) renew() {
+	defer close(le.renewed)
+	ticker := time.NewTicker(le.ttl / 3)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-le.stop:
+			return
+		case <-ticker.C:
+			acquired, err := le.storage.AcquireLeadership(le.identity, le.ttl)
+			if err != nil {
+				le.log.WithError(err).Error("Unable to renew leader lease")
+			}
+			if acquired {
+				renewed = time.Now()
+				continue
+			}
+			if err == nil || time.Since(renewed) >= le.ttl {
+				close(le.lost)
+				return
+			}
+		}
+	}
+}
+
+// Lost is closed when leadership is lost. It is nil and never ready if leader election is disabled.
+func (le *leaderElector) Lost() <-chan struct{} {
+	if le == nil {
+		return nil
+	}
+	return le.lost
+}
+
+// Release stops lease renewal and releases lease, so other replica becomes leader without waiting for expiration.
+// It is called only after lease is acquired.
+func (le *leaderElector) Release() {
+	if le == nil {
+		return
+	}
+	close(le.stop)
+	<-le.renewed
+	if err := le.storage.ReleaseLeadership(le.identity); err != nil {
+		le.log.WithError(err).Error("Unable to release leader lease")
+	}
+	le.storage.Close()
+}
@@ -8,13 +8,18 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"syscall"
 	"text/tabwriter"
 	"time"
 
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/storage"
+	"github.com/containerum/kube-events/pkg/storage/jsonl"
 	"github.com/containerum/kube-events/pkg/storage/mongodb"
 
 	"github.com/containerum/kube-events/pkg/model"
 	"github.com/containerum/kube-events/pkg/transform"
+	"github.com/containerum/kube-events/pkg/watchrecord"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/urfave/cli.v2"
 	"k8s.io/apimachinery/pkg/watch"
@@ -38,7 +43,13 @@ var eventTransformer = transform.EventTransformer{
 }
 
 func printFlags(ctx *cli.Context) error {
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.TabIndent|tabwriter.Debug)
+	out := os.Stdout
+	// stdout is reserved for records
+	if (containsString(ctx.StringSlice(storageFlag.Name), storageJSONL) && ctx.String(jsonlPathFlag.Name) == jsonl.Stdout) ||
+		ctx.Args().First() == exportCommand.Name || ctx.Bool(dryRunFlag.Name) {
+		out = os.Stderr
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.TabIndent|tabwriter.Debug)
 	for _, f := range ctx.FlagNames() {
 		fmt.Fprintf(w, "Flag: %s\t Value: %s\n", f, ctx.String(f))
 	}
@@ -93,7 +104,9 @@ func pingKube(client *Kube, pingPeriod time.Duration, errChan chan<- error, stop
 			}
 			if resp.StatusCode != http.StatusOK || string(body) != "ok" {
 				errChan <- fmt.Errorf("%s", body)
+				continue
 			}
+			errChan <- nil
 		}
 	}
 }
@@ -101,84 +114,258 @@ func pingKube(client *Kube, pingPeriod time.Duration, errChan chan<- error, stop
 func action(ctx *cli.Context) error {
 	setupLogs(ctx)
 
-	kubeClient, err := setupKubeClient(ctx)
-	if err != nil {
-		return err
+	eventsAPI := ctx.String(eventsAPIFlag.Name)
+	switch eventsAPI {
+	case eventsAPICore, eventsAPIEventsV1beta1, eventsAPIEventsV1:
+		//pass
+	default:
+		return fmt.Errorf("unsupported events API %q", eventsAPI)
 	}
-	log.WithField("Size", len(kubeClient.config.BearerToken)).Debug("BearerToken")
 
-	watchers := kubeClient.WatchSupportedResources()
+	system := NewSystemRecorder(ctx.Int(bufferCapacityFlag.Name))
+	system.Record(kubeClientModel.EventInfo, KubeEventsStarted, "", map[string]string{"events_api": eventsAPI})
 
-	mongoStorage, err := setupMongo(ctx)
+	var recordStorage *storage.FanOut
+	var mongoStorage *mongodb.Storage
+	dropTracer, err := setupDropTracer(ctx)
 	if err != nil {
 		return err
 	}
-	defer mongoStorage.Close()
+	onDrop := dropTracer.Trace
+	dryRun := ctx.Bool(dryRunFlag.Name)
+	if dryRun {
+		printer, err := newDryRunPrinter(ctx)
+		if err != nil {
+			return err
+		}
+		if ctx.Bool(dryRunShowDroppedFlag.Name) {
+			onDrop = func(trace transform.DropTrace) {
+				dropTracer.Trace(trace)
+				printer.OnDrop(trace)
+			}
+		}
+		recordStorage = setupDryRun(printer)
+		log.Info("Dry run: records are printed instead of writing to storages")
+	} else {
+		var err error
+		if recordStorage, mongoStorage, err = setupStorage(ctx, system.OnDrop); err != nil {
+			return err
+		}
+	}
+	defer recordStorage.Stop()
+
+	sigch := make(chan os.Signal, 1)
+	signal.Notify(sigch, os.Interrupt, syscall.SIGTERM)
+
+	leader := setupLeaderElection(ctx, mongoStorage, system)
+	if leader != nil {
+		if !leader.Acquire(sigch) {
+			system.RecordNow(recordStorage, kubeClientModel.EventInfo, KubeEventsStopped, "Interrupted", nil)
+			return nil
+		}
+		// lease is released after queued records are written, so the next leader starts after them
+		defer func() {
+			recordStorage.Stop()
+			leader.Release()
+		}()
+	}
+
+	var kubeClient *Kube
+	var replay *watchrecord.Replay
+	var watchers Watchers
+	if ctx.String(replayWatchFlag.Name) != "" {
+		var err error
+		if replay, err = setupReplay(ctx); err != nil {
+			return err
+		}
+		watchers = ReplayWatchers(replay)
+	} else {
+		var err error
+		if kubeClient, err = setupKubeClient(ctx); err != nil {
+			return err
+		}
+		log.WithField("Size", len(kubeClient.config.BearerToken)).Debug("BearerToken")
+
+		watchers = kubeClient.WatchSupportedResources(eventsAPI, system.OnRelist)
+		recorder, err := setupWatchRecorder(ctx)
+		if err != nil {
+			return err
+		}
+		if recorder != nil {
+			defer recorder.Close()
+			watchers = watchers.Recorded(recorder)
+		}
+	}
+	watchers = watchers.Filtered(onDrop)
+	//Query API and subscriptions need Mongo
+	var querier storage.EventQuerier
+	if mongoStorage != nil {
+		querier = mongoStorage
+	}
+
+	hub := setupStreamHub(ctx)
+
+	// dry run has no side effects: alerts are not sent, subscriptions are not dispatched and records are not archived
+	alertStop := make(chan struct{})
+	defer close(alertStop)
+	if !dryRun {
+		if err := setupAlerts(ctx, hub, alertStop); err != nil {
+			return err
+		}
+	}
+
+	api := setupAPIServer(ctx, querier, hub)
+	api.HandleStorageHealth(recordStorage)
+	api.HandleDropTraces(dropTracer)
+
+	subscriptionStop := make(chan struct{})
+	defer close(subscriptionStop)
+	if !dryRun && mongoStorage != nil {
+		api.HandleSubscriptions(ctx, mongoStorage, setupSubscriptions(ctx, mongoStorage, hub, subscriptionStop))
+	}
+
+	archiveStop := make(chan struct{})
+	defer close(archiveStop)
+	if !dryRun && mongoStorage != nil {
+		if err := setupArchiver(ctx, mongoStorage, archiveStop); err != nil {
+			return err
+		}
+	}
 
 	//Namespaces
 	defer watchers.ResourceQuotas.Stop()
-	nsBuffer := setupBuffer(ctx, mongoStorage, eventTransformer.Output(watchers.ResourceQuotas.ResultChan()))
+	nsBuffer := setupBuffer(ctx, recordStorage, ResourceUpsert, system.OnWrite, hub.Tee(mongodb.ResourceQuotasCollection, eventTransformer.Output(watchers.ResourceQuotas.ResultChan())))
 	defer nsBuffer.Stop()
 	go nsBuffer.RunCollection(mongodb.ResourceQuotasCollection)
 
 	//Deployments
 	defer watchers.Deployments.Stop()
-	deplBuffer := setupBuffer(ctx, mongoStorage, eventTransformer.Output(watchers.Deployments.ResultChan()))
+	deplBuffer := setupBuffer(ctx, recordStorage, ResourceUpsert, system.OnWrite, hub.Tee(mongodb.DeploymentCollection, eventTransformer.Output(watchers.Deployments.ResultChan())))
 	defer deplBuffer.Stop()
 	go deplBuffer.RunCollection(mongodb.DeploymentCollection)
 
 	//Services
 	defer watchers.Services.Stop()
-	svcBuffer := setupBuffer(ctx, mongoStorage, eventTransformer.Output(watchers.Services.ResultChan()))
+	svcBuffer := setupBuffer(ctx, recordStorage, ResourceUpsert, system.OnWrite, hub.Tee(mongodb.ServiceCollection, eventTransformer.Output(watchers.Services.ResultChan())))
 	defer svcBuffer.Stop()
 	go svcBuffer.RunCollection(mongodb.ServiceCollection)
 
 	//Ingresses
 	defer watchers.Ingresses.Stop()
-	ingrBuffer := setupBuffer(ctx, mongoStorage, eventTransformer.Output(watchers.Ingresses.ResultChan()))
+	ingrBuffer := setupBuffer(ctx, recordStorage, ResourceUpsert, system.OnWrite, hub.Tee(mongodb.IngressCollection, eventTransformer.Output(watchers.Ingresses.ResultChan())))
 	defer ingrBuffer.Stop()
 	go ingrBuffer.RunCollection(mongodb.IngressCollection)
 
 	//Volumes
 	defer watchers.PVCs.Stop()
-	pvcBuffer := setupBuffer(ctx, mongoStorage, eventTransformer.Output(watchers.PVCs.ResultChan()))
+	pvcBuffer := setupBuffer(ctx, recordStorage, ResourceUpsert, system.OnWrite, hub.Tee(mongodb.PVCCollection, eventTransformer.Output(watchers.PVCs.ResultChan())))
 	defer pvcBuffer.Stop()
 	go pvcBuffer.RunCollection(mongodb.PVCCollection)
 
 	//Secrets
 	defer watchers.PVCs.Stop()
-	secretBuffer := setupBuffer(ctx, mongoStorage, eventTransformer.Output(watchers.Secrets.ResultChan()))
+	secretBuffer := setupBuffer(ctx, recordStorage, ResourceUpsert, system.OnWrite, hub.Tee(mongodb.SecretsCollection, eventTransformer.Output(watchers.Secrets.ResultChan())))
 	defer secretBuffer.Stop()
 	go secretBuffer.RunCollection(mongodb.SecretsCollection)
 
 	//ConfigMaps
 	defer watchers.PVCs.Stop()
-	cmBuffer := setupBuffer(ctx, mongoStorage, eventTransformer.Output(watchers.ConfigMaps.ResultChan()))
+	cmBuffer := setupBuffer(ctx, recordStorage, ResourceUpsert, system.OnWrite, hub.Tee(mongodb.ConfigMapsCollection, eventTransformer.Output(watchers.ConfigMaps.ResultChan())))
 	defer cmBuffer.Stop()
 	go cmBuffer.RunCollection(mongodb.ConfigMapsCollection)
 
 	//Events
 	defer watchers.Events.Stop()
-	eventBuffer := setupBuffer(ctx, mongoStorage, eventTransformer.Output(watchers.Events.ResultChan()))
+	eventBuffer := setupBuffer(ctx, recordStorage, EventUpsert, system.OnWrite, hub.Tee(mongodb.EventsCollection, eventTransformer.Output(watchers.Events.ResultChan())))
 	defer eventBuffer.Stop()
 	go eventBuffer.RunCollection(mongodb.EventsCollection)
 
+	//User and system events from other services
+	userEvents := make(chan kubeClientModel.Event)
+	userBuffer := setupBuffer(ctx, recordStorage, nil, system.OnWrite, hub.Tee(mongodb.UserCollection, userEvents))
+	defer userBuffer.Stop()
+	go userBuffer.RunCollection(mongodb.UserCollection)
+
+	systemEvents := system.Events()
+	systemBuffer := setupBuffer(ctx, recordStorage, nil, system.OnWrite, hub.Tee(mongodb.SystemCollection, systemEvents))
+	defer systemBuffer.Stop()
+	go systemBuffer.RunCollection(mongodb.SystemCollection)
+
+	// buffered records are written on exit before buffers and storages are stopped
+	defer flushBuffers(map[string]*storage.RecordBuffer{
+		mongodb.ResourceQuotasCollection: nsBuffer,
+		mongodb.DeploymentCollection:     deplBuffer,
+		mongodb.ServiceCollection:        svcBuffer,
+		mongodb.IngressCollection:        ingrBuffer,
+		mongodb.PVCCollection:            pvcBuffer,
+		mongodb.SecretsCollection:        secretBuffer,
+		mongodb.ConfigMapsCollection:     cmBuffer,
+		mongodb.EventsCollection:         eventBuffer,
+		mongodb.UserCollection:           userBuffer,
+		mongodb.SystemCollection:         systemBuffer,
+	})
+
+	api.HandleIngest(ctx, map[string]chan<- kubeClientModel.Event{
+		mongodb.UserCollection:   userEvents,
+		mongodb.SystemCollection: systemEvents,
+	})
+
+	go api.Run()
+	defer api.Stop()
+
+	grpcAPI := setupGRPCServer(ctx, querier, hub)
+	go grpcAPI.Run()
+	defer grpcAPI.Stop()
+
 	pingStopChan := make(chan struct{})
 	defer close(pingStopChan)
 	pingErrChan := make(chan error)
-	go pingKube(kubeClient, 5*time.Second, pingErrChan, pingStopChan)
+	if kubeClient != nil {
+		go pingKube(kubeClient, 5*time.Second, pingErrChan, pingStopChan)
+	}
 
-	sigch := make(chan os.Signal, 1)
-	signal.Notify(sigch, os.Interrupt)
-	select {
-	case <-sigch:
-		return nil
-	case err := <-pingErrChan:
-		log.WithError(err).Errorf("Ping kube failed")
-		os.Exit(1)
+	// replayDone is nil and never ready if events are not replayed
+	var replayDone <-chan struct{}
+	if replay != nil {
+		replayDone = replay.Done()
+		replay.Start()
 	}
 
-	return nil
+	for {
+		select {
+		case <-sigch:
+			system.RecordNow(recordStorage, kubeClientModel.EventInfo, KubeEventsStopped, "Interrupted", nil)
+			return nil
+		case <-leader.Lost():
+			system.RecordNow(recordStorage, kubeClientModel.EventWarning, LeaderLost, "Leader lease is not renewed", nil)
+			return fmt.Errorf("leadership lost")
+		case <-replayDone:
+			flushReplayed(map[string]*storage.RecordBuffer{
+				mongodb.ResourceQuotasCollection: nsBuffer,
+				mongodb.DeploymentCollection:     deplBuffer,
+				mongodb.ServiceCollection:        svcBuffer,
+				mongodb.IngressCollection:        ingrBuffer,
+				mongodb.PVCCollection:            pvcBuffer,
+				mongodb.SecretsCollection:        secretBuffer,
+				mongodb.ConfigMapsCollection:     cmBuffer,
+				mongodb.EventsCollection:         eventBuffer,
+			})
+			systemBuffer.Flush(mongodb.SystemCollection)
+			system.RecordNow(recordStorage, kubeClientModel.EventInfo, KubeEventsStopped, "Replay finished", nil)
+			return nil
+		case err := <-pingErrChan:
+			if err != nil {
+				log.WithError(err).Errorf("Ping kube failed")
+			}
+			unreachable := system.OnKubePing(err)
+			if unreachable > ctx.Duration(kubeUnreachableTimeoutFlag.Name) {
+				system.RecordNow(recordStorage, kubeClientModel.EventError, KubeEventsStopped,
+					fmt.Sprintf("Kubernetes API server is unreachable for %v", unreachable), nil)
+				// returned error lets deferred flushes and leader release run before exit
+				return fmt.Errorf("kubernetes API server is unreachable for %v", unreachable)
+			}
+		}
+	}
 }
 
 func main() {
@@ -189,14 +376,145 @@ func main() {
 			&configFlag,
 			&debugFlag,
 			&textlogFlag,
+			&storageFlag,
+			&primaryStorageFlag,
+			&storageRoutesFlag,
+			&storageQueueSizeFlag,
+			&storageWriteRetriesFlag,
+			&storageRetryDelayFlag,
 			&mongoAddressFlag,
 			&mongoUserFlag,
 			&mongoPasswordFlag,
 			&mongoDatabaseFlag,
+			&webhookURLFlag,
+			&webhookCollectionURLFlag,
+			&webhookFormatFlag,
+			&webhookSecretFlag,
+			&webhookHeadersFlag,
+			&webhookTimeoutFlag,
+			&webhookMaxAttemptsFlag,
+			&webhookRetryDelayFlag,
+			&webhookMaxRetryDelayFlag,
+			&webhookRetryStatusFlag,
+			&elasticsearchURLFlag,
+			&elasticsearchIndexPrefixFlag,
+			&elasticsearchIndexDateFormatFlag,
+			&elasticsearchUserFlag,
+			&elasticsearchPasswordFlag,
+			&elasticsearchAPIKeyFlag,
+			&elasticsearchTimeoutFlag,
+			&elasticsearchMaxAttemptsFlag,
+			&elasticsearchRetryDelayFlag,
+			&lokiURLFlag,
+			&lokiFormatFlag,
+			&lokiTenantIDFlag,
+			&lokiUserFlag,
+			&lokiPasswordFlag,
+			&lokiLabelsFlag,
+			&lokiTimeoutFlag,
+			&lokiMaxAttemptsFlag,
+			&lokiRetryDelayFlag,
+			&cloudEventsURLFlag,
+			&cloudEventsModeFlag,
+			&cloudEventsClusterFlag,
+			&cloudEventsTypePrefixFlag,
+			&cloudEventsHeadersFlag,
+			&cloudEventsTimeoutFlag,
+			&cloudEventsMaxAttemptsFlag,
+			&cloudEventsRetryDelayFlag,
+			&syslogNetworkFlag,
+			&syslogAddrFlag,
+			&syslogFacilityFlag,
+			&syslogHostnameFlag,
+			&syslogAppNameFlag,
+			&syslogQueueSizeFlag,
+			&syslogTLSCAFlag,
+			&syslogTLSInsecureFlag,
+			&syslogReconnectDelayFlag,
+			&syslogTimeoutFlag,
+			&jsonlPathFlag,
+			&jsonlMaxSizeFlag,
+			&jsonlMaxAgeFlag,
+			&jsonlMaxBackupsFlag,
+			&jsonlCompressFlag,
+			&jsonlFsyncFlag,
+			&jsonlFsyncIntervalFlag,
+			&natsURLFlag,
+			&natsSubjectPrefixFlag,
+			&natsJetStreamFlag,
+			&natsUserFlag,
+			&natsPasswordFlag,
+			&natsTokenFlag,
+			&natsTLSCAFlag,
+			&natsTimeoutFlag,
+			&natsMaxAttemptsFlag,
+			&natsRetryDelayFlag,
+			&redisAddrFlag,
+			&redisUserFlag,
+			&redisPasswordFlag,
+			&redisDBFlag,
+			&redisTLSCAFlag,
+			&redisKeyPrefixFlag,
+			&redisMaxLenFlag,
+			&redisExactTrimFlag,
+			&redisTimeoutFlag,
+			&redisMaxAttemptsFlag,
+			&redisRetryDelayFlag,
+			&retentionFlag,
+			&retentionRulesFlag,
+			&retentionSweepPeriodFlag,
+			&archiveTargetFlag,
+			&archiveDirFlag,
+			&archiveS3EndpointFlag,
+			&archiveS3BucketFlag,
+			&archiveS3RegionFlag,
+			&archiveS3AccessKeyFlag,
+			&archiveS3SecretKeyFlag,
+			&archiveS3PrefixFlag,
+			&archiveLeadTimeFlag,
+			&archivePeriodFlag,
 			&bufferCapacityFlag,
 			&bufferFlushPeriodFlag,
 			&bufferMinInsertEventsFlag,
+			&bufferWriteRetriesFlag,
+			&bufferRetryDelayFlag,
 			&connectTimeoutFlag,
+			&kubeUnreachableTimeoutFlag,
+			&eventsAPIFlag,
+			&leaderElectionFlag,
+			&leaderLeaseTTLFlag,
+			&dryRunFlag,
+			&dryRunFormatFlag,
+			&dryRunShowDroppedFlag,
+			&dropTraceSizeFlag,
+			&dropTraceLogSampleFlag,
+			&recordWatchFlag,
+			&replayWatchFlag,
+			&replayRealtimeFlag,
+			&apiListenFlag,
+			&apiTokensFlag,
+			&grpcListenFlag,
+			&streamHistoryFlag,
+			&streamSubscriberBufferFlag,
+			&streamKeepAliveFlag,
+			&ingestTokensFlag,
+			&ingestTimeoutFlag,
+			&alertConfigFlag,
+			&alertWorkersFlag,
+			&subscriptionWorkersFlag,
+			&subscriptionQueueFlag,
+			&subscriptionMaxAttemptsFlag,
+			&subscriptionRetryDelayFlag,
+			&subscriptionMaxRetryDelayFlag,
+			&subscriptionTimeoutFlag,
+			&subscriptionRefreshPeriodFlag,
+			&subscriptionAllowPrivateTargetsFlag,
+		},
+		Commands: []*cli.Command{
+			&migrateCommand,
+			&archiveCommand,
+			&exportCommand,
+			&importCommand,
 		},
 		Before: printFlags,
 		Action: action,
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/containerum/kube-events/pkg/storage/mongodb"
+	"gopkg.in/urfave/cli.v2"
+)
+
+var dryRunMigrationsFlag = cli.BoolFlag{
+	Name:  "dry_run",
+	Usage: "Print pending migrations without applying them.",
+}
+
+var migrateCommand = cli.Command{
+	Name:  "migrate",
+	Usage: "Manage MongoDB storage schema migrations.",
+	Subcommands: []*cli.Command{
+		{
+			Name:   "status",
+			Usage:  "Print schema version and pending migrations.",
+			Action: migrateStatusAction,
+		},
+		{
+			Name:   "up",
+			Usage:  "Apply pending migrations.",
+			Flags:  []cli.Flag{&dryRunMigrationsFlag},
+			Action: migrateUpAction,
+		},
+	},
+}
+
+func dialMongo(ctx *cli.Context) (*mongodb.Storage, error) {
+	setupLogs(ctx)
+	dialInfo, retention, err := mongoConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mongodb.Dial(dialInfo, retention)
+}
+
+func printMigrations(migrations []mongodb.Migration, withSteps bool) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, migration := range migrations {
+		fmt.Fprintf(w, "%d\t%s\n", migration.Version, migration.Description)
+		if !withSteps {
+			continue
+		}
+		for _, step := range migration.Steps {
+			fmt.Fprintf(w, "\t  %s\n", step)
+		}
+	}
+	return w.Flush()
+}
+
+func migrateStatusAction(ctx *cli.Context) error {
+	mongoStorage, err := dialMongo(ctx)
+	if err != nil {
+		return err
+	}
+	defer mongoStorage.Close()
+
+	version, err := mongoStorage.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	pending, err := mongoStorage.PendingMigrations()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Schema version: %d\n", version)
+	fmt.Printf("Pending migrations: %d\n", len(pending))
+	return printMigrations(pending, false)
+}
+
+func migrateUpAction(ctx *cli.Context) error {
+	mongoStorage, err := dialMongo(ctx)
+	if err != nil {
+		return err
+	}
+	defer mongoStorage.Close()
+
+	dryRun := ctx.Bool(dryRunMigrationsFlag.Name)
+	migrations, err := mongoStorage.Migrate(dryRun)
+	if dryRun {
+		fmt.Printf("Would apply %d migrations:\n", len(migrations))
+	} else {
+		fmt.Printf("Applied %d migrations:\n", len(migrations))
+	}
+	if printErr := printMigrations(migrations, dryRun); printErr != nil {
+		return printErr
+	}
+	return err
+}
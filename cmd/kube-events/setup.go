@@ -1,10 +1,13 @@
 package main
 
 import (
+	"fmt"
 	"io/ioutil"
+	"strings"
 	"time"
 
 	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/eventsv1"
 	"github.com/containerum/kube-events/pkg/storage"
 	"github.com/containerum/kube-events/pkg/storage/mongodb"
 	"github.com/globalsign/mgo"
@@ -64,6 +67,27 @@ var (
 		Value:   "kube-watches",
 	}
 
+	retentionFlag = cli.DurationFlag{
+		Name:    "retention",
+		EnvVars: []string{"RETENTION"},
+		Usage:   "Default period of storing records.",
+		Value:   30 * 24 * time.Hour,
+	}
+
+	retentionRulesFlag = cli.StringSliceFlag{
+		Name:    "retention_rule",
+		EnvVars: []string{"RETENTION_RULES"},
+		Usage: "Retention override in format \"<collection or *>[/<event kind or name>]=<duration>\", " +
+			"i.e. \"events=1440h\", \"*/error=2160h\", \"*/ResourceDeleted=2160h\".",
+	}
+
+	retentionSweepPeriodFlag = cli.DurationFlag{
+		Name:    "retention_sweep_period",
+		EnvVars: []string{"RETENTION_SWEEP_PERIOD"},
+		Usage:   "Period of removing records which retention is shorter than collection TTL.",
+		Value:   time.Hour,
+	}
+
 	bufferCapacityFlag = cli.IntFlag{
 		Name:    "buffer_capacity",
 		EnvVars: []string{"BUFFER_CAPACITY"},
@@ -85,6 +109,35 @@ var (
 		Value:   30 * time.Second,
 	}
 
+	kubeUnreachableTimeoutFlag = cli.DurationFlag{
+		Name:    "kube_unreachable_timeout",
+		EnvVars: []string{"KUBE_UNREACHABLE_TIMEOUT"},
+		Usage:   "Exit if Kubernetes API server is unreachable longer than timeout.",
+		Value:   time.Minute,
+	}
+
+	eventsAPIFlag = cli.StringFlag{
+		Name:    "events_api",
+		EnvVars: []string{"EVENTS_API"},
+		Usage: "Kubernetes Events API to watch: \"" + eventsAPICore + "\", \"" + eventsAPIEventsV1 + "\" " +
+			"or \"" + eventsAPIEventsV1beta1 + "\" (Kubernetes before 1.19).",
+		Value: eventsAPICore,
+	}
+
+	bufferWriteRetriesFlag = cli.IntFlag{
+		Name:    "buffer_write_retries",
+		EnvVars: []string{"BUFFER_WRITE_RETRIES"},
+		Usage:   "Number of primary storage write retries after failure.",
+		Value:   3,
+	}
+
+	bufferRetryDelayFlag = cli.DurationFlag{
+		Name:    "buffer_retry_delay",
+		EnvVars: []string{"BUFFER_RETRY_DELAY"},
+		Usage:   "Delay before first primary storage write retry, doubled for next retries.",
+		Value:   time.Second,
+	}
+
 	connectTimeoutFlag = cli.DurationFlag{
 		Name:    "connection_timeout",
 		EnvVars: []string{"CONNECTION_TIMEOUT"},
@@ -130,9 +183,14 @@ func setupKubeClient(ctx *cli.Context) (*Kube, error) {
 	if err != nil {
 		return nil, err
 	}
+	eventsV1, err := eventsv1.NewRESTClient(config)
+	if err != nil {
+		return nil, err
+	}
 
 	return &Kube{
 		Clientset: kubecli,
+		eventsV1:  eventsV1,
 		config:    config,
 	}, nil
 }
@@ -146,24 +204,82 @@ func (ml *MongoLogrusAdapter) Output(callDepth int, s string) error {
 	return nil
 }
 
-func setupMongo(ctx *cli.Context) (*mongodb.Storage, error) {
+func parseRetentionRules(rules []string) ([]mongodb.RetentionRule, error) {
+	ret := make([]mongodb.RetentionRule, 0, len(rules))
+	for _, ruleStr := range rules {
+		selector, durationStr := ruleStr, ""
+		if i := strings.LastIndex(ruleStr, "="); i >= 0 {
+			selector, durationStr = ruleStr[:i], ruleStr[i+1:]
+		}
+		expireAfter, err := time.ParseDuration(durationStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retention rule %q: %v", ruleStr, err)
+		}
+		var rule mongodb.RetentionRule
+		rule.ExpireAfter = expireAfter
+		parts := strings.SplitN(selector, "/", 2)
+		if parts[0] != "*" {
+			rule.Collection = parts[0]
+		}
+		if len(parts) > 1 {
+			switch kind := kubeClientModel.EventKind(parts[1]); kind {
+			case kubeClientModel.EventError, kubeClientModel.EventWarning, kubeClientModel.EventInfo:
+				rule.Kind = kind
+			default:
+				rule.Name = parts[1]
+			}
+		}
+		ret = append(ret, rule)
+	}
+	return ret, nil
+}
+
+func mongoConfig(ctx *cli.Context) (*mgo.DialInfo, mongodb.Retention, error) {
+	retentionRules, err := parseRetentionRules(ctx.StringSlice(retentionRulesFlag.Name))
+	if err != nil {
+		return nil, mongodb.Retention{}, err
+	}
 	mgo.SetDebug(ctx.Bool(debugFlag.Name))
 	mgo.SetLogger(&MongoLogrusAdapter{Log: log.WithField("component", "mgo")})
-	return mongodb.OpenConnection(&mgo.DialInfo{
+	return &mgo.DialInfo{
 		Addrs:     ctx.StringSlice(mongoAddressFlag.Name),
 		Database:  ctx.String(mongoDatabaseFlag.Name),
 		Mechanism: "SCRAM-SHA-1",
 		Username:  ctx.String(mongoUserFlag.Name),
 		Password:  ctx.String(mongoPasswordFlag.Name),
-	})
+	}, mongodb.Retention{
+		Default:     ctx.Duration(retentionFlag.Name),
+		Rules:       retentionRules,
+		SweepPeriod: ctx.Duration(retentionSweepPeriodFlag.Name),
+	}, nil
 }
 
-func setupBuffer(ctx *cli.Context, inserter storage.EventBulkInserter, collector <-chan kubeClientModel.Event) *storage.RecordBuffer {
+func setupMongo(ctx *cli.Context) (*mongodb.Storage, error) {
+	dialInfo, retention, err := mongoConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mongodb.OpenConnection(dialInfo, retention)
+}
+
+func setupBuffer(ctx *cli.Context, inserter storage.EventBulkInserter, upsert storage.UpsertFunc,
+	onWrite func(collection string, records int, err error), collector <-chan kubeClientModel.Event) *storage.RecordBuffer {
 	return storage.NewRecordBuffer(storage.RecordBufferConfig{
 		Storage:         inserter,
 		BufferCap:       ctx.Int(bufferCapacityFlag.Name),
 		InsertPeriod:    ctx.Duration(bufferFlushPeriodFlag.Name),
 		MinInsertEvents: ctx.Int(bufferMinInsertEventsFlag.Name),
 		Collector:       collector,
+		Upsert:          upsert,
+		WriteRetries:    ctx.Int(bufferWriteRetriesFlag.Name),
+		RetryDelay:      ctx.Duration(bufferRetryDelayFlag.Name),
+		OnWrite:         onWrite,
 	})
 }
+
+// flushBuffers writes records buffered for collections.
+func flushBuffers(buffers map[string]*storage.RecordBuffer) {
+	for collection, buffer := range buffers {
+		buffer.Flush(collection)
+	}
+}
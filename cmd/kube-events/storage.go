@@ -0,0 +1,911 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/cloudevents"
+	"github.com/containerum/kube-events/pkg/httpapi"
+	"github.com/containerum/kube-events/pkg/storage"
+	"github.com/containerum/kube-events/pkg/storage/elasticsearch"
+	"github.com/containerum/kube-events/pkg/storage/jsonl"
+	"github.com/containerum/kube-events/pkg/storage/loki"
+	"github.com/containerum/kube-events/pkg/storage/mongodb"
+	"github.com/containerum/kube-events/pkg/storage/nats"
+	"github.com/containerum/kube-events/pkg/storage/redis"
+	"github.com/containerum/kube-events/pkg/storage/syslog"
+	"github.com/containerum/kube-events/pkg/storage/webhook"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/urfave/cli.v2"
+)
+
+// Storage types
+const (
+	storageMongo         = "mongo"
+	storageWebhook       = "webhook"
+	storageElasticsearch = "elasticsearch"
+	storageLoki          = "loki"
+	storageCloudEvents   = "cloudevents"
+	storageSyslog        = "syslog"
+	storageJSONL         = "jsonl"
+	storageNATS          = "nats"
+	storageRedis         = "redis"
+)
+
+var (
+	storageFlag = cli.StringSliceFlag{
+		Name:    "storage",
+		EnvVars: []string{"STORAGE"},
+		Usage: "Storages to write records to: \"" + storageMongo + "\", \"" + storageWebhook + "\", " +
+			"\"" + storageElasticsearch + "\", \"" + storageLoki + "\", \"" + storageCloudEvents + "\", " +
+			"\"" + storageSyslog + "\", \"" + storageJSONL + "\", \"" + storageNATS + "\", \"" + storageRedis + "\".",
+		Value: cli.NewStringSlice(storageMongo),
+	}
+
+	primaryStorageFlag = cli.StringSliceFlag{
+		Name:    "primary_storage",
+		EnvVars: []string{"PRIMARY_STORAGE"},
+		Usage: "Storages written synchronously, their failures are retried by record buffer. " +
+			"Other storages are written from own queues. First selected storage is primary if not specified.",
+	}
+
+	storageRoutesFlag = cli.StringSliceFlag{
+		Name:    "storage_route",
+		EnvVars: []string{"STORAGE_ROUTES"},
+		Usage: "Records written to storage in format \"<storage>=<collection,... or *>[/<event kind,...>]\", " +
+			"i.e. \"webhook=events,system/error,warning\". Storage receives all records if not specified.",
+	}
+
+	storageQueueSizeFlag = cli.IntFlag{
+		Name:    "storage_queue_size",
+		EnvVars: []string{"STORAGE_QUEUE_SIZE"},
+		Usage:   "Number of batches queued for secondary storage. Batches are dropped if queue is full.",
+		Value:   100,
+	}
+
+	storageWriteRetriesFlag = cli.IntFlag{
+		Name:    "storage_write_retries",
+		EnvVars: []string{"STORAGE_WRITE_RETRIES"},
+		Usage:   "Number of secondary storage write retries after failure.",
+		Value:   3,
+	}
+
+	storageRetryDelayFlag = cli.DurationFlag{
+		Name:    "storage_retry_delay",
+		EnvVars: []string{"STORAGE_RETRY_DELAY"},
+		Usage:   "Delay before first secondary storage write retry, doubled for next retries.",
+		Value:   time.Second,
+	}
+
+	webhookURLFlag = cli.StringFlag{
+		Name:    "webhook_url",
+		EnvVars: []string{"WEBHOOK_URL"},
+		Usage:   "Webhook storage URL for all collections.",
+	}
+
+	webhookCollectionURLFlag = cli.StringSliceFlag{
+		Name:    "webhook_collection_url",
+		EnvVars: []string{"WEBHOOK_COLLECTION_URLS"},
+		Usage:   "Webhook storage URL for collection in format \"<collection>=<url>\".",
+	}
+
+	webhookFormatFlag = cli.StringFlag{
+		Name:    "webhook_format",
+		EnvVars: []string{"WEBHOOK_FORMAT"},
+		Usage:   "Webhook storage payload format: \"" + webhook.FormatJSON + "\" or \"" + webhook.FormatNDJSON + "\".",
+		Value:   webhook.FormatJSON,
+	}
+
+	webhookSecretFlag = cli.StringFlag{
+		Name:    "webhook_secret",
+		EnvVars: []string{"WEBHOOK_SECRET"},
+		Usage:   "Key of webhook payload HMAC-SHA256 signature. Payload is not signed if empty.",
+	}
+
+	webhookHeadersFlag = cli.StringSliceFlag{
+		Name:    "webhook_header",
+		EnvVars: []string{"WEBHOOK_HEADERS"},
+		Usage:   "Additional webhook request header in format \"<name>: <value>\".",
+	}
+
+	webhookTimeoutFlag = cli.DurationFlag{
+		Name:    "webhook_timeout",
+		EnvVars: []string{"WEBHOOK_TIMEOUT"},
+		Usage:   "Webhook request timeout.",
+		Value:   10 * time.Second,
+	}
+
+	webhookMaxAttemptsFlag = cli.IntFlag{
+		Name:    "webhook_max_attempts",
+		EnvVars: []string{"WEBHOOK_MAX_ATTEMPTS"},
+		Usage:   "Number of webhook request attempts for one batch. Batch is not retried again by record buffer.",
+		Value:   3,
+	}
+
+	webhookRetryDelayFlag = cli.DurationFlag{
+		Name:    "webhook_retry_delay",
+		EnvVars: []string{"WEBHOOK_RETRY_DELAY"},
+		Usage:   "Delay before first webhook retry if Retry-After is not returned. Delay is doubled after every attempt.",
+		Value:   time.Second,
+	}
+
+	webhookMaxRetryDelayFlag = cli.DurationFlag{
+		Name:    "webhook_max_retry_delay",
+		EnvVars: []string{"WEBHOOK_MAX_RETRY_DELAY"},
+		Usage:   "Maximum delay between webhook retries. Longer Retry-After is shortened to it.",
+		Value:   30 * time.Second,
+	}
+
+	webhookRetryStatusFlag = cli.StringSliceFlag{
+		Name:    "webhook_retry_status",
+		EnvVars: []string{"WEBHOOK_RETRY_STATUSES"},
+		Usage:   "Webhook response status code (\"503\") or class (\"5xx\") which is retried.",
+		Value:   cli.NewStringSlice("5xx", "429"),
+	}
+
+	elasticsearchURLFlag = cli.StringSliceFlag{
+		Name:    "elasticsearch_url",
+		EnvVars: []string{"ELASTICSEARCH_URLS"},
+		Usage:   "Elasticsearch or OpenSearch node URL.",
+	}
+
+	elasticsearchIndexPrefixFlag = cli.StringFlag{
+		Name:    "elasticsearch_index_prefix",
+		EnvVars: []string{"ELASTICSEARCH_INDEX_PREFIX"},
+		Usage:   "Elasticsearch index name prefix. Index name is \"<prefix>-<collection>-<date>\".",
+		Value:   "kube-events",
+	}
+
+	elasticsearchIndexDateFormatFlag = cli.StringFlag{
+		Name:    "elasticsearch_index_date_format",
+		EnvVars: []string{"ELASTICSEARCH_INDEX_DATE_FORMAT"},
+		Usage:   "Elasticsearch index date suffix format (Go time layout). Date suffix is omitted if empty and for upserted records.",
+		Value:   "2006.01.02",
+	}
+
+	elasticsearchUserFlag = cli.StringFlag{
+		Name:    "elasticsearch_login",
+		EnvVars: []string{"ELASTICSEARCH_LOGIN"},
+		Usage:   "Username for Elasticsearch basic auth.",
+	}
+
+	elasticsearchPasswordFlag = cli.StringFlag{
+		Name:    "elasticsearch_password",
+		EnvVars: []string{"ELASTICSEARCH_PASSWORD"},
+		Usage:   "Password for Elasticsearch basic auth.",
+	}
+
+	elasticsearchAPIKeyFlag = cli.StringFlag{
+		Name:    "elasticsearch_api_key",
+		EnvVars: []string{"ELASTICSEARCH_API_KEY"},
+		Usage:   "Base64 encoded Elasticsearch API key. It is used instead of basic auth if specified.",
+	}
+
+	elasticsearchTimeoutFlag = cli.DurationFlag{
+		Name:    "elasticsearch_timeout",
+		EnvVars: []string{"ELASTICSEARCH_TIMEOUT"},
+		Usage:   "Elasticsearch request timeout.",
+		Value:   30 * time.Second,
+	}
+
+	elasticsearchMaxAttemptsFlag = cli.IntFlag{
+		Name:    "elasticsearch_max_attempts",
+		EnvVars: []string{"ELASTICSEARCH_MAX_ATTEMPTS"},
+		Usage:   "Number of attempts of sending documents failed with 429 or 5xx status.",
+		Value:   3,
+	}
+
+	elasticsearchRetryDelayFlag = cli.DurationFlag{
+		Name:    "elasticsearch_retry_delay",
+		EnvVars: []string{"ELASTICSEARCH_RETRY_DELAY"},
+		Usage:   "Delay before first Elasticsearch retry, doubled for next retries.",
+		Value:   time.Second,
+	}
+
+	lokiURLFlag = cli.StringFlag{
+		Name:    "loki_url",
+		EnvVars: []string{"LOKI_URL"},
+		Usage:   "Loki push API URL, i.e. \"http://loki:3100/loki/api/v1/push\".",
+	}
+
+	lokiFormatFlag = cli.StringFlag{
+		Name:    "loki_format",
+		EnvVars: []string{"LOKI_FORMAT"},
+		Usage:   "Loki push request format: \"" + loki.FormatProtobuf + "\" or \"" + loki.FormatJSON + "\".",
+		Value:   loki.FormatProtobuf,
+	}
+
+	lokiTenantIDFlag = cli.StringFlag{
+		Name:    "loki_tenant_id",
+		EnvVars: []string{"LOKI_TENANT_ID"},
+		Usage:   "Loki tenant ID sent in X-Scope-OrgID header.",
+	}
+
+	lokiUserFlag = cli.StringFlag{
+		Name:    "loki_login",
+		EnvVars: []string{"LOKI_LOGIN"},
+		Usage:   "Username for Loki basic auth.",
+	}
+
+	lokiPasswordFlag = cli.StringFlag{
+		Name:    "loki_password",
+		EnvVars: []string{"LOKI_PASSWORD"},
+		Usage:   "Password for Loki basic auth.",
+	}
+
+	lokiLabelsFlag = cli.StringSliceFlag{
+		Name:    "loki_label",
+		EnvVars: []string{"LOKI_LABELS"},
+		Usage:   "Static label added to all Loki streams in format \"<name>=<value>\", i.e. \"cluster=prod\".",
+	}
+
+	lokiTimeoutFlag = cli.DurationFlag{
+		Name:    "loki_timeout",
+		EnvVars: []string{"LOKI_TIMEOUT"},
+		Usage:   "Loki push request timeout.",
+		Value:   10 * time.Second,
+	}
+
+	lokiMaxAttemptsFlag = cli.IntFlag{
+		Name:    "loki_max_attempts",
+		EnvVars: []string{"LOKI_MAX_ATTEMPTS"},
+		Usage:   "Number of attempts of Loki push failed with 429 or 5xx status.",
+		Value:   3,
+	}
+
+	lokiRetryDelayFlag = cli.DurationFlag{
+		Name:    "loki_retry_delay",
+		EnvVars: []string{"LOKI_RETRY_DELAY"},
+		Usage:   "Delay before first Loki push retry, doubled for next retries.",
+		Value:   time.Second,
+	}
+
+	cloudEventsURLFlag = cli.StringFlag{
+		Name:    "cloudevents_url",
+		EnvVars: []string{"CLOUDEVENTS_URL"},
+		Usage:   "URL receiving records as CloudEvents.",
+	}
+
+	cloudEventsModeFlag = cli.StringFlag{
+		Name:    "cloudevents_mode",
+		EnvVars: []string{"CLOUDEVENTS_MODE"},
+		Usage: "CloudEvents HTTP content mode: \"" + cloudevents.ModeStructured + "\", \"" + cloudevents.ModeBinary + "\" " +
+			"or \"" + cloudevents.ModeBatch + "\".",
+		Value: cloudevents.ModeBatch,
+	}
+
+	cloudEventsClusterFlag = cli.StringFlag{
+		Name:    "cloudevents_cluster",
+		EnvVars: []string{"CLOUDEVENTS_CLUSTER"},
+		Usage:   "Cluster name used in CloudEvents source.",
+	}
+
+	cloudEventsTypePrefixFlag = cli.StringFlag{
+		Name:    "cloudevents_type_prefix",
+		EnvVars: []string{"CLOUDEVENTS_TYPE_PREFIX"},
+		Usage:   "Prefix of CloudEvents type.",
+		Value:   cloudevents.DefaultTypePrefix,
+	}
+
+	cloudEventsHeadersFlag = cli.StringSliceFlag{
+		Name:    "cloudevents_header",
+		EnvVars: []string{"CLOUDEVENTS_HEADERS"},
+		Usage:   "Additional CloudEvents request header in format \"<name>: <value>\".",
+	}
+
+	cloudEventsTimeoutFlag = cli.DurationFlag{
+		Name:    "cloudevents_timeout",
+		EnvVars: []string{"CLOUDEVENTS_TIMEOUT"},
+		Usage:   "CloudEvents request timeout.",
+		Value:   10 * time.Second,
+	}
+
+	cloudEventsMaxAttemptsFlag = cli.IntFlag{
+		Name:    "cloudevents_max_attempts",
+		EnvVars: []string{"CLOUDEVENTS_MAX_ATTEMPTS"},
+		Usage:   "Number of attempts of CloudEvents request failed with 429 or 5xx status.",
+		Value:   3,
+	}
+
+	cloudEventsRetryDelayFlag = cli.DurationFlag{
+		Name:    "cloudevents_retry_delay",
+		EnvVars: []string{"CLOUDEVENTS_RETRY_DELAY"},
+		Usage:   "Delay before first CloudEvents request retry, doubled for next retries.",
+		Value:   time.Second,
+	}
+
+	syslogNetworkFlag = cli.StringFlag{
+		Name:    "syslog_network",
+		EnvVars: []string{"SYSLOG_NETWORK"},
+		Usage:   "Syslog transport: \"" + syslog.NetworkUDP + "\", \"" + syslog.NetworkTCP + "\" or \"" + syslog.NetworkTLS + "\".",
+		Value:   syslog.NetworkUDP,
+	}
+
+	syslogAddrFlag = cli.StringFlag{
+		Name:    "syslog_addr",
+		EnvVars: []string{"SYSLOG_ADDR"},
+		Usage:   "Syslog server address.",
+	}
+
+	syslogFacilityFlag = cli.IntFlag{
+		Name:    "syslog_facility",
+		EnvVars: []string{"SYSLOG_FACILITY"},
+		Usage:   "Syslog facility code, i.e. 1 (user) or 16 (local0).",
+		Value:   1,
+	}
+
+	syslogHostnameFlag = cli.StringFlag{
+		Name:    "syslog_hostname",
+		EnvVars: []string{"SYSLOG_HOSTNAME"},
+		Usage:   "Syslog message hostname. Host name is used if not specified.",
+	}
+
+	syslogAppNameFlag = cli.StringFlag{
+		Name:    "syslog_app_name",
+		EnvVars: []string{"SYSLOG_APP_NAME"},
+		Usage:   "Syslog message application name.",
+		Value:   "kube-events",
+	}
+
+	syslogQueueSizeFlag = cli.IntFlag{
+		Name:    "syslog_queue_size",
+		EnvVars: []string{"SYSLOG_QUEUE_SIZE"},
+		Usage:   "Number of syslog messages queued while server is unavailable. Oldest messages are dropped if queue is full.",
+		Value:   10000,
+	}
+
+	syslogTLSCAFlag = cli.StringFlag{
+		Name:    "syslog_tls_ca",
+		EnvVars: []string{"SYSLOG_TLS_CA"},
+		Usage:   "CA certificates file for syslog TLS connection. System CAs are used if not specified.",
+	}
+
+	syslogTLSInsecureFlag = cli.BoolFlag{
+		Name:    "syslog_tls_insecure",
+		EnvVars: []string{"SYSLOG_TLS_INSECURE"},
+		Usage:   "Do not verify syslog server certificate.",
+	}
+
+	syslogReconnectDelayFlag = cli.DurationFlag{
+		Name:    "syslog_reconnect_delay",
+		EnvVars: []string{"SYSLOG_RECONNECT_DELAY"},
+		Usage:   "Delay between syslog reconnection attempts.",
+		Value:   5 * time.Second,
+	}
+
+	syslogTimeoutFlag = cli.DurationFlag{
+		Name:    "syslog_timeout",
+		EnvVars: []string{"SYSLOG_TIMEOUT"},
+		Usage:   "Syslog connection and write timeout.",
+		Value:   5 * time.Second,
+	}
+
+	jsonlPathFlag = cli.StringFlag{
+		Name:    "jsonl_path",
+		EnvVars: []string{"JSONL_PATH"},
+		Usage:   "JSON lines storage file path. Records are written to stdout if \"" + jsonl.Stdout + "\".",
+		Value:   jsonl.Stdout,
+	}
+
+	jsonlMaxSizeFlag = cli.Int64Flag{
+		Name:    "jsonl_max_size",
+		EnvVars: []string{"JSONL_MAX_SIZE"},
+		Usage:   "JSON lines file size in bytes which triggers rotation. File is not rotated by size if 0.",
+		Value:   100 << 20,
+	}
+
+	jsonlMaxAgeFlag = cli.DurationFlag{
+		Name:    "jsonl_max_age",
+		EnvVars: []string{"JSONL_MAX_AGE"},
+		Usage:   "JSON lines file age which triggers rotation. File is not rotated by time if 0.",
+	}
+
+	jsonlMaxBackupsFlag = cli.IntFlag{
+		Name:    "jsonl_max_backups",
+		EnvVars: []string{"JSONL_MAX_BACKUPS"},
+		Usage:   "Number of kept rotated JSON lines files. All files are kept if 0.",
+		Value:   5,
+	}
+
+	jsonlCompressFlag = cli.BoolFlag{
+		Name:    "jsonl_compress",
+		EnvVars: []string{"JSONL_COMPRESS"},
+		Usage:   "Compress rotated JSON lines files with gzip.",
+	}
+
+	jsonlFsyncFlag = cli.StringFlag{
+		Name:    "jsonl_fsync",
+		EnvVars: []string{"JSONL_FSYNC"},
+		Usage: "JSON lines file sync mode: \"" + jsonl.FsyncNone + "\", \"" + jsonl.FsyncBatch + "\" (after every batch) " +
+			"or \"" + jsonl.FsyncInterval + "\" (every jsonl_fsync_interval).",
+		Value: jsonl.FsyncNone,
+	}
+
+	jsonlFsyncIntervalFlag = cli.DurationFlag{
+		Name:    "jsonl_fsync_interval",
+		EnvVars: []string{"JSONL_FSYNC_INTERVAL"},
+		Usage:   "JSON lines file sync interval in \"" + jsonl.FsyncInterval + "\" sync mode.",
+		Value:   time.Second,
+	}
+
+	natsURLFlag = cli.StringSliceFlag{
+		Name:    "nats_url",
+		EnvVars: []string{"NATS_URLS"},
+		Usage:   "NATS server URL, i.e. \"nats://nats:4222\". Servers are used in turn after connection failures.",
+	}
+
+	natsSubjectPrefixFlag = cli.StringFlag{
+		Name:    "nats_subject_prefix",
+		EnvVars: []string{"NATS_SUBJECT_PREFIX"},
+		Usage:   "NATS subject prefix. Subject is \"<prefix>.<collection>.<namespace>.<event kind>\".",
+		Value:   nats.DefaultSubjectPrefix,
+	}
+
+	natsJetStreamFlag = cli.BoolFlag{
+		Name:    "nats_jetstream",
+		EnvVars: []string{"NATS_JETSTREAM"},
+		Usage:   "Wait for JetStream acks. Stream for subjects must exist.",
+	}
+
+	natsUserFlag = cli.StringFlag{
+		Name:    "nats_login",
+		EnvVars: []string{"NATS_LOGIN"},
+		Usage:   "NATS username.",
+	}
+
+	natsPasswordFlag = cli.StringFlag{
+		Name:    "nats_password",
+		EnvVars: []string{"NATS_PASSWORD"},
+		Usage:   "NATS password.",
+	}
+
+	natsTokenFlag = cli.StringFlag{
+		Name:    "nats_token",
+		EnvVars: []string{"NATS_TOKEN"},
+		Usage:   "NATS authentication token.",
+	}
+
+	natsTLSCAFlag = cli.StringFlag{
+		Name:    "nats_tls_ca",
+		EnvVars: []string{"NATS_TLS_CA"},
+		Usage:   "CA certificates file for NATS TLS connection. TLS is enabled if specified.",
+	}
+
+	natsTimeoutFlag = cli.DurationFlag{
+		Name:    "nats_timeout",
+		EnvVars: []string{"NATS_TIMEOUT"},
+		Usage:   "NATS connection and JetStream ack timeout.",
+		Value:   5 * time.Second,
+	}
+
+	natsMaxAttemptsFlag = cli.IntFlag{
+		Name:    "nats_max_attempts",
+		EnvVars: []string{"NATS_MAX_ATTEMPTS"},
+		Usage:   "Number of attempts to publish records. Only not acknowledged records are published again.",
+		Value:   3,
+	}
+
+	natsRetryDelayFlag = cli.DurationFlag{
+		Name:    "nats_retry_delay",
+		EnvVars: []string{"NATS_RETRY_DELAY"},
+		Usage:   "Delay before first NATS publish retry, doubled for next retries.",
+		Value:   time.Second,
+	}
+
+	redisAddrFlag = cli.StringFlag{
+		Name:    "redis_addr",
+		EnvVars: []string{"REDIS_ADDR"},
+		Usage:   "Redis server address.",
+		Value:   "redis:6379",
+	}
+
+	redisUserFlag = cli.StringFlag{
+		Name:    "redis_login",
+		EnvVars: []string{"REDIS_LOGIN"},
+		Usage:   "Redis ACL username. Password only authentication is used if not specified.",
+	}
+
+	redisPasswordFlag = cli.StringFlag{
+		Name:    "redis_password",
+		EnvVars: []string{"REDIS_PASSWORD"},
+		Usage:   "Redis password.",
+	}
+
+	redisDBFlag = cli.IntFlag{
+		Name:    "redis_db",
+		EnvVars: []string{"REDIS_DB"},
+		Usage:   "Redis database number.",
+	}
+
+	redisTLSCAFlag = cli.StringFlag{
+		Name:    "redis_tls_ca",
+		EnvVars: []string{"REDIS_TLS_CA"},
+		Usage:   "CA certificates file for Redis TLS connection. TLS is enabled if specified.",
+	}
+
+	redisKeyPrefixFlag = cli.StringFlag{
+		Name:    "redis_key_prefix",
+		EnvVars: []string{"REDIS_KEY_PREFIX"},
+		Usage:   "Redis stream key prefix. Stream key is \"<prefix><collection>\".",
+		Value:   redis.DefaultKeyPrefix,
+	}
+
+	redisMaxLenFlag = cli.Int64Flag{
+		Name:    "redis_max_len",
+		EnvVars: []string{"REDIS_MAX_LEN"},
+		Usage:   "Maximum Redis stream length, older entries are trimmed. Stream is not trimmed if 0.",
+		Value:   100000,
+	}
+
+	redisExactTrimFlag = cli.BoolFlag{
+		Name:    "redis_exact_trim",
+		EnvVars: []string{"REDIS_EXACT_TRIM"},
+		Usage:   "Trim Redis streams exactly to redis_max_len. Approximate trimming is used by default as it is much cheaper.",
+	}
+
+	redisTimeoutFlag = cli.DurationFlag{
+		Name:    "redis_timeout",
+		EnvVars: []string{"REDIS_TIMEOUT"},
+		Usage:   "Redis connection and pipeline timeout.",
+		Value:   5 * time.Second,
+	}
+
+	redisMaxAttemptsFlag = cli.IntFlag{
+		Name:    "redis_max_attempts",
+		EnvVars: []string{"REDIS_MAX_ATTEMPTS"},
+		Usage:   "Number of attempts to add records to Redis. Only records failed with connection or temporary errors are added again.",
+		Value:   3,
+	}
+
+	redisRetryDelayFlag = cli.DurationFlag{
+		Name:    "redis_retry_delay",
+		EnvVars: []string{"REDIS_RETRY_DELAY"},
+		Usage:   "Delay before first Redis retry, doubled for next retries.",
+		Value:   time.Second,
+	}
+)
+
+// parseKeyValues parses "<key><sep><value>" pairs.
+func parseKeyValues(pairs []string, sep string) (map[string]string, error) {
+	ret := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, sep, 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid value %q, expected \"<key>%s<value>\"", pair, sep)
+		}
+		ret[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return ret, nil
+}
+
+func setupWebhook(ctx *cli.Context) (*webhook.Sink, error) {
+	collectionURLs, err := parseKeyValues(ctx.StringSlice(webhookCollectionURLFlag.Name), "=")
+	if err != nil {
+		return nil, err
+	}
+	headers, err := parseKeyValues(ctx.StringSlice(webhookHeadersFlag.Name), ":")
+	if err != nil {
+		return nil, err
+	}
+	return webhook.NewSink(webhook.Config{
+		URL:            ctx.String(webhookURLFlag.Name),
+		CollectionURLs: collectionURLs,
+		Format:         ctx.String(webhookFormatFlag.Name),
+		Secret:         ctx.String(webhookSecretFlag.Name),
+		Headers:        headers,
+		Timeout:        ctx.Duration(webhookTimeoutFlag.Name),
+		MaxAttempts:    ctx.Int(webhookMaxAttemptsFlag.Name),
+		RetryDelay:     ctx.Duration(webhookRetryDelayFlag.Name),
+		MaxRetryDelay:  ctx.Duration(webhookMaxRetryDelayFlag.Name),
+		RetryStatuses:  ctx.StringSlice(webhookRetryStatusFlag.Name),
+	})
+}
+
+// setupElasticsearch returns sink which updates index template before the first write if lazy is set,
+// otherwise template is updated now.
+func setupElasticsearch(ctx *cli.Context, lazy bool) (*elasticsearch.Sink, error) {
+	sink, err := elasticsearch.NewSink(elasticsearch.Config{
+		URLs:            ctx.StringSlice(elasticsearchURLFlag.Name),
+		IndexPrefix:     ctx.String(elasticsearchIndexPrefixFlag.Name),
+		IndexDateFormat: ctx.String(elasticsearchIndexDateFormatFlag.Name),
+		Username:        ctx.String(elasticsearchUserFlag.Name),
+		Password:        ctx.String(elasticsearchPasswordFlag.Name),
+		APIKey:          ctx.String(elasticsearchAPIKeyFlag.Name),
+		Timeout:         ctx.Duration(elasticsearchTimeoutFlag.Name),
+		MaxAttempts:     ctx.Int(elasticsearchMaxAttemptsFlag.Name),
+		RetryDelay:      ctx.Duration(elasticsearchRetryDelayFlag.Name),
+	})
+	if err != nil || lazy {
+		return sink, err
+	}
+	return sink, sink.EnsureTemplate()
+}
+
+func setupLoki(ctx *cli.Context) (*loki.Sink, error) {
+	labels, err := parseKeyValues(ctx.StringSlice(lokiLabelsFlag.Name), "=")
+	if err != nil {
+		return nil, err
+	}
+	return loki.NewSink(loki.Config{
+		URL:         ctx.String(lokiURLFlag.Name),
+		Format:      ctx.String(lokiFormatFlag.Name),
+		TenantID:    ctx.String(lokiTenantIDFlag.Name),
+		Username:    ctx.String(lokiUserFlag.Name),
+		Password:    ctx.String(lokiPasswordFlag.Name),
+		Labels:      labels,
+		Timeout:     ctx.Duration(lokiTimeoutFlag.Name),
+		MaxAttempts: ctx.Int(lokiMaxAttemptsFlag.Name),
+		RetryDelay:  ctx.Duration(lokiRetryDelayFlag.Name),
+	})
+}
+
+func setupCloudEvents(ctx *cli.Context) (*cloudevents.HTTPSink, error) {
+	headers, err := parseKeyValues(ctx.StringSlice(cloudEventsHeadersFlag.Name), ":")
+	if err != nil {
+		return nil, err
+	}
+	return cloudevents.NewHTTPSink(cloudevents.HTTPSinkConfig{
+		URL:  ctx.String(cloudEventsURLFlag.Name),
+		Mode: ctx.String(cloudEventsModeFlag.Name),
+		Encoder: cloudevents.Encoder{
+			Cluster:    ctx.String(cloudEventsClusterFlag.Name),
+			TypePrefix: ctx.String(cloudEventsTypePrefixFlag.Name),
+		},
+		Headers:     headers,
+		Timeout:     ctx.Duration(cloudEventsTimeoutFlag.Name),
+		MaxAttempts: ctx.Int(cloudEventsMaxAttemptsFlag.Name),
+		RetryDelay:  ctx.Duration(cloudEventsRetryDelayFlag.Name),
+	})
+}
+
+// makeTLSConfig returns TLS config trusting CA certificates from file. System CAs are trusted if file is not specified.
+func makeTLSConfig(caFile string, insecure bool) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: insecure,
+	}
+	if caFile != "" {
+		ca, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+	}
+	return tlsConfig, nil
+}
+
+func setupSyslog(ctx *cli.Context) (*syslog.Sink, error) {
+	tlsConfig, err := makeTLSConfig(ctx.String(syslogTLSCAFlag.Name), ctx.Bool(syslogTLSInsecureFlag.Name))
+	if err != nil {
+		return nil, err
+	}
+	return syslog.NewSink(syslog.Config{
+		Network:        ctx.String(syslogNetworkFlag.Name),
+		Addr:           ctx.String(syslogAddrFlag.Name),
+		TLS:            tlsConfig,
+		Facility:       ctx.Int(syslogFacilityFlag.Name),
+		Hostname:       ctx.String(syslogHostnameFlag.Name),
+		AppName:        ctx.String(syslogAppNameFlag.Name),
+		QueueSize:      ctx.Int(syslogQueueSizeFlag.Name),
+		DialTimeout:    ctx.Duration(syslogTimeoutFlag.Name),
+		WriteTimeout:   ctx.Duration(syslogTimeoutFlag.Name),
+		ReconnectDelay: ctx.Duration(syslogReconnectDelayFlag.Name),
+	})
+}
+
+func setupJSONL(ctx *cli.Context) (*jsonl.Sink, error) {
+	return jsonl.NewSink(jsonl.Config{
+		Path:          ctx.String(jsonlPathFlag.Name),
+		MaxSize:       ctx.Int64(jsonlMaxSizeFlag.Name),
+		MaxAge:        ctx.Duration(jsonlMaxAgeFlag.Name),
+		MaxBackups:    ctx.Int(jsonlMaxBackupsFlag.Name),
+		Compress:      ctx.Bool(jsonlCompressFlag.Name),
+		Fsync:         ctx.String(jsonlFsyncFlag.Name),
+		FsyncInterval: ctx.Duration(jsonlFsyncIntervalFlag.Name),
+	})
+}
+
+func setupNATS(ctx *cli.Context, lazy bool) (*nats.Sink, error) {
+	var tlsConfig *tls.Config
+	if caFile := ctx.String(natsTLSCAFlag.Name); caFile != "" {
+		var err error
+		if tlsConfig, err = makeTLSConfig(caFile, false); err != nil {
+			return nil, err
+		}
+	}
+	return nats.NewSink(nats.Config{
+		URLs:          ctx.StringSlice(natsURLFlag.Name),
+		SubjectPrefix: ctx.String(natsSubjectPrefixFlag.Name),
+		JetStream:     ctx.Bool(natsJetStreamFlag.Name),
+		Username:      ctx.String(natsUserFlag.Name),
+		Password:      ctx.String(natsPasswordFlag.Name),
+		Token:         ctx.String(natsTokenFlag.Name),
+		TLS:           tlsConfig,
+		Timeout:       ctx.Duration(natsTimeoutFlag.Name),
+		LazyConnect:   lazy,
+		MaxAttempts:   ctx.Int(natsMaxAttemptsFlag.Name),
+		RetryDelay:    ctx.Duration(natsRetryDelayFlag.Name),
+	})
+}
+
+func setupRedis(ctx *cli.Context, lazy bool) (*redis.Sink, error) {
+	var tlsConfig *tls.Config
+	if caFile := ctx.String(redisTLSCAFlag.Name); caFile != "" {
+		var err error
+		if tlsConfig, err = makeTLSConfig(caFile, false); err != nil {
+			return nil, err
+		}
+	}
+	return redis.NewSink(redis.Config{
+		Addr:        ctx.String(redisAddrFlag.Name),
+		Username:    ctx.String(redisUserFlag.Name),
+		Password:    ctx.String(redisPasswordFlag.Name),
+		DB:          ctx.Int(redisDBFlag.Name),
+		TLS:         tlsConfig,
+		KeyPrefix:   ctx.String(redisKeyPrefixFlag.Name),
+		MaxLen:      ctx.Int64(redisMaxLenFlag.Name),
+		ApproxTrim:  !ctx.Bool(redisExactTrimFlag.Name),
+		Timeout:     ctx.Duration(redisTimeoutFlag.Name),
+		LazyConnect: lazy,
+		MaxAttempts: ctx.Int(redisMaxAttemptsFlag.Name),
+		RetryDelay:  ctx.Duration(redisRetryDelayFlag.Name),
+	})
+}
+
+type storageRoute struct {
+	collections []string
+	kinds       []kubeClientModel.EventKind
+}
+
+func parseStorageRoutes(routes []string) (map[string]storageRoute, error) {
+	pairs, err := parseKeyValues(routes, "=")
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]storageRoute, len(pairs))
+	for storageType, routeStr := range pairs {
+		var route storageRoute
+		parts := strings.SplitN(routeStr, "/", 2)
+		if parts[0] != "*" && parts[0] != "" {
+			route.collections = strings.Split(parts[0], ",")
+		}
+		if len(parts) > 1 {
+			for _, kind := range strings.Split(parts[1], ",") {
+				switch kind := kubeClientModel.EventKind(kind); kind {
+				case kubeClientModel.EventError, kubeClientModel.EventWarning, kubeClientModel.EventInfo:
+					route.kinds = append(route.kinds, kind)
+				default:
+					return nil, fmt.Errorf("invalid storage route %q: unknown event kind %q", routeStr, kind)
+				}
+			}
+		}
+		ret[storageType] = route
+	}
+	return ret, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// setupStorage opens selected storages. Returned Mongo storage is nil if Mongo is not selected.
+// onDrop (if not nil) is called when batch is dropped by secondary storage.
+// Secondary storages connect on the first write, so unavailable secondary storage does not block startup,
+// it is reported unhealthy after failed writes.
+func setupStorage(ctx *cli.Context, onDrop func(sink, collection string, records int)) (_ *storage.FanOut, mongoStorage *mongodb.Storage, err error) {
+	routes, err := parseStorageRoutes(ctx.StringSlice(storageRoutesFlag.Name))
+	if err != nil {
+		return nil, nil, err
+	}
+	storageTypes := ctx.StringSlice(storageFlag.Name)
+	if len(storageTypes) == 0 {
+		return nil, nil, fmt.Errorf("no storage selected")
+	}
+	primary := ctx.StringSlice(primaryStorageFlag.Name)
+	if len(primary) == 0 {
+		primary = storageTypes[:1]
+	}
+
+	var sinks []storage.FanOutSink
+	defer func() {
+		if err != nil {
+			closeSinks(sinks)
+		}
+	}()
+	for _, storageType := range storageTypes {
+		var inserter storage.EventBulkInserter
+		isPrimary := containsString(primary, storageType)
+		switch storageType {
+		case storageMongo:
+			if mongoStorage, err = setupMongo(ctx); err != nil {
+				return nil, nil, err
+			}
+			inserter = mongoStorage
+		case storageWebhook:
+			if inserter, err = setupWebhook(ctx); err != nil {
+				return nil, nil, err
+			}
+		case storageElasticsearch:
+			if inserter, err = setupElasticsearch(ctx, !isPrimary); err != nil {
+				return nil, nil, err
+			}
+		case storageLoki:
+			if inserter, err = setupLoki(ctx); err != nil {
+				return nil, nil, err
+			}
+		case storageCloudEvents:
+			if inserter, err = setupCloudEvents(ctx); err != nil {
+				return nil, nil, err
+			}
+		case storageSyslog:
+			if inserter, err = setupSyslog(ctx); err != nil {
+				return nil, nil, err
+			}
+		case storageJSONL:
+			if inserter, err = setupJSONL(ctx); err != nil {
+				return nil, nil, err
+			}
+		case storageNATS:
+			if inserter, err = setupNATS(ctx, !isPrimary); err != nil {
+				return nil, nil, err
+			}
+		case storageRedis:
+			if inserter, err = setupRedis(ctx, !isPrimary); err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, fmt.Errorf("unknown storage %q", storageType)
+		}
+		route := routes[storageType]
+		sink := storage.FanOutSink{
+			Name:         storageType,
+			Storage:      inserter,
+			Primary:      isPrimary,
+			Collections:  route.collections,
+			Kinds:        route.kinds,
+			QueueSize:    ctx.Int(storageQueueSizeFlag.Name),
+			WriteRetries: ctx.Int(storageWriteRetriesFlag.Name),
+			RetryDelay:   ctx.Duration(storageRetryDelayFlag.Name),
+			OnDrop:       onDrop,
+		}
+		if sink.Primary {
+			sink.WriteRetries = ctx.Int(bufferWriteRetriesFlag.Name)
+			sink.RetryDelay = ctx.Duration(bufferRetryDelayFlag.Name)
+		}
+		sinks = append(sinks, sink)
+		log.WithFields(log.Fields{
+			"storage": storageType,
+			"primary": isPrimary,
+		}).Info("Storage is enabled")
+	}
+	return storage.NewFanOut(sinks), mongoStorage, nil
+}
+
+// closeSinks closes storages which implement io.Closer.
+func closeSinks(sinks []storage.FanOutSink) {
+	for _, sink := range sinks {
+		if closer, ok := sink.Storage.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				log.WithError(err).WithField("storage", sink.Name).Error("Unable to close storage")
+			}
+		}
+	}
+}
+
+// HandleStorageHealth enables storages health API.
+func (s *apiServer) HandleStorageHealth(fanOut *storage.FanOut) {
+	s.mux.Handle("/storage/health", httpapi.NewSinkHealthHandler(fanOut.Health))
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"time"
+
+	"github.com/containerum/kube-events/pkg/httpapi"
+	"github.com/containerum/kube-events/pkg/stream"
+	"github.com/containerum/kube-events/pkg/subscription"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/urfave/cli.v2"
+)
+
+var (
+	subscriptionWorkersFlag = cli.IntFlag{
+		Name:    "subscription_workers",
+		EnvVars: []string{"SUBSCRIPTION_WORKERS"},
+		Usage:   "Number of concurrent webhook deliveries of tenant subscriptions.",
+		Value:   8,
+	}
+
+	subscriptionQueueFlag = cli.IntFlag{
+		Name:    "subscription_queue",
+		EnvVars: []string{"SUBSCRIPTION_QUEUE"},
+		Usage:   "Number of queued webhook deliveries. Deliveries are logged as failed if queue is full.",
+		Value:   1000,
+	}
+
+	subscriptionMaxAttemptsFlag = cli.IntFlag{
+		Name:    "subscription_max_attempts",
+		EnvVars: []string{"SUBSCRIPTION_MAX_ATTEMPTS"},
+		Usage:   "Number of webhook delivery attempts.",
+		Value:   5,
+	}
+
+	subscriptionRetryDelayFlag = cli.DurationFlag{
+		Name:    "subscription_retry_delay",
+		EnvVars: []string{"SUBSCRIPTION_RETRY_DELAY"},
+		Usage:   "Delay before first webhook delivery retry. Delay is doubled after every attempt.",
+		Value:   time.Second,
+	}
+
+	subscriptionMaxRetryDelayFlag = cli.DurationFlag{
+		Name:    "subscription_max_retry_delay",
+		EnvVars: []string{"SUBSCRIPTION_MAX_RETRY_DELAY"},
+		Usage:   "Maximum delay between webhook delivery retries.",
+		Value:   time.Minute,
+	}
+
+	subscriptionTimeoutFlag = cli.DurationFlag{
+		Name:    "subscription_timeout",
+		EnvVars: []string{"SUBSCRIPTION_TIMEOUT"},
+		Usage:   "Webhook request timeout.",
+		Value:   10 * time.Second,
+	}
+
+	subscriptionAllowPrivateTargetsFlag = cli.BoolFlag{
+		Name:    "subscription_allow_private_targets",
+		EnvVars: []string{"SUBSCRIPTION_ALLOW_PRIVATE_TARGETS"},
+		Usage:   "Allow webhooks to loopback, link-local and cluster addresses. Tenants can reach internal services if set.",
+	}
+
+	subscriptionRefreshPeriodFlag = cli.DurationFlag{
+		Name:    "subscription_refresh_period",
+		EnvVars: []string{"SUBSCRIPTION_REFRESH_PERIOD"},
+		Usage:   "Period of reloading subscriptions changed by other instances.",
+		Value:   time.Minute,
+	}
+)
+
+// setupSubscriptions starts dispatcher of tenant subscriptions fed by transformed records.
+func setupSubscriptions(ctx *cli.Context, store subscription.Store, hub *stream.Hub, stop <-chan struct{}) *subscription.Dispatcher {
+	dispatcher := subscription.NewDispatcher(subscription.DispatcherConfig{
+		Store:         store,
+		Workers:       ctx.Int(subscriptionWorkersFlag.Name),
+		QueueSize:     ctx.Int(subscriptionQueueFlag.Name),
+		MaxAttempts:   ctx.Int(subscriptionMaxAttemptsFlag.Name),
+		RetryDelay:    ctx.Duration(subscriptionRetryDelayFlag.Name),
+		MaxRetryDelay: ctx.Duration(subscriptionMaxRetryDelayFlag.Name),
+		Timeout:       ctx.Duration(subscriptionTimeoutFlag.Name),
+		RefreshPeriod: ctx.Duration(subscriptionRefreshPeriodFlag.Name),
+
+		AllowPrivateTargets: ctx.Bool(subscriptionAllowPrivateTargetsFlag.Name),
+	})
+	go dispatcher.Run(hub, stop)
+	return dispatcher
+}
+
+// HandleSubscriptions enables tenant subscriptions API if API tokens are specified.
+// Tenant is taken from user headers, so API is available only to gateway which holds API token.
+func (s *apiServer) HandleSubscriptions(ctx *cli.Context, store subscription.Store, dispatcher *subscription.Dispatcher) {
+	if len(s.tokens) == 0 {
+		log.Warn("Subscriptions API is disabled: it requires API token of gateway which sets user headers")
+		return
+	}
+	handler := httpapi.NewSubscriptionHandler(httpapi.SubscriptionHandlerConfig{
+		Store:               store,
+		Tokens:              s.tokens,
+		AllowPrivateTargets: ctx.Bool(subscriptionAllowPrivateTargetsFlag.Name),
+		OnChange: func() {
+			go func() {
+				if err := dispatcher.Refresh(); err != nil {
+					log.WithError(err).Error("Unable to refresh subscriptions")
+				}
+			}()
+		},
+	})
+	s.mux.Handle("/subscriptions", handler)
+	s.mux.Handle("/subscriptions/", handler)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/storage"
+	"github.com/containerum/kube-events/pkg/storage/mongodb"
+	log "github.com/sirupsen/logrus"
+)
+
+// kube-events lifecycle and connectivity event names
+const (
+	KubeEventsStarted    = "KubeEventsStarted"
+	KubeEventsStopped    = "KubeEventsStopped"
+	APIServerUnreachable = "APIServerUnreachable"
+	APIServerRecovered   = "APIServerRecovered"
+	StorageDegraded      = "StorageDegraded"
+	StorageRecovered     = "StorageRecovered"
+	InformerRelisted     = "InformerRelisted"
+	EventsDropped        = "EventsDropped"
+	LeaderElected        = "LeaderElected"
+	LeaderLost           = "LeaderLost"
+)
+
+const informerRelistDebounce = time.Minute
+
+// SystemRecorder makes system records about kube-events itself.
+// Records are passed to system collection buffer without blocking callers and dropped if buffer is not read.
+type SystemRecorder struct {
+	events chan kubeClientModel.Event
+	host   string
+
+	mu              sync.Mutex
+	degraded        map[string]bool
+	relisted        map[string]time.Time
+	kubeUnreachable time.Time
+
+	log *log.Entry
+}
+
+func NewSystemRecorder(queueSize int) *SystemRecorder {
+	host, _ := os.Hostname()
+	return &SystemRecorder{
+		events:   make(chan kubeClientModel.Event, queueSize),
+		host:     host,
+		degraded: make(map[string]bool),
+		relisted: make(map[string]time.Time),
+		log:      log.WithField("component", "system_recorder"),
+	}
+}
+
+// Events returns channel which should be collected to system collection.
+func (sr *SystemRecorder) Events() chan kubeClientModel.Event {
+	return sr.events
+}
+
+func (sr *SystemRecorder) makeRecord(kind kubeClientModel.EventKind, name, message string, details map[string]string) kubeClientModel.Event {
+	if details == nil {
+		details = map[string]string{}
+	}
+	details["host"] = sr.host
+	return kubeClientModel.Event{
+		Time:         time.Now().Format(time.RFC3339),
+		Kind:         kind,
+		Name:         name,
+		ResourceType: kubeClientModel.TypeSystem,
+		ResourceName: "kube-events",
+		Message:      message,
+		Details:      details,
+	}
+}
+
+func (sr *SystemRecorder) Record(kind kubeClientModel.EventKind, name, message string, details map[string]string) {
+	record := sr.makeRecord(kind, name, message, details)
+	select {
+	case sr.events <- record:
+	default:
+		sr.log.WithField("event_name", name).Error("System records queue is full, record dropped")
+	}
+}
+
+// RecordNow writes record to storage immediately. It is used when buffers will not be flushed (i.e. on shutdown).
+func (sr *SystemRecorder) RecordNow(inserter storage.EventBulkInserter, kind kubeClientModel.EventKind, name, message string, details map[string]string) {
+	record := sr.makeRecord(kind, name, message, details)
+	record.DateAdded = time.Now()
+	if err := inserter.BulkInsert([]kubeClientModel.Event{record}, mongodb.SystemCollection); err != nil {
+		sr.log.WithError(err).WithField("event_name", name).Error("Unable to write system record")
+	}
+}
+
+// OnWrite records storage state changes. It is used as storage.RecordBufferConfig.OnWrite.
+func (sr *SystemRecorder) OnWrite(collection string, records int, err error) {
+	sr.mu.Lock()
+	wasDegraded := sr.degraded[collection]
+	sr.degraded[collection] = err != nil
+	sr.mu.Unlock()
+
+	details := map[string]string{"collection": collection}
+	switch {
+	case err != nil && !wasDegraded:
+		sr.Record(kubeClientModel.EventError, StorageDegraded, err.Error(), details)
+	case err == nil && wasDegraded:
+		sr.Record(kubeClientModel.EventInfo, StorageRecovered, "", details)
+	}
+}
+
+// OnDrop records batches dropped by storage because its queue is full. It is used as storage.FanOutSink.OnDrop.
+func (sr *SystemRecorder) OnDrop(sink, collection string, records int) {
+	// dropped system records would produce new records on every drop
+	if collection == mongodb.SystemCollection {
+		return
+	}
+	details := map[string]string{
+		"storage":    sink,
+		"collection": collection,
+		"count":      strconv.Itoa(records),
+	}
+	sr.Record(kubeClientModel.EventWarning, EventsDropped,
+		fmt.Sprintf("%d records were not written to %s: queue is full", records, sink), details)
+}
+
+// OnRelist records informer relists, but not more often than once per informerRelistDebounce for resource,
+// because relist re-delivers every object.
+func (sr *SystemRecorder) OnRelist(resource string) {
+	sr.mu.Lock()
+	last := sr.relisted[resource]
+	now := time.Now()
+	if now.Sub(last) < informerRelistDebounce {
+		sr.mu.Unlock()
+		return
+	}
+	sr.relisted[resource] = now
+	sr.mu.Unlock()
+	sr.Record(kubeClientModel.EventWarning, InformerRelisted, resource+" informer relisted resources, changes may be missed",
+		map[string]string{"resource": resource})
+}
+
+// OnKubePing records API server connectivity changes and returns duration of API server unavailability.
+func (sr *SystemRecorder) OnKubePing(err error) time.Duration {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	switch {
+	case err != nil && sr.kubeUnreachable.IsZero():
+		sr.kubeUnreachable = time.Now()
+		sr.Record(kubeClientModel.EventError, APIServerUnreachable, err.Error(), nil)
+	case err == nil && !sr.kubeUnreachable.IsZero():
+		sr.Record(kubeClientModel.EventInfo, APIServerRecovered, "",
+			map[string]string{"downtime": time.Since(sr.kubeUnreachable).String()})
+		sr.kubeUnreachable = time.Time{}
+	}
+	if sr.kubeUnreachable.IsZero() {
+		return 0
+	}
+	return time.Since(sr.kubeUnreachable)
+}
@@ -6,9 +6,11 @@ import (
 	"time"
 
 	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/eventsv1"
 	"github.com/containerum/kube-events/pkg/model"
 	apiApps "k8s.io/api/apps/v1"
 	apiCore "k8s.io/api/core/v1"
+	apiEvents "k8s.io/api/events/v1beta1"
 	apiExtensions "k8s.io/api/extensions/v1beta1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
@@ -32,8 +34,8 @@ func ObservableTypeFromObject(object runtime.Object) model.ObservableResource {
 		return model.ObservableConfigMap
 	case *apiCore.Node:
 		return model.ObservableNode
-	case *apiCore.Event:
-		event := object.(*apiCore.Event)
+	case *apiCore.Event, *apiEvents.Event, *eventsv1.Event:
+		event, _ := kubeEventFromObject(object)
 		switch event.InvolvedObject.Kind {
 		case "Pod", "PersistentVolumeClaim", "Node":
 			return model.ObservableEvent
@@ -94,15 +96,17 @@ func MakeDeployRecord(event watch.Event) kubeClientModel.Event {
 }
 
 func MakeEventRecord(event watch.Event) kubeClientModel.Event {
-	kubeEvent := event.Object.(*apiCore.Event)
+	kubeEvent, _ := kubeEventFromObject(event.Object)
+	_, firstSeen, _ := eventSeries(kubeEvent)
 	ret := kubeClientModel.Event{
-		Time:              kubeEvent.FirstTimestamp.Time.Format(time.RFC3339),
+		Time:              firstSeen.Format(time.RFC3339),
 		ResourceName:      kubeEvent.InvolvedObject.Name,
 		ResourceUID:       string(kubeEvent.UID),
 		ResourceNamespace: kubeEvent.Namespace,
 		Message:           kubeEvent.Message,
 		Details:           map[string]string{},
 	}
+	eventSeriesDetails(kubeEvent, ret.Details)
 
 	switch kubeEvent.InvolvedObject.Kind {
 	case "Pod":
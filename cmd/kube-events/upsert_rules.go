@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/storage"
+)
+
+// EventUpsert identifies kubernetes events by UID, so series updates (count and last-seen bumps) replace recorded event.
+// Occurrences are taken from series count, so the same series update observed again is not counted twice.
+// Series are written to typed fields instead of details, so they can be queried.
+func EventUpsert(record kubeClientModel.Event) storage.Upsert {
+	firstSeen, err := time.Parse(time.RFC3339, record.Details[detailsFirstSeen])
+	if err != nil {
+		firstSeen = record.DateAdded
+	}
+	lastSeen, err := time.Parse(time.RFC3339, record.Details[detailsLastSeen])
+	if err != nil {
+		lastSeen = record.DateAdded
+	}
+	count, err := strconv.Atoi(record.Details[detailsCount])
+	if err != nil || count < 1 {
+		count = 1
+	}
+	return storage.Upsert{
+		Key: map[string]interface{}{
+			"resourceuid": record.ResourceUID,
+		},
+		Set: withoutSeriesDetails(record),
+		// series updates don't move record in time and don't extend its retention
+		SetOnInsert: map[string]interface{}{
+			"dateadded": record.DateAdded,
+			"firstseen": firstSeen,
+		},
+		Max: map[string]interface{}{
+			"occurrences": count,
+			"lastseen":    lastSeen,
+		},
+	}
+}
+
+func withoutSeriesDetails(record kubeClientModel.Event) kubeClientModel.Event {
+	details := make(map[string]string, len(record.Details))
+	for k, v := range record.Details {
+		switch k {
+		case detailsCount, detailsFirstSeen, detailsLastSeen:
+		default:
+			details[k] = v
+		}
+	}
+	record.Details = details
+	if len(details) == 0 {
+		record.Details = nil
+	}
+	return record
+}
+
+// ResourceUpsert identifies ResourceCreated records by event name and resource UID,
+// so objects listed again by informer do not duplicate them. Other records are inserted.
+func ResourceUpsert(record kubeClientModel.Event) storage.Upsert {
+	if record.Name != kubeClientModel.ResourceCreated || record.ResourceUID == "" {
+		return storage.Upsert{Set: record}
+	}
+	return storage.Upsert{
+		Key: map[string]interface{}{
+			"eventname":   record.Name,
+			"resourceuid": record.ResourceUID,
+		},
+		Set: record,
+		SetOnInsert: map[string]interface{}{
+			"dateadded": record.DateAdded,
+		},
+		Inc: map[string]int{
+			"occurrences": 1,
+		},
+		Max: map[string]interface{}{
+			"lastmodified": record.DateAdded,
+		},
+	}
+}
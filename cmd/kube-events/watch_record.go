@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/containerum/kube-events/pkg/storage"
+	"github.com/containerum/kube-events/pkg/watchrecord"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/urfave/cli.v2"
+)
+
+var (
+	recordWatchFlag = cli.StringFlag{
+		Name:    "record_watch",
+		EnvVars: []string{"RECORD_WATCH"},
+		Usage:   "Record raw watch events to file before filtering, so they can be replayed with --replay_watch.",
+	}
+
+	replayWatchFlag = cli.StringFlag{
+		Name:    "replay_watch",
+		EnvVars: []string{"REPLAY_WATCH"},
+		Usage: "Replay watch events recorded with --record_watch instead of watching Kubernetes. " +
+			"Program exits when all replayed records are written.",
+	}
+
+	replayRealtimeFlag = cli.BoolFlag{
+		Name:    "replay_realtime",
+		EnvVars: []string{"REPLAY_REALTIME"},
+		Usage:   "Replay watch events with original intervals instead of as fast as possible.",
+	}
+)
+
+// setupWatchRecorder returns recorder or nil if recording is disabled.
+func setupWatchRecorder(ctx *cli.Context) (*watchrecord.Recorder, error) {
+	path := ctx.String(recordWatchFlag.Name)
+	if path == "" {
+		return nil, nil
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open watch recording: %v", err)
+	}
+	log.WithField("file", path).Info("Recording watch events")
+	return watchrecord.NewRecorder(file), nil
+}
+
+func setupReplay(ctx *cli.Context) (*watchrecord.Replay, error) {
+	in, closeInput, err := openInput(ctx.String(replayWatchFlag.Name))
+	if err != nil {
+		return nil, fmt.Errorf("unable to open watch recording: %v", err)
+	}
+	defer closeInput()
+	return watchrecord.NewReplay(in, ctx.Bool(replayRealtimeFlag.Name))
+}
+
+// flushReplayed waits until buffers collect all replayed records and writes them.
+func flushReplayed(buffers map[string]*storage.RecordBuffer) {
+	for _, buffer := range buffers {
+		<-buffer.Drained()
+	}
+	flushBuffers(buffers)
+}
@@ -0,0 +1,39 @@
+package alert
+
+import (
+	"io/ioutil"
+
+	"github.com/ghodss/yaml"
+)
+
+// Config describes alert rules and notifiers.
+//
+//	notifiers:
+//	  - name: ops
+//	    type: slack
+//	    url: https://hooks.slack.com/services/...
+//	rules:
+//	  - name: image-pull-backoff
+//	    match:
+//	      - field: event_name
+//	        in: [BackOff]
+//	    threshold: 5
+//	    window: 10m
+//	    group_by: [resource_namespace]
+//	    silence: 1h
+//	    notifiers: [ops]
+type Config struct {
+	Notifiers []NotifierConfig `json:"notifiers"`
+	Rules     []Rule           `json:"rules"`
+}
+
+// LoadConfig reads config from YAML or JSON file.
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	err = yaml.Unmarshal(data, &cfg)
+	return cfg, err
+}
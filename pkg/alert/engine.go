@@ -0,0 +1,224 @@
+package alert
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/stream"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxAlertEvents limits number of events attached to alert.
+const maxAlertEvents = 10
+
+// Alert is sent to notifiers when rule fires.
+type Alert struct {
+	Rule      string                  `json:"rule"`
+	Group     map[string]string       `json:"group,omitempty"`
+	Count     int                     `json:"count"`
+	Window    Duration                `json:"window"`
+	FirstSeen time.Time               `json:"first_seen"`
+	LastSeen  time.Time               `json:"last_seen"`
+	Events    []kubeClientModel.Event `json:"events"`
+}
+
+// Summary returns one line description of alert. Group fields are sorted by name.
+func (a Alert) Summary() string {
+	summary := fmt.Sprintf("[%s] %d events", a.Rule, a.Count)
+	if a.Window > 0 {
+		summary += fmt.Sprintf(" in %v", time.Duration(a.Window))
+	}
+	fields := make([]string, 0, len(a.Group))
+	for field := range a.Group {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		summary += fmt.Sprintf(", %s=%s", field, a.Group[field])
+	}
+	return summary
+}
+
+type group struct {
+	values        map[string]string
+	seen          []time.Time
+	events        []kubeClientModel.Event
+	silencedUntil time.Time
+}
+
+// Engine evaluates rules for every record and sends fired alerts to notifiers.
+type Engine struct {
+	rules     []Rule
+	notifiers map[string]Notifier
+	groups    []map[string]*group
+
+	queue chan notification
+	log   *log.Entry
+}
+
+type notification struct {
+	notifier string
+	alert    Alert
+}
+
+// NewEngine checks rules and notifiers. Notifications are sent by workers, so slow notifiers do not block records processing.
+func NewEngine(cfg Config, workers int) (*Engine, error) {
+	notifiers := make(map[string]Notifier, len(cfg.Notifiers))
+	for _, notifierCfg := range cfg.Notifiers {
+		notifier, err := NewNotifier(notifierCfg)
+		if err != nil {
+			return nil, err
+		}
+		notifiers[notifierCfg.Name] = notifier
+	}
+	for i := range cfg.Rules {
+		if err := cfg.Rules[i].compile(); err != nil {
+			return nil, err
+		}
+		for _, notifier := range cfg.Rules[i].Notifiers {
+			if _, ok := notifiers[notifier]; !ok {
+				return nil, fmt.Errorf("rule %q: unknown notifier %q", cfg.Rules[i].Name, notifier)
+			}
+		}
+	}
+	engine := &Engine{
+		rules:     cfg.Rules,
+		notifiers: notifiers,
+		groups:    make([]map[string]*group, len(cfg.Rules)),
+		queue:     make(chan notification, 100),
+		log:       log.WithField("component", "alert_engine"),
+	}
+	for i := range engine.groups {
+		engine.groups[i] = make(map[string]*group)
+	}
+	for i := 0; i < workers; i++ {
+		go engine.notifyWorker()
+	}
+	return engine, nil
+}
+
+func (e *Engine) notifyWorker() {
+	for n := range e.queue {
+		if err := e.notifiers[n.notifier].Notify(n.alert); err != nil {
+			e.log.WithError(err).WithFields(log.Fields{
+				"rule":     n.alert.Rule,
+				"notifier": n.notifier,
+			}).Error("Notification failed")
+		}
+	}
+}
+
+// Process evaluates rules for record.
+func (e *Engine) Process(record stream.Record) {
+	now := record.Received
+	if now.IsZero() {
+		now = time.Now()
+	}
+	for i := range e.rules {
+		rule := &e.rules[i]
+		if !rule.Match(record) {
+			continue
+		}
+		key, values := rule.groupKey(record)
+		g, ok := e.groups[i][key]
+		if !ok {
+			g = &group{values: values}
+			e.groups[i][key] = g
+		}
+
+		g.seen = append(g.seen, now)
+		g.events = append(g.events, record.Event)
+		if len(g.events) > maxAlertEvents {
+			g.events = g.events[len(g.events)-maxAlertEvents:]
+		}
+		e.expire(rule, g, now)
+
+		if len(g.seen) < rule.Threshold || now.Before(g.silencedUntil) {
+			continue
+		}
+		alert := Alert{
+			Rule:      rule.Name,
+			Group:     g.values,
+			Count:     len(g.seen),
+			Window:    rule.Window,
+			FirstSeen: g.seen[0],
+			LastSeen:  now,
+			Events:    append([]kubeClientModel.Event(nil), g.events...),
+		}
+		g.silencedUntil = now.Add(time.Duration(rule.Silence))
+		g.seen = nil
+		g.events = nil
+		e.fire(rule, alert)
+	}
+}
+
+// expire removes records out of rule window.
+func (e *Engine) expire(rule *Rule, g *group, now time.Time) {
+	if rule.Window <= 0 {
+		return
+	}
+	from := now.Add(-time.Duration(rule.Window))
+	expired := 0
+	for expired < len(g.seen) && g.seen[expired].Before(from) {
+		expired++
+	}
+	g.seen = g.seen[expired:]
+	if len(g.events) > len(g.seen) {
+		g.events = g.events[len(g.events)-len(g.seen):]
+	}
+}
+
+func (e *Engine) fire(rule *Rule, alert Alert) {
+	e.log.WithField("rule", rule.Name).Info(alert.Summary())
+	for _, notifier := range rule.Notifiers {
+		select {
+		case e.queue <- notification{notifier: notifier, alert: alert}:
+		default:
+			e.log.WithFields(log.Fields{
+				"rule":     rule.Name,
+				"notifier": notifier,
+			}).Error("Notification queue is full, alert dropped")
+		}
+	}
+}
+
+// cleanup removes groups without records in window and silence.
+func (e *Engine) cleanup(now time.Time) {
+	for i := range e.rules {
+		for key, g := range e.groups[i] {
+			e.expire(&e.rules[i], g, now)
+			if len(g.seen) == 0 && now.After(g.silencedUntil) {
+				delete(e.groups[i], key)
+			}
+		}
+	}
+}
+
+// Run processes records from hub until stop is closed.
+// Subscription is renewed if engine does not keep up with records.
+func (e *Engine) Run(hub *stream.Hub, filter stream.Filter, stop <-chan struct{}) {
+	cleanup := time.NewTicker(time.Minute)
+	defer cleanup.Stop()
+	for {
+		sub, _, _ := hub.Subscribe(filter, stream.Resume{})
+	readLoop:
+		for {
+			select {
+			case record, ok := <-sub.C():
+				if !ok {
+					e.log.Error("Alert engine is too slow, records were skipped")
+					break readLoop
+				}
+				e.Process(record)
+			case now := <-cleanup.C:
+				e.cleanup(now)
+			case <-stop:
+				sub.Cancel()
+				close(e.queue)
+				return
+			}
+		}
+	}
+}
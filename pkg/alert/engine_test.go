@@ -0,0 +1,141 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/containerum/kube-events/pkg/stream"
+	log "github.com/sirupsen/logrus"
+)
+
+type testNotifier struct{}
+
+func (testNotifier) Notify(alert Alert) error { return nil }
+
+// newTestEngine makes engine without workers, so fired alerts stay in queue.
+func newTestEngine(t *testing.T, rules ...Rule) *Engine {
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	engine := &Engine{
+		rules:     rules,
+		notifiers: map[string]Notifier{"test": testNotifier{}},
+		groups:    make([]map[string]*group, len(rules)),
+		queue:     make(chan notification, 100),
+		log:       log.WithField("component", "alert_engine"),
+	}
+	for i := range engine.groups {
+		engine.groups[i] = make(map[string]*group)
+	}
+	return engine
+}
+
+func fired(engine *Engine) []Alert {
+	var alerts []Alert
+	for {
+		select {
+		case n := <-engine.queue:
+			alerts = append(alerts, n.alert)
+		default:
+			return alerts
+		}
+	}
+}
+
+func receivedAt(record stream.Record, at time.Time) stream.Record {
+	record.Received = at
+	return record
+}
+
+func TestEngineThresholdAndWindow(t *testing.T) {
+	engine := newTestEngine(t, Rule{
+		Name:       "backoff",
+		Conditions: []Condition{{Field: "event_name", In: []string{"BackOff"}}},
+		Threshold:  3,
+		Window:     Duration(time.Minute),
+		Notifiers:  []string{"test"},
+	})
+	start := time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC)
+	engine.Process(receivedAt(testRecord("BackOff", "ns"), start))
+	engine.Process(receivedAt(testRecord("Failed", "ns"), start.Add(time.Second)))
+	engine.Process(receivedAt(testRecord("BackOff", "ns"), start.Add(2*time.Second)))
+	// first record is out of window
+	engine.Process(receivedAt(testRecord("BackOff", "ns"), start.Add(61*time.Second)))
+	if alerts := fired(engine); len(alerts) != 0 {
+		t.Fatalf("expected no alerts, got %v", alerts)
+	}
+
+	engine.Process(receivedAt(testRecord("BackOff", "ns"), start.Add(62*time.Second)))
+	alerts := fired(engine)
+	if len(alerts) != 1 {
+		t.Fatalf("expected 1 alert, got %d", len(alerts))
+	}
+	if alerts[0].Count != 3 || len(alerts[0].Events) != 3 {
+		t.Errorf("expected 3 events in alert, got count %d and %d events", alerts[0].Count, len(alerts[0].Events))
+	}
+	if !alerts[0].FirstSeen.Equal(start.Add(2 * time.Second)) {
+		t.Errorf("unexpected first seen %v", alerts[0].FirstSeen)
+	}
+}
+
+func TestEngineSilenceAndGroups(t *testing.T) {
+	engine := newTestEngine(t, Rule{
+		Name:      "any",
+		GroupBy:   []string{"resource_namespace"},
+		Silence:   Duration(time.Hour),
+		Notifiers: []string{"test"},
+	})
+	start := time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC)
+	engine.Process(receivedAt(testRecord("BackOff", "ns-1"), start))
+	engine.Process(receivedAt(testRecord("BackOff", "ns-1"), start.Add(time.Minute)))
+	engine.Process(receivedAt(testRecord("BackOff", "ns-2"), start.Add(time.Minute)))
+	engine.Process(receivedAt(testRecord("BackOff", "ns-1"), start.Add(time.Hour+time.Second)))
+
+	alerts := fired(engine)
+	if len(alerts) != 3 {
+		t.Fatalf("expected 3 alerts, got %d", len(alerts))
+	}
+	expected := []string{"ns-1", "ns-2", "ns-1"}
+	for i, alert := range alerts {
+		if alert.Group["resource_namespace"] != expected[i] {
+			t.Errorf("alert %d: expected group %s, got %v", i, expected[i], alert.Group)
+		}
+	}
+}
+
+func TestEngineCleanup(t *testing.T) {
+	engine := newTestEngine(t, Rule{
+		Name:      "window",
+		Threshold: 2,
+		Window:    Duration(time.Minute),
+		GroupBy:   []string{"resource_namespace"},
+		Notifiers: []string{"test"},
+	})
+	start := time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC)
+	engine.Process(receivedAt(testRecord("BackOff", "ns-1"), start))
+	engine.cleanup(start.Add(2 * time.Minute))
+	if len(engine.groups[0]) != 0 {
+		t.Errorf("expected expired group to be removed, got %d groups", len(engine.groups[0]))
+	}
+}
+
+func TestAlertSummary(t *testing.T) {
+	alert := Alert{
+		Rule:   "backoff",
+		Count:  5,
+		Window: Duration(10 * time.Minute),
+		Group: map[string]string{
+			"resource_namespace": "ns",
+			"details.node":       "node-1",
+			"event_name":         "BackOff",
+		},
+	}
+	expected := "[backoff] 5 events in 10m0s, details.node=node-1, event_name=BackOff, resource_namespace=ns"
+	for i := 0; i < 10; i++ {
+		if summary := alert.Summary(); summary != expected {
+			t.Fatalf("expected summary %q, got %q", expected, summary)
+		}
+	}
+}
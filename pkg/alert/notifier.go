@@ -0,0 +1,200 @@
+package alert
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+const (
+	NotifierWebhook = "webhook"
+	NotifierSlack   = "slack"
+	NotifierEmail   = "email"
+)
+
+type Notifier interface {
+	Notify(alert Alert) error
+}
+
+type NotifierConfig struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+
+	// webhook and slack
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Timeout Duration          `json:"timeout,omitempty"`
+
+	// email
+	SMTPAddr string   `json:"smtp_addr,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+}
+
+func NewNotifier(cfg NotifierConfig) (Notifier, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("notifier name is required")
+	}
+	timeout := time.Duration(cfg.Timeout)
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	switch cfg.Type {
+	case NotifierWebhook, NotifierSlack:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("notifier %q: url is required", cfg.Name)
+		}
+		return &WebhookNotifier{
+			URL:     cfg.URL,
+			Headers: cfg.Headers,
+			Slack:   cfg.Type == NotifierSlack,
+			Client:  &http.Client{Timeout: timeout},
+		}, nil
+	case NotifierEmail:
+		if cfg.SMTPAddr == "" || cfg.From == "" || len(cfg.To) == 0 {
+			return nil, fmt.Errorf("notifier %q: smtp_addr, from and to are required", cfg.Name)
+		}
+		return &EmailNotifier{
+			Addr:     cfg.SMTPAddr,
+			Username: cfg.Username,
+			Password: cfg.Password,
+			From:     cfg.From,
+			To:       cfg.To,
+			Timeout:  timeout,
+		}, nil
+	default:
+		return nil, fmt.Errorf("notifier %q: unknown type %q", cfg.Name, cfg.Type)
+	}
+}
+
+// WebhookNotifier posts alert as JSON. Slack-compatible webhooks receive message text.
+type WebhookNotifier struct {
+	URL     string
+	Headers map[string]string
+	Slack   bool
+	Client  *http.Client
+}
+
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+func alertText(alert Alert) string {
+	lines := []string{alert.Summary()}
+	for _, event := range alert.Events {
+		line := fmt.Sprintf("%s %s %s %s/%s", event.Time, event.Kind, event.Name, event.ResourceNamespace, event.ResourceName)
+		if event.Message != "" {
+			line += ": " + event.Message
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (n *WebhookNotifier) Notify(alert Alert) error {
+	var body interface{} = alert
+	if n.Slack {
+		body = slackMessage{Text: alertText(alert)}
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, n.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range n.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// headerReplacer removes line breaks from header values, so record fields can not inject headers.
+var headerReplacer = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+// EmailNotifier sends alert as plain text email.
+type EmailNotifier struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+	To       []string
+	// Timeout limits connecting to SMTP server and sending email, so hung server doesn't block notifier.
+	Timeout time.Duration
+}
+
+func (n *EmailNotifier) Notify(alert Alert) error {
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		n.From, strings.Join(n.To, ", "), headerReplacer.Replace(alert.Summary()), strings.Replace(alertText(alert), "\n", "\r\n", -1))
+
+	host := n.Addr
+	if h, _, err := net.SplitHostPort(n.Addr); err == nil {
+		host = h
+	}
+	// the same as smtp.SendMail, but with timeout
+	conn, err := net.DialTimeout("tcp", n.Addr, n.Timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if n.Timeout > 0 {
+		if err := conn.SetDeadline(time.Now().Add(n.Timeout)); err != nil {
+			return err
+		}
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if n.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.Username, n.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(n.From); err != nil {
+		return err
+	}
+	for _, to := range n.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,212 @@
+package alert
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+)
+
+func testAlert() Alert {
+	return Alert{
+		Rule:  "backoff",
+		Count: 1,
+		Group: map[string]string{"resource_namespace": "ns"},
+		Events: []kubeClientModel.Event{{
+			Kind:              kubeClientModel.EventWarning,
+			Name:              "BackOff",
+			ResourceNamespace: "ns",
+			ResourceName:      "pod",
+			Message:           "Back-off restarting failed container",
+		}},
+	}
+}
+
+func TestNewNotifierErrors(t *testing.T) {
+	configs := map[string]NotifierConfig{
+		"no name":         {Type: NotifierWebhook, URL: "http://localhost"},
+		"no url":          {Name: "n", Type: NotifierSlack},
+		"no smtp address": {Name: "n", Type: NotifierEmail, From: "a@example.com", To: []string{"b@example.com"}},
+		"unknown type":    {Name: "n", Type: "pager"},
+	}
+	for name, cfg := range configs {
+		if _, err := NewNotifier(cfg); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var received []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("unable to decode body: %v", err)
+		}
+		received = append(received, body)
+	}))
+	defer server.Close()
+
+	for _, notifierType := range []string{NotifierWebhook, NotifierSlack} {
+		notifier, err := NewNotifier(NotifierConfig{
+			Name:    notifierType,
+			Type:    notifierType,
+			URL:     server.URL,
+			Headers: map[string]string{"X-Token": "secret"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := notifier.Notify(testAlert()); err != nil {
+			t.Fatalf("%s: %v", notifierType, err)
+		}
+	}
+	if len(received) != 2 {
+		t.Fatalf("expected 2 notifications, got %d", len(received))
+	}
+	if received[0]["rule"] != "backoff" {
+		t.Errorf("unexpected webhook body %v", received[0])
+	}
+	text, _ := received[1]["text"].(string)
+	if !strings.HasPrefix(text, "[backoff] 1 events, resource_namespace=ns\n") || !strings.Contains(text, "Back-off restarting") {
+		t.Errorf("unexpected slack text %q", text)
+	}
+
+	notifier, _ := NewNotifier(NotifierConfig{Name: "n", Type: NotifierWebhook, URL: server.URL})
+	if err := notifier.Notify(testAlert()); err == nil {
+		t.Error("expected error on unauthorized response")
+	}
+}
+
+// serveSMTP accepts one SMTP session and sends received message data to messages.
+func serveSMTP(t *testing.T, listener net.Listener, messages chan<- string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+		case "EHLO", "HELO", "MAIL", "RCPT", "RSET", "NOOP":
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 Go ahead")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				t.Errorf("unable to read message: %v", err)
+				return
+			}
+			messages <- string(data)
+			text.PrintfLine("250 OK")
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Unknown command %s", cmd)
+		}
+	}
+}
+
+func TestEmailNotifierSubject(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	messages := make(chan string, 1)
+	go serveSMTP(t, listener, messages)
+
+	notifier, err := NewNotifier(NotifierConfig{
+		Name:     "email",
+		Type:     NotifierEmail,
+		SMTPAddr: listener.Addr().String(),
+		From:     "kube-events@example.com",
+		To:       []string{"ops@example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alert := testAlert()
+	alert.Group = map[string]string{"resource_name": "pod\r\nBcc: attacker@example.com"}
+	if err := notifier.Notify(alert); err != nil {
+		t.Fatal(err)
+	}
+
+	var message string
+	select {
+	case message = <-messages:
+	case <-time.After(5 * time.Second):
+		t.Fatal("message is not received")
+	}
+	header, err := textproto.NewReader(bufio.NewReader(strings.NewReader(message))).ReadMIMEHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bcc := header.Get("Bcc"); bcc != "" {
+		t.Errorf("header injected from group value: Bcc: %s", bcc)
+	}
+	expected := "[backoff] 1 events, resource_name=pod Bcc: attacker@example.com"
+	if subject := header.Get("Subject"); subject != expected {
+		t.Errorf("expected subject %q, got %q", expected, subject)
+	}
+}
+
+func TestEmailNotifierTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	// server accepts connections, but never greets
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	notifier, err := NewNotifier(NotifierConfig{
+		Name:     "email",
+		Type:     NotifierEmail,
+		SMTPAddr: listener.Addr().String(),
+		From:     "kube-events@example.com",
+		To:       []string{"ops@example.com"},
+		Timeout:  Duration(100 * time.Millisecond),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- notifier.Notify(testAlert())
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected timeout error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("notify is not limited by timeout")
+	}
+}
@@ -0,0 +1,146 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/containerum/kube-events/pkg/stream"
+)
+
+// Duration is a time.Duration which is unmarshalled from string like "5m".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(str)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Condition matches record field. Field is a JSON name of kubeClientModel.Event field, "collection" or "details.<key>".
+// Field matches if it equals to one of In values (if set) and matches Regexp (if set).
+type Condition struct {
+	Field  string   `json:"field"`
+	In     []string `json:"in,omitempty"`
+	Regexp string   `json:"regexp,omitempty"`
+
+	re *regexp.Regexp
+}
+
+func (c *Condition) compile() error {
+	if _, ok := fieldGetters[c.Field]; !ok && !strings.HasPrefix(c.Field, detailsPrefix) {
+		return fmt.Errorf("unknown field %q", c.Field)
+	}
+	if c.Regexp == "" {
+		return nil
+	}
+	re, err := regexp.Compile(c.Regexp)
+	if err != nil {
+		return err
+	}
+	c.re = re
+	return nil
+}
+
+func (c *Condition) Match(record stream.Record) bool {
+	value := FieldValue(record, c.Field)
+	if len(c.In) > 0 {
+		found := false
+		for _, v := range c.In {
+			found = found || v == value
+		}
+		if !found {
+			return false
+		}
+	}
+	return c.re == nil || c.re.MatchString(value)
+}
+
+// Rule fires alert when at least Threshold records matching all conditions are received during Window.
+// Records are counted separately for groups with different GroupBy field values.
+// After alert is fired, group is silenced for Silence duration.
+type Rule struct {
+	Name       string      `json:"name"`
+	Conditions []Condition `json:"match"`
+	Threshold  int         `json:"threshold,omitempty"`
+	Window     Duration    `json:"window,omitempty"`
+	GroupBy    []string    `json:"group_by,omitempty"`
+	Silence    Duration    `json:"silence,omitempty"`
+	Notifiers  []string    `json:"notifiers"`
+}
+
+func (r *Rule) compile() error {
+	if r.Name == "" {
+		return fmt.Errorf("rule name is required")
+	}
+	if r.Threshold < 1 {
+		r.Threshold = 1
+	}
+	for i := range r.Conditions {
+		if err := r.Conditions[i].compile(); err != nil {
+			return fmt.Errorf("rule %q: %v", r.Name, err)
+		}
+	}
+	for _, field := range r.GroupBy {
+		if _, ok := fieldGetters[field]; !ok && !strings.HasPrefix(field, detailsPrefix) {
+			return fmt.Errorf("rule %q: unknown group field %q", r.Name, field)
+		}
+	}
+	return nil
+}
+
+func (r *Rule) Match(record stream.Record) bool {
+	for i := range r.Conditions {
+		if !r.Conditions[i].Match(record) {
+			return false
+		}
+	}
+	return true
+}
+
+// groupKey returns values of GroupBy fields.
+func (r *Rule) groupKey(record stream.Record) (string, map[string]string) {
+	values := make(map[string]string, len(r.GroupBy))
+	parts := make([]string, 0, len(r.GroupBy))
+	for _, field := range r.GroupBy {
+		value := FieldValue(record, field)
+		values[field] = value
+		parts = append(parts, value)
+	}
+	return strings.Join(parts, "\x00"), values
+}
+
+const detailsPrefix = "details."
+
+var fieldGetters = map[string]func(record stream.Record) string{
+	"collection":         func(r stream.Record) string { return r.Collection },
+	"event_kind":         func(r stream.Record) string { return string(r.Event.Kind) },
+	"event_time":         func(r stream.Record) string { return r.Event.Time },
+	"event_name":         func(r stream.Record) string { return r.Event.Name },
+	"resource_type":      func(r stream.Record) string { return string(r.Event.ResourceType) },
+	"resource_name":      func(r stream.Record) string { return r.Event.ResourceName },
+	"resource_namespace": func(r stream.Record) string { return r.Event.ResourceNamespace },
+	"resource_uid":       func(r stream.Record) string { return r.Event.ResourceUID },
+	"message":            func(r stream.Record) string { return r.Event.Message },
+}
+
+// FieldValue returns value of record field by JSON name.
+func FieldValue(record stream.Record, field string) string {
+	if getter, ok := fieldGetters[field]; ok {
+		return getter(record)
+	}
+	return record.Event.Details[strings.TrimPrefix(field, detailsPrefix)]
+}
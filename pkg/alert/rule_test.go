@@ -0,0 +1,84 @@
+package alert
+
+import (
+	"testing"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/stream"
+)
+
+func testRecord(name, namespace string) stream.Record {
+	return stream.Record{
+		Collection: "events",
+		Event: kubeClientModel.Event{
+			Kind:              kubeClientModel.EventWarning,
+			Name:              name,
+			ResourceNamespace: namespace,
+			ResourceName:      "pod",
+			Details:           map[string]string{"node": "node-1"},
+		},
+	}
+}
+
+func TestConditionMatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition Condition
+		match     bool
+	}{
+		{"in", Condition{Field: "event_name", In: []string{"Failed", "BackOff"}}, true},
+		{"not in", Condition{Field: "event_name", In: []string{"Failed"}}, false},
+		{"regexp", Condition{Field: "resource_namespace", Regexp: "^prod-"}, true},
+		{"not regexp", Condition{Field: "resource_namespace", Regexp: "^dev-"}, false},
+		{"in and regexp", Condition{Field: "event_name", In: []string{"BackOff"}, Regexp: "Off$"}, true},
+		{"details", Condition{Field: "details.node", In: []string{"node-1"}}, true},
+		{"collection", Condition{Field: "collection", In: []string{"deployments"}}, false},
+	}
+	record := testRecord("BackOff", "prod-1")
+	for _, test := range tests {
+		if err := test.condition.compile(); err != nil {
+			t.Fatalf("%s: compile: %v", test.name, err)
+		}
+		if match := test.condition.Match(record); match != test.match {
+			t.Errorf("%s: expected match %v, got %v", test.name, test.match, match)
+		}
+	}
+}
+
+func TestRuleCompileErrors(t *testing.T) {
+	rules := map[string]Rule{
+		"no name":       {Conditions: []Condition{{Field: "event_name"}}},
+		"unknown field": {Name: "r", Conditions: []Condition{{Field: "unknown"}}},
+		"bad regexp":    {Name: "r", Conditions: []Condition{{Field: "message", Regexp: "("}}},
+		"unknown group": {Name: "r", GroupBy: []string{"unknown"}},
+	}
+	for name, rule := range rules {
+		if err := rule.compile(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	rule := Rule{Name: "r"}
+	if err := rule.compile(); err != nil {
+		t.Fatal(err)
+	}
+	if rule.Threshold != 1 {
+		t.Errorf("expected default threshold 1, got %d", rule.Threshold)
+	}
+}
+
+func TestRuleGroupKey(t *testing.T) {
+	rule := Rule{Name: "r", GroupBy: []string{"resource_namespace", "details.node"}}
+	key1, values := rule.groupKey(testRecord("BackOff", "ns-1"))
+	key2, _ := rule.groupKey(testRecord("Failed", "ns-1"))
+	key3, _ := rule.groupKey(testRecord("BackOff", "ns-2"))
+	if key1 != key2 {
+		t.Errorf("records of the same group have different keys %q and %q", key1, key2)
+	}
+	if key1 == key3 {
+		t.Errorf("records of different groups have the same key %q", key1)
+	}
+	if values["resource_namespace"] != "ns-1" || values["details.node"] != "node-1" {
+		t.Errorf("unexpected group values %v", values)
+	}
+}
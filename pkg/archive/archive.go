@@ -0,0 +1,315 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	manifestName = "manifest.json"
+	partition    = 24 * time.Hour
+	// restoreBatchSize is a number of records inserted at once by Restore.
+	restoreBatchSize = 500
+)
+
+// Source provides records for archiving.
+type Source interface {
+	IterateRecords(query storage.EventQuery, fn func(record kubeClientModel.Event) error) error
+	RetentionBounds(collection string) (shortest, longest time.Duration)
+}
+
+// Line is an archived record. Record fields are stored with time of adding to storage.
+type Line struct {
+	DateAdded time.Time `json:"date_added"`
+	kubeClientModel.Event
+}
+
+// File describes archived records added to storage in [From, To) time range.
+type File struct {
+	Key        string    `json:"key"`
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	Records    int       `json:"records"`
+	Size       int       `json:"size"`
+	SHA256     string    `json:"sha256"`
+	ArchivedAt time.Time `json:"archived_at"`
+}
+
+// Manifest lists archived files of collection. Records added before ArchivedUntil are archived.
+type Manifest struct {
+	Collection    string    `json:"collection"`
+	ArchivedUntil time.Time `json:"archived_until"`
+	Files         []File    `json:"files"`
+}
+
+type Config struct {
+	Source      Source
+	Target      Target
+	Collections []string
+	// LeadTime is a time before expiration when records are archived. It must be shorter than shortest retention.
+	LeadTime time.Duration
+	Period   time.Duration
+}
+
+// Archiver periodically exports records nearing expiration to gzipped NDJSON files partitioned by day of adding:
+//
+//	<collection>/date=2006-01-02/<collection>-2006-01-02.ndjson.gz
+//
+// Day is archived when its records with shortest collection retention expire in less than LeadTime.
+// Every collection has manifest "<collection>/manifest.json" which is updated after file is written.
+type Archiver struct {
+	cfg Config
+	log *log.Entry
+}
+
+func NewArchiver(cfg Config) (*Archiver, error) {
+	for _, collection := range cfg.Collections {
+		shortest, _ := cfg.Source.RetentionBounds(collection)
+		if cfg.LeadTime >= shortest {
+			return nil, fmt.Errorf("archive lead time %v must be shorter than shortest retention %v of %s", cfg.LeadTime, shortest, collection)
+		}
+	}
+	return &Archiver{
+		cfg: cfg,
+		log: log.WithField("component", "archiver"),
+	}, nil
+}
+
+func manifestKey(collection string) string {
+	return collection + "/" + manifestName
+}
+
+func fileKey(collection string, day time.Time) string {
+	date := day.Format("2006-01-02")
+	return collection + "/date=" + date + "/" + collection + "-" + date + ".ndjson.gz"
+}
+
+// ReadManifest returns collection manifest. Empty manifest is returned if collection is not archived.
+func ReadManifest(target Target, collection string) (Manifest, error) {
+	manifest := Manifest{Collection: collection}
+	data, err := target.Get(manifestKey(collection))
+	if err == ErrNotExist {
+		return manifest, nil
+	}
+	if err != nil {
+		return manifest, err
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("invalid manifest of %s: %v", collection, err)
+	}
+	return manifest, nil
+}
+
+func writeManifest(target Target, manifest Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return target.Put(manifestKey(manifest.Collection), data)
+}
+
+// Run archives records every period until stop is closed.
+func (a *Archiver) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(a.cfg.Period)
+	defer ticker.Stop()
+	for {
+		a.ArchiveAll(time.Now())
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ArchiveAll archives all collections, errors are logged.
+func (a *Archiver) ArchiveAll(now time.Time) {
+	for _, collection := range a.cfg.Collections {
+		if err := a.Archive(collection, now); err != nil {
+			a.log.WithError(err).WithField("collection", collection).Error("Archiving failed")
+		}
+	}
+}
+
+// Archive archives days of collection which are nearing expiration.
+func (a *Archiver) Archive(collection string, now time.Time) error {
+	manifest, err := ReadManifest(a.cfg.Target, collection)
+	if err != nil {
+		return err
+	}
+	shortest, longest := a.cfg.Source.RetentionBounds(collection)
+	// older records are already removed
+	day := now.Add(-longest).UTC().Truncate(partition)
+	if manifest.ArchivedUntil.After(day) {
+		day = manifest.ArchivedUntil.UTC()
+	}
+	until := now.Add(-shortest + a.cfg.LeadTime).UTC().Truncate(partition)
+	// records of current day are still added
+	if today := now.UTC().Truncate(partition); until.After(today) {
+		until = today
+	}
+
+	for ; day.Before(until); day = day.Add(partition) {
+		file, err := a.archiveDay(collection, day)
+		if err != nil {
+			return err
+		}
+		if file.Records > 0 {
+			manifest.Files = append(manifest.Files, file)
+		}
+		manifest.ArchivedUntil = day.Add(partition)
+		if err := writeManifest(a.cfg.Target, manifest); err != nil {
+			return err
+		}
+		a.log.WithFields(log.Fields{
+			"collection": collection,
+			"day":        day.Format("2006-01-02"),
+			"records":    file.Records,
+		}).Info("Records archived")
+	}
+	return nil
+}
+
+// archiveDay writes file with records added in day. File is not written if there are no records.
+func (a *Archiver) archiveDay(collection string, day time.Time) (File, error) {
+	file := File{
+		Key:  fileKey(collection, day),
+		From: day,
+		To:   day.Add(partition),
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(gz)
+	err := a.cfg.Source.IterateRecords(storage.EventQuery{
+		Collection: collection,
+		Since:      file.From,
+		Until:      file.To,
+	}, func(record kubeClientModel.Event) error {
+		file.Records++
+		return encoder.Encode(Line{DateAdded: record.DateAdded, Event: record})
+	})
+	if err != nil {
+		return file, err
+	}
+	if err := gz.Close(); err != nil {
+		return file, err
+	}
+	if file.Records == 0 {
+		return file, nil
+	}
+
+	data := buf.Bytes()
+	file.Size = len(data)
+	file.SHA256 = sha256Hex(data)
+	file.ArchivedAt = time.Now().UTC()
+	return file, a.cfg.Target.Put(file.Key, data)
+}
+
+// RestoreOptions selects archived records to restore.
+type RestoreOptions struct {
+	Collection string
+	// From and To select records added in [From, To). Zero values are not limiting.
+	From time.Time
+	To   time.Time
+	// TargetCollection is a collection records are inserted into. Records are restored to original collection if empty.
+	TargetCollection string
+}
+
+// Restore inserts archived records into storage and returns number of restored records.
+// Restored records are added with current time, so they are not expired by storage immediately.
+// Original time of adding is kept in "archived_date_added" detail.
+func Restore(target Target, inserter storage.EventBulkInserter, opts RestoreOptions) (int, error) {
+	manifest, err := ReadManifest(target, opts.Collection)
+	if err != nil {
+		return 0, err
+	}
+	if opts.TargetCollection == "" {
+		opts.TargetCollection = opts.Collection
+	}
+	files := make([]File, 0, len(manifest.Files))
+	for _, file := range manifest.Files {
+		if (opts.To.IsZero() || file.From.Before(opts.To)) && (opts.From.IsZero() || file.To.After(opts.From)) {
+			files = append(files, file)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].From.Before(files[j].From)
+	})
+
+	restored := 0
+	now := time.Now()
+	for _, file := range files {
+		lines, err := readFile(target, file)
+		if err != nil {
+			return restored, err
+		}
+		batch := make([]kubeClientModel.Event, 0, restoreBatchSize)
+		for _, line := range lines {
+			if line.DateAdded.Before(opts.From) || (!opts.To.IsZero() && !line.DateAdded.Before(opts.To)) {
+				continue
+			}
+			record := line.Event
+			details := make(map[string]string, len(record.Details)+1)
+			for k, v := range record.Details {
+				details[k] = v
+			}
+			details["archived_date_added"] = line.DateAdded.UTC().Format(time.RFC3339Nano)
+			record.Details = details
+			record.DateAdded = now
+			batch = append(batch, record)
+			if len(batch) == restoreBatchSize {
+				if err := inserter.BulkInsert(batch, opts.TargetCollection); err != nil {
+					return restored, err
+				}
+				restored += len(batch)
+				batch = batch[:0]
+			}
+		}
+		if len(batch) > 0 {
+			if err := inserter.BulkInsert(batch, opts.TargetCollection); err != nil {
+				return restored, err
+			}
+			restored += len(batch)
+		}
+	}
+	return restored, nil
+}
+
+// readFile reads archived file and verifies its checksum.
+func readFile(target Target, file File) ([]Line, error) {
+	data, err := target.Get(file.Key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %v", file.Key, err)
+	}
+	if sha256Hex(data) != file.SHA256 {
+		return nil, fmt.Errorf("checksum mismatch of %s", file.Key)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %v", file.Key, err)
+	}
+	defer gz.Close()
+	var lines []Line
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		var line Line
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("invalid record in %s: %v", file.Key, err)
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read %s: %v", file.Key, err)
+	}
+	return lines, nil
+}
@@ -0,0 +1,100 @@
+package cloudevents
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+)
+
+const (
+	SpecVersion = "1.0"
+
+	DefaultTypePrefix = "io.containerum.kube-events"
+)
+
+// Event is a CloudEvents 1.0 event in JSON format.
+type Event struct {
+	SpecVersion     string                `json:"specversion"`
+	ID              string                `json:"id"`
+	Source          string                `json:"source"`
+	Type            string                `json:"type"`
+	Subject         string                `json:"subject,omitempty"`
+	Time            string                `json:"time,omitempty"`
+	DataContentType string                `json:"datacontenttype"`
+	Collection      string                `json:"collection"`
+	Data            kubeClientModel.Event `json:"data"`
+}
+
+// Encoder makes CloudEvents from records.
+type Encoder struct {
+	// Cluster is a first part of event source.
+	Cluster string
+	// TypePrefix is a prefix of event type.
+	TypePrefix string
+}
+
+// Encode makes CloudEvent from record:
+//
+//	type is "<prefix>.<event kind>.<event name>",
+//	source is "/<cluster>/<namespace>/<resource type>" without empty parts,
+//	subject is a resource name,
+//	id is a hash of collection and record, so record sent again has same id,
+//	time is an event time or time of adding to collection.
+func (e Encoder) Encode(record kubeClientModel.Event, collection string) Event {
+	prefix := e.TypePrefix
+	if prefix == "" {
+		prefix = DefaultTypePrefix
+	}
+	typeParts := []string{prefix}
+	for _, part := range []string{string(record.Kind), record.Name} {
+		if part != "" {
+			typeParts = append(typeParts, part)
+		}
+	}
+
+	source := ""
+	for _, part := range []string{e.Cluster, record.ResourceNamespace, string(record.ResourceType)} {
+		if part != "" {
+			source += "/" + url.PathEscape(part)
+		}
+	}
+	if source == "" {
+		source = "/"
+	}
+
+	return Event{
+		SpecVersion:     SpecVersion,
+		ID:              eventID(record, collection),
+		Source:          source,
+		Type:            strings.Join(typeParts, "."),
+		Subject:         record.ResourceName,
+		Time:            eventTime(record),
+		DataContentType: "application/json",
+		Collection:      collection,
+		Data:            record,
+	}
+}
+
+func eventID(record kubeClientModel.Event, collection string) string {
+	hash := sha256.New()
+	io.WriteString(hash, collection)
+	hash.Write([]byte{0})
+	json.NewEncoder(hash).Encode(record)
+	return hex.EncodeToString(hash.Sum(nil)[:16])
+}
+
+func eventTime(record kubeClientModel.Event) string {
+	if t, err := time.Parse(time.RFC3339Nano, record.Time); err == nil {
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	if !record.DateAdded.IsZero() {
+		return record.DateAdded.UTC().Format(time.RFC3339Nano)
+	}
+	return ""
+}
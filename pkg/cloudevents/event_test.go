@@ -0,0 +1,48 @@
+package cloudevents
+
+import (
+	"testing"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+)
+
+func testRecord(name string) kubeClientModel.Event {
+	return kubeClientModel.Event{
+		Kind:              kubeClientModel.EventWarning,
+		Name:              name,
+		Time:              "2018-07-01T12:00:00+03:00",
+		ResourceType:      kubeClientModel.TypePod,
+		ResourceName:      "pod-1",
+		ResourceNamespace: "default",
+	}
+}
+
+func TestEncode(t *testing.T) {
+	encoder := Encoder{Cluster: "prod"}
+	event := encoder.Encode(testRecord("BackOff"), "events")
+	if event.Type != DefaultTypePrefix+".warning.BackOff" {
+		t.Errorf("unexpected type %q", event.Type)
+	}
+	if event.Source != "/prod/default/pod" {
+		t.Errorf("unexpected source %q", event.Source)
+	}
+	if event.Subject != "pod-1" {
+		t.Errorf("unexpected subject %q", event.Subject)
+	}
+	if event.Time != "2018-07-01T09:00:00Z" {
+		t.Errorf("unexpected time %q", event.Time)
+	}
+	if again := encoder.Encode(testRecord("BackOff"), "events"); again.ID != event.ID || len(event.ID) != 32 {
+		t.Errorf("id is not deterministic: %q, %q", event.ID, again.ID)
+	}
+	if other := encoder.Encode(testRecord("BackOff"), "system"); other.ID == event.ID {
+		t.Error("records of different collections have same id")
+	}
+
+	record := kubeClientModel.Event{DateAdded: time.Date(2018, 7, 1, 10, 0, 0, 0, time.UTC)}
+	event = Encoder{TypePrefix: "kube"}.Encode(record, "events")
+	if event.Type != "kube" || event.Source != "/" || event.Time != "2018-07-01T10:00:00Z" {
+		t.Errorf("unexpected event %+v", event)
+	}
+}
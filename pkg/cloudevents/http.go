@@ -0,0 +1,164 @@
+package cloudevents
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// HTTP content modes
+const (
+	// ModeStructured sends every event in request body with "application/cloudevents+json" content type.
+	ModeStructured = "structured"
+	// ModeBinary sends every event data in request body and attributes in "ce-" headers.
+	ModeBinary = "binary"
+	// ModeBatch sends all flushed events in one request with "application/cloudevents-batch+json" content type.
+	ModeBatch = "batch"
+)
+
+type HTTPSinkConfig struct {
+	URL     string
+	Mode    string
+	Encoder Encoder
+	Headers map[string]string
+	Timeout time.Duration
+
+	// MaxAttempts is a number of attempts of request failed with 429 or 5xx status.
+	MaxAttempts int
+	RetryDelay  time.Duration
+}
+
+// HTTPSink sends records as CloudEvents over HTTP. It implements storage.EventBulkInserter.
+// If request fails in structured or binary mode, only events which were not sent are resent by record buffer.
+// Batch is resent as a whole, consumers may deduplicate events by id.
+type HTTPSink struct {
+	cfg    HTTPSinkConfig
+	client *http.Client
+	log    *log.Entry
+}
+
+func NewHTTPSink(cfg HTTPSinkConfig) (*HTTPSink, error) {
+	switch cfg.Mode {
+	case "":
+		cfg.Mode = ModeBatch
+	case ModeStructured, ModeBinary, ModeBatch:
+		//pass
+	default:
+		return nil, fmt.Errorf("unknown cloudevents mode %q", cfg.Mode)
+	}
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("cloudevents url is required")
+	}
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	return &HTTPSink{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		log:    log.WithField("component", "cloudevents_sink"),
+	}, nil
+}
+
+func (s *HTTPSink) BulkInsert(r []kubeClientModel.Event, collection string) error {
+	if len(r) == 0 {
+		return nil
+	}
+	events := make([]Event, len(r))
+	for i, record := range r {
+		events[i] = s.cfg.Encoder.Encode(record, collection)
+	}
+
+	if s.cfg.Mode == ModeBatch {
+		body, err := json.Marshal(events)
+		if err != nil {
+			return err
+		}
+		return s.send(body, http.Header{"Content-Type": {"application/cloudevents-batch+json"}})
+	}
+	for i, event := range events {
+		body, header, err := s.encode(event)
+		if err == nil {
+			err = s.send(body, header)
+		}
+		if err == nil {
+			continue
+		}
+		if storage.IsFinal(err) {
+			return storage.Final(fmt.Errorf("%d events are not sent: %v", len(events)-i, err))
+		}
+		// events sent before failure are not resent
+		failed := make([]int, 0, len(events)-i)
+		for j := i; j < len(events); j++ {
+			failed = append(failed, j)
+		}
+		return &storage.PartialError{Failed: failed, Err: err}
+	}
+	return nil
+}
+
+// encode returns request body and headers of single event.
+func (s *HTTPSink) encode(event Event) ([]byte, http.Header, error) {
+	if s.cfg.Mode == ModeStructured {
+		body, err := json.Marshal(event)
+		return body, http.Header{"Content-Type": {"application/cloudevents+json"}}, err
+	}
+	body, err := json.Marshal(event.Data)
+	header := http.Header{
+		"Content-Type":   {event.DataContentType},
+		"Ce-Specversion": {event.SpecVersion},
+		"Ce-Id":          {event.ID},
+		"Ce-Source":      {event.Source},
+		"Ce-Type":        {event.Type},
+		"Ce-Collection":  {event.Collection},
+	}
+	if event.Subject != "" {
+		header.Set("Ce-Subject", event.Subject)
+	}
+	if event.Time != "" {
+		header.Set("Ce-Time", event.Time)
+	}
+	return body, header, err
+}
+
+func (s *HTTPSink) send(body []byte, header http.Header) error {
+	retry := storage.Retry{Attempts: s.cfg.MaxAttempts, Delay: s.cfg.RetryDelay}
+	return retry.Do(s.log, func() error {
+		return s.post(body, header)
+	})
+}
+
+// post returns final error if response status is not 429 or 5xx.
+func (s *HTTPSink) post(body []byte, header http.Header) error {
+	req, err := http.NewRequest(http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return storage.Final(err)
+	}
+	for k, v := range s.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("cloudevents endpoint returned %s", resp.Status)
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return storage.Final(err)
+	}
+	return err
+}
@@ -0,0 +1,141 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/storage"
+)
+
+// testEndpoint stores received requests and fails requests listed in failures.
+type testEndpoint struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	failures map[int]int
+}
+
+func (e *testEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	n := len(e.requests)
+	e.requests = append(e.requests, r)
+	e.bodies = append(e.bodies, body)
+	if status, ok := e.failures[n]; ok {
+		w.WriteHeader(status)
+	}
+}
+
+func newTestSink(t *testing.T, mode string, endpoint *testEndpoint) (*HTTPSink, func()) {
+	server := httptest.NewServer(endpoint)
+	sink, err := NewHTTPSink(HTTPSinkConfig{
+		URL:        server.URL,
+		Mode:       mode,
+		Encoder:    Encoder{Cluster: "prod"},
+		Timeout:    time.Second,
+		RetryDelay: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sink, server.Close
+}
+
+func TestStructuredMode(t *testing.T) {
+	endpoint := &testEndpoint{}
+	sink, stop := newTestSink(t, ModeStructured, endpoint)
+	defer stop()
+	if err := sink.BulkInsert([]kubeClientModel.Event{testRecord("a"), testRecord("b")}, "events"); err != nil {
+		t.Fatal(err)
+	}
+	if len(endpoint.requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(endpoint.requests))
+	}
+	for i, name := range []string{"a", "b"} {
+		if ct := endpoint.requests[i].Header.Get("Content-Type"); ct != "application/cloudevents+json" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+		var event Event
+		if err := json.Unmarshal(endpoint.bodies[i], &event); err != nil {
+			t.Fatal(err)
+		}
+		if event.SpecVersion != SpecVersion || event.Data.Name != name || event.Collection != "events" {
+			t.Errorf("unexpected event %+v", event)
+		}
+	}
+}
+
+func TestBinaryMode(t *testing.T) {
+	endpoint := &testEndpoint{}
+	sink, stop := newTestSink(t, ModeBinary, endpoint)
+	defer stop()
+	if err := sink.BulkInsert([]kubeClientModel.Event{testRecord("a")}, "events"); err != nil {
+		t.Fatal(err)
+	}
+	expected := Encoder{Cluster: "prod"}.Encode(testRecord("a"), "events")
+	header := endpoint.requests[0].Header
+	for name, value := range map[string]string{
+		"Content-Type":   "application/json",
+		"Ce-Specversion": SpecVersion,
+		"Ce-Id":          expected.ID,
+		"Ce-Source":      "/prod/default/pod",
+		"Ce-Type":        expected.Type,
+		"Ce-Subject":     "pod-1",
+		"Ce-Time":        "2018-07-01T09:00:00Z",
+		"Ce-Collection":  "events",
+	} {
+		if header.Get(name) != value {
+			t.Errorf("expected %s: %q, got %q", name, value, header.Get(name))
+		}
+	}
+	var record kubeClientModel.Event
+	if err := json.Unmarshal(endpoint.bodies[0], &record); err != nil || record.Name != "a" {
+		t.Errorf("unexpected data %s: %v", endpoint.bodies[0], err)
+	}
+}
+
+func TestBatchMode(t *testing.T) {
+	endpoint := &testEndpoint{}
+	sink, stop := newTestSink(t, ModeBatch, endpoint)
+	defer stop()
+	if err := sink.BulkInsert([]kubeClientModel.Event{testRecord("a"), testRecord("b")}, "events"); err != nil {
+		t.Fatal(err)
+	}
+	if len(endpoint.requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(endpoint.requests))
+	}
+	if ct := endpoint.requests[0].Header.Get("Content-Type"); ct != "application/cloudevents-batch+json" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	var events []Event
+	if err := json.Unmarshal(endpoint.bodies[0], &events); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Data.Name != "a" || events[1].Data.Name != "b" {
+		t.Errorf("unexpected events %+v", events)
+	}
+}
+
+func TestSentEventsAreNotResent(t *testing.T) {
+	endpoint := &testEndpoint{failures: map[int]int{1: http.StatusServiceUnavailable}}
+	sink, stop := newTestSink(t, ModeStructured, endpoint)
+	defer stop()
+	err := sink.BulkInsert([]kubeClientModel.Event{testRecord("a"), testRecord("b"), testRecord("c")}, "events")
+	partial, ok := err.(*storage.PartialError)
+	if !ok || len(partial.Failed) != 2 || partial.Failed[0] != 1 || partial.Failed[1] != 2 {
+		t.Fatalf("expected partial error of events 1 and 2, got %v", err)
+	}
+
+	endpoint.failures = map[int]int{2: http.StatusBadRequest}
+	err = sink.BulkInsert([]kubeClientModel.Event{testRecord("b"), testRecord("c")}, "events")
+	if !storage.IsFinal(err) {
+		t.Fatalf("expected final error, got %v", err)
+	}
+}
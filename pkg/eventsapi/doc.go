@@ -0,0 +1,6 @@
+// Package eventsapi contains gRPC service for querying stored events and watching transformed events.
+//
+// Go client and server stubs in events.pb.go are generated from events.proto.
+package eventsapi
+
+//go:generate protoc --go_out=plugins=grpc,paths=source_relative:. events.proto
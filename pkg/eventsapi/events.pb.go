@@ -0,0 +1,782 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: events.proto
+
+package eventsapi // import "github.com/containerum/kube-events/pkg/eventsapi"
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+import timestamp "github.com/golang/protobuf/ptypes/timestamp"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+// Event mirrors github.com/containerum/kube-client/pkg/model.Event.
+type Event struct {
+	EventKind            string               `protobuf:"bytes,1,opt,name=event_kind,json=eventKind,proto3" json:"event_kind,omitempty"`
+	EventTime            string               `protobuf:"bytes,2,opt,name=event_time,json=eventTime,proto3" json:"event_time,omitempty"`
+	EventName            string               `protobuf:"bytes,3,opt,name=event_name,json=eventName,proto3" json:"event_name,omitempty"`
+	ResourceType         string               `protobuf:"bytes,4,opt,name=resource_type,json=resourceType,proto3" json:"resource_type,omitempty"`
+	ResourceName         string               `protobuf:"bytes,5,opt,name=resource_name,json=resourceName,proto3" json:"resource_name,omitempty"`
+	ResourceNamespace    string               `protobuf:"bytes,6,opt,name=resource_namespace,json=resourceNamespace,proto3" json:"resource_namespace,omitempty"`
+	ResourceUid          string               `protobuf:"bytes,7,opt,name=resource_uid,json=resourceUid,proto3" json:"resource_uid,omitempty"`
+	Message              string               `protobuf:"bytes,8,opt,name=message,proto3" json:"message,omitempty"`
+	Details              map[string]string    `protobuf:"bytes,9,rep,name=details,proto3" json:"details,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	DateAdded            *timestamp.Timestamp `protobuf:"bytes,10,opt,name=date_added,json=dateAdded,proto3" json:"date_added,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *Event) Reset()         { *m = Event{} }
+func (m *Event) String() string { return proto.CompactTextString(m) }
+func (*Event) ProtoMessage()    {}
+func (*Event) Descriptor() ([]byte, []int) {
+	return fileDescriptor_events_4fc7bdd2cee42de4, []int{0}
+}
+func (m *Event) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Event.Unmarshal(m, b)
+}
+func (m *Event) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Event.Marshal(b, m, deterministic)
+}
+func (dst *Event) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Event.Merge(dst, src)
+}
+func (m *Event) XXX_Size() int {
+	return xxx_messageInfo_Event.Size(m)
+}
+func (m *Event) XXX_DiscardUnknown() {
+	xxx_messageInfo_Event.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Event proto.InternalMessageInfo
+
+func (m *Event) GetEventKind() string {
+	if m != nil {
+		return m.EventKind
+	}
+	return ""
+}
+
+func (m *Event) GetEventTime() string {
+	if m != nil {
+		return m.EventTime
+	}
+	return ""
+}
+
+func (m *Event) GetEventName() string {
+	if m != nil {
+		return m.EventName
+	}
+	return ""
+}
+
+func (m *Event) GetResourceType() string {
+	if m != nil {
+		return m.ResourceType
+	}
+	return ""
+}
+
+func (m *Event) GetResourceName() string {
+	if m != nil {
+		return m.ResourceName
+	}
+	return ""
+}
+
+func (m *Event) GetResourceNamespace() string {
+	if m != nil {
+		return m.ResourceNamespace
+	}
+	return ""
+}
+
+func (m *Event) GetResourceUid() string {
+	if m != nil {
+		return m.ResourceUid
+	}
+	return ""
+}
+
+func (m *Event) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *Event) GetDetails() map[string]string {
+	if m != nil {
+		return m.Details
+	}
+	return nil
+}
+
+func (m *Event) GetDateAdded() *timestamp.Timestamp {
+	if m != nil {
+		return m.DateAdded
+	}
+	return nil
+}
+
+type ListRequest struct {
+	Collection        string               `protobuf:"bytes,1,opt,name=collection,proto3" json:"collection,omitempty"`
+	ResourceType      string               `protobuf:"bytes,2,opt,name=resource_type,json=resourceType,proto3" json:"resource_type,omitempty"`
+	ResourceNamespace string               `protobuf:"bytes,3,opt,name=resource_namespace,json=resourceNamespace,proto3" json:"resource_namespace,omitempty"`
+	ResourceName      string               `protobuf:"bytes,4,opt,name=resource_name,json=resourceName,proto3" json:"resource_name,omitempty"`
+	ResourceUid       string               `protobuf:"bytes,5,opt,name=resource_uid,json=resourceUid,proto3" json:"resource_uid,omitempty"`
+	EventKind         []string             `protobuf:"bytes,6,rep,name=event_kind,json=eventKind,proto3" json:"event_kind,omitempty"`
+	EventName         string               `protobuf:"bytes,7,opt,name=event_name,json=eventName,proto3" json:"event_name,omitempty"`
+	Since             *timestamp.Timestamp `protobuf:"bytes,8,opt,name=since,proto3" json:"since,omitempty"`
+	Until             *timestamp.Timestamp `protobuf:"bytes,9,opt,name=until,proto3" json:"until,omitempty"`
+	Limit             int32                `protobuf:"varint,10,opt,name=limit,proto3" json:"limit,omitempty"`
+	Cursor            string               `protobuf:"bytes,11,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	// Kubernetes events which occurred at least min_occurrences times.
+	MinOccurrences int32 `protobuf:"varint,12,opt,name=min_occurrences,json=minOccurrences,proto3" json:"min_occurrences,omitempty"`
+	// Kubernetes events which were last seen at seen_since or later.
+	SeenSince            *timestamp.Timestamp `protobuf:"bytes,13,opt,name=seen_since,json=seenSince,proto3" json:"seen_since,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *ListRequest) Reset()         { *m = ListRequest{} }
+func (m *ListRequest) String() string { return proto.CompactTextString(m) }
+func (*ListRequest) ProtoMessage()    {}
+func (*ListRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_events_4fc7bdd2cee42de4, []int{1}
+}
+func (m *ListRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListRequest.Unmarshal(m, b)
+}
+func (m *ListRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListRequest.Marshal(b, m, deterministic)
+}
+func (dst *ListRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListRequest.Merge(dst, src)
+}
+func (m *ListRequest) XXX_Size() int {
+	return xxx_messageInfo_ListRequest.Size(m)
+}
+func (m *ListRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListRequest proto.InternalMessageInfo
+
+func (m *ListRequest) GetCollection() string {
+	if m != nil {
+		return m.Collection
+	}
+	return ""
+}
+
+func (m *ListRequest) GetResourceType() string {
+	if m != nil {
+		return m.ResourceType
+	}
+	return ""
+}
+
+func (m *ListRequest) GetResourceNamespace() string {
+	if m != nil {
+		return m.ResourceNamespace
+	}
+	return ""
+}
+
+func (m *ListRequest) GetResourceName() string {
+	if m != nil {
+		return m.ResourceName
+	}
+	return ""
+}
+
+func (m *ListRequest) GetResourceUid() string {
+	if m != nil {
+		return m.ResourceUid
+	}
+	return ""
+}
+
+func (m *ListRequest) GetEventKind() []string {
+	if m != nil {
+		return m.EventKind
+	}
+	return nil
+}
+
+func (m *ListRequest) GetEventName() string {
+	if m != nil {
+		return m.EventName
+	}
+	return ""
+}
+
+func (m *ListRequest) GetSince() *timestamp.Timestamp {
+	if m != nil {
+		return m.Since
+	}
+	return nil
+}
+
+func (m *ListRequest) GetUntil() *timestamp.Timestamp {
+	if m != nil {
+		return m.Until
+	}
+	return nil
+}
+
+func (m *ListRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+func (m *ListRequest) GetCursor() string {
+	if m != nil {
+		return m.Cursor
+	}
+	return ""
+}
+
+func (m *ListRequest) GetMinOccurrences() int32 {
+	if m != nil {
+		return m.MinOccurrences
+	}
+	return 0
+}
+
+func (m *ListRequest) GetSeenSince() *timestamp.Timestamp {
+	if m != nil {
+		return m.SeenSince
+	}
+	return nil
+}
+
+type ListResponse struct {
+	Events               []*Event `protobuf:"bytes,1,rep,name=events,proto3" json:"events,omitempty"`
+	NextCursor           string   `protobuf:"bytes,2,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListResponse) Reset()         { *m = ListResponse{} }
+func (m *ListResponse) String() string { return proto.CompactTextString(m) }
+func (*ListResponse) ProtoMessage()    {}
+func (*ListResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_events_4fc7bdd2cee42de4, []int{2}
+}
+func (m *ListResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListResponse.Unmarshal(m, b)
+}
+func (m *ListResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListResponse.Marshal(b, m, deterministic)
+}
+func (dst *ListResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListResponse.Merge(dst, src)
+}
+func (m *ListResponse) XXX_Size() int {
+	return xxx_messageInfo_ListResponse.Size(m)
+}
+func (m *ListResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListResponse proto.InternalMessageInfo
+
+func (m *ListResponse) GetEvents() []*Event {
+	if m != nil {
+		return m.Events
+	}
+	return nil
+}
+
+func (m *ListResponse) GetNextCursor() string {
+	if m != nil {
+		return m.NextCursor
+	}
+	return ""
+}
+
+type WatchRequest struct {
+	Collection        []string `protobuf:"bytes,1,rep,name=collection,proto3" json:"collection,omitempty"`
+	ResourceNamespace string   `protobuf:"bytes,2,opt,name=resource_namespace,json=resourceNamespace,proto3" json:"resource_namespace,omitempty"`
+	ResourceType      []string `protobuf:"bytes,3,rep,name=resource_type,json=resourceType,proto3" json:"resource_type,omitempty"`
+	EventKind         []string `protobuf:"bytes,4,rep,name=event_kind,json=eventKind,proto3" json:"event_kind,omitempty"`
+	// Replay records kept in memory after record with this ID or, if it is unknown, received after since.
+	LastId               string               `protobuf:"bytes,5,opt,name=last_id,json=lastId,proto3" json:"last_id,omitempty"`
+	Since                *timestamp.Timestamp `protobuf:"bytes,6,opt,name=since,proto3" json:"since,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *WatchRequest) Reset()         { *m = WatchRequest{} }
+func (m *WatchRequest) String() string { return proto.CompactTextString(m) }
+func (*WatchRequest) ProtoMessage()    {}
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_events_4fc7bdd2cee42de4, []int{3}
+}
+func (m *WatchRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WatchRequest.Unmarshal(m, b)
+}
+func (m *WatchRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WatchRequest.Marshal(b, m, deterministic)
+}
+func (dst *WatchRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WatchRequest.Merge(dst, src)
+}
+func (m *WatchRequest) XXX_Size() int {
+	return xxx_messageInfo_WatchRequest.Size(m)
+}
+func (m *WatchRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_WatchRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WatchRequest proto.InternalMessageInfo
+
+func (m *WatchRequest) GetCollection() []string {
+	if m != nil {
+		return m.Collection
+	}
+	return nil
+}
+
+func (m *WatchRequest) GetResourceNamespace() string {
+	if m != nil {
+		return m.ResourceNamespace
+	}
+	return ""
+}
+
+func (m *WatchRequest) GetResourceType() []string {
+	if m != nil {
+		return m.ResourceType
+	}
+	return nil
+}
+
+func (m *WatchRequest) GetEventKind() []string {
+	if m != nil {
+		return m.EventKind
+	}
+	return nil
+}
+
+func (m *WatchRequest) GetLastId() string {
+	if m != nil {
+		return m.LastId
+	}
+	return ""
+}
+
+func (m *WatchRequest) GetSince() *timestamp.Timestamp {
+	if m != nil {
+		return m.Since
+	}
+	return nil
+}
+
+type WatchResponse struct {
+	// ID may be passed as last_id to resume watch after this record.
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Collection           string   `protobuf:"bytes,2,opt,name=collection,proto3" json:"collection,omitempty"`
+	Event                *Event   `protobuf:"bytes,3,opt,name=event,proto3" json:"event,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WatchResponse) Reset()         { *m = WatchResponse{} }
+func (m *WatchResponse) String() string { return proto.CompactTextString(m) }
+func (*WatchResponse) ProtoMessage()    {}
+func (*WatchResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_events_4fc7bdd2cee42de4, []int{4}
+}
+func (m *WatchResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WatchResponse.Unmarshal(m, b)
+}
+func (m *WatchResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WatchResponse.Marshal(b, m, deterministic)
+}
+func (dst *WatchResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WatchResponse.Merge(dst, src)
+}
+func (m *WatchResponse) XXX_Size() int {
+	return xxx_messageInfo_WatchResponse.Size(m)
+}
+func (m *WatchResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_WatchResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WatchResponse proto.InternalMessageInfo
+
+func (m *WatchResponse) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *WatchResponse) GetCollection() string {
+	if m != nil {
+		return m.Collection
+	}
+	return ""
+}
+
+func (m *WatchResponse) GetEvent() *Event {
+	if m != nil {
+		return m.Event
+	}
+	return nil
+}
+
+type StatsRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StatsRequest) Reset()         { *m = StatsRequest{} }
+func (m *StatsRequest) String() string { return proto.CompactTextString(m) }
+func (*StatsRequest) ProtoMessage()    {}
+func (*StatsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_events_4fc7bdd2cee42de4, []int{5}
+}
+func (m *StatsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StatsRequest.Unmarshal(m, b)
+}
+func (m *StatsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StatsRequest.Marshal(b, m, deterministic)
+}
+func (dst *StatsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StatsRequest.Merge(dst, src)
+}
+func (m *StatsRequest) XXX_Size() int {
+	return xxx_messageInfo_StatsRequest.Size(m)
+}
+func (m *StatsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_StatsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StatsRequest proto.InternalMessageInfo
+
+type StatsResponse struct {
+	Published             uint64            `protobuf:"varint,1,opt,name=published,proto3" json:"published,omitempty"`
+	Subscribers           uint64            `protobuf:"varint,2,opt,name=subscribers,proto3" json:"subscribers,omitempty"`
+	DroppedSubscribers    uint64            `protobuf:"varint,3,opt,name=dropped_subscribers,json=droppedSubscribers,proto3" json:"dropped_subscribers,omitempty"`
+	PublishedByCollection map[string]uint64 `protobuf:"bytes,4,rep,name=published_by_collection,json=publishedByCollection,proto3" json:"published_by_collection,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral  struct{}          `json:"-"`
+	XXX_unrecognized      []byte            `json:"-"`
+	XXX_sizecache         int32             `json:"-"`
+}
+
+func (m *StatsResponse) Reset()         { *m = StatsResponse{} }
+func (m *StatsResponse) String() string { return proto.CompactTextString(m) }
+func (*StatsResponse) ProtoMessage()    {}
+func (*StatsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_events_4fc7bdd2cee42de4, []int{6}
+}
+func (m *StatsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StatsResponse.Unmarshal(m, b)
+}
+func (m *StatsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StatsResponse.Marshal(b, m, deterministic)
+}
+func (dst *StatsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StatsResponse.Merge(dst, src)
+}
+func (m *StatsResponse) XXX_Size() int {
+	return xxx_messageInfo_StatsResponse.Size(m)
+}
+func (m *StatsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_StatsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StatsResponse proto.InternalMessageInfo
+
+func (m *StatsResponse) GetPublished() uint64 {
+	if m != nil {
+		return m.Published
+	}
+	return 0
+}
+
+func (m *StatsResponse) GetSubscribers() uint64 {
+	if m != nil {
+		return m.Subscribers
+	}
+	return 0
+}
+
+func (m *StatsResponse) GetDroppedSubscribers() uint64 {
+	if m != nil {
+		return m.DroppedSubscribers
+	}
+	return 0
+}
+
+func (m *StatsResponse) GetPublishedByCollection() map[string]uint64 {
+	if m != nil {
+		return m.PublishedByCollection
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*Event)(nil), "eventsapi.Event")
+	proto.RegisterMapType((map[string]string)(nil), "eventsapi.Event.DetailsEntry")
+	proto.RegisterType((*ListRequest)(nil), "eventsapi.ListRequest")
+	proto.RegisterType((*ListResponse)(nil), "eventsapi.ListResponse")
+	proto.RegisterType((*WatchRequest)(nil), "eventsapi.WatchRequest")
+	proto.RegisterType((*WatchResponse)(nil), "eventsapi.WatchResponse")
+	proto.RegisterType((*StatsRequest)(nil), "eventsapi.StatsRequest")
+	proto.RegisterType((*StatsResponse)(nil), "eventsapi.StatsResponse")
+	proto.RegisterMapType((map[string]uint64)(nil), "eventsapi.StatsResponse.PublishedByCollectionEntry")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// KubeEventsClient is the client API for KubeEvents service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type KubeEventsClient interface {
+	// List returns page of stored events from newest to oldest.
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	// Watch streams records as soon as they are transformed.
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (KubeEvents_WatchClient, error)
+	Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error)
+}
+
+type kubeEventsClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewKubeEventsClient(cc *grpc.ClientConn) KubeEventsClient {
+	return &kubeEventsClient{cc}
+}
+
+func (c *kubeEventsClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	out := new(ListResponse)
+	err := c.cc.Invoke(ctx, "/eventsapi.KubeEvents/List", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kubeEventsClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (KubeEvents_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_KubeEvents_serviceDesc.Streams[0], "/eventsapi.KubeEvents/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kubeEventsWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type KubeEvents_WatchClient interface {
+	Recv() (*WatchResponse, error)
+	grpc.ClientStream
+}
+
+type kubeEventsWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *kubeEventsWatchClient) Recv() (*WatchResponse, error) {
+	m := new(WatchResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kubeEventsClient) Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error) {
+	out := new(StatsResponse)
+	err := c.cc.Invoke(ctx, "/eventsapi.KubeEvents/Stats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// KubeEventsServer is the server API for KubeEvents service.
+type KubeEventsServer interface {
+	// List returns page of stored events from newest to oldest.
+	List(context.Context, *ListRequest) (*ListResponse, error)
+	// Watch streams records as soon as they are transformed.
+	Watch(*WatchRequest, KubeEvents_WatchServer) error
+	Stats(context.Context, *StatsRequest) (*StatsResponse, error)
+}
+
+func RegisterKubeEventsServer(s *grpc.Server, srv KubeEventsServer) {
+	s.RegisterService(&_KubeEvents_serviceDesc, srv)
+}
+
+func _KubeEvents_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KubeEventsServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/eventsapi.KubeEvents/List",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KubeEventsServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KubeEvents_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KubeEventsServer).Watch(m, &kubeEventsWatchServer{stream})
+}
+
+type KubeEvents_WatchServer interface {
+	Send(*WatchResponse) error
+	grpc.ServerStream
+}
+
+type kubeEventsWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *kubeEventsWatchServer) Send(m *WatchResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _KubeEvents_Stats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KubeEventsServer).Stats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/eventsapi.KubeEvents/Stats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KubeEventsServer).Stats(ctx, req.(*StatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _KubeEvents_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "eventsapi.KubeEvents",
+	HandlerType: (*KubeEventsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "List",
+			Handler:    _KubeEvents_List_Handler,
+		},
+		{
+			MethodName: "Stats",
+			Handler:    _KubeEvents_Stats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _KubeEvents_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "events.proto",
+}
+
+func init() { proto.RegisterFile("events.proto", fileDescriptor_events_4fc7bdd2cee42de4) }
+
+var fileDescriptor_events_4fc7bdd2cee42de4 = []byte{
+	// 800 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x54, 0x5d, 0x6f, 0xe3, 0x44,
+	0x14, 0x55, 0xfc, 0x91, 0x90, 0x9b, 0xb4, 0x2c, 0x03, 0x6c, 0x47, 0x16, 0xcb, 0x86, 0x20, 0x41,
+	0x5f, 0xd6, 0xa9, 0xba, 0x0f, 0x0b, 0x15, 0x2f, 0xec, 0x52, 0x09, 0xb4, 0x08, 0x90, 0x5b, 0x84,
+	0xe0, 0xc5, 0xf2, 0xc7, 0x25, 0x1d, 0xc5, 0x1e, 0x1b, 0xcf, 0x78, 0x45, 0x7e, 0x19, 0x8f, 0xbc,
+	0xf3, 0x5b, 0xf8, 0x0b, 0x48, 0xc8, 0x33, 0x76, 0x32, 0xce, 0xc7, 0xb6, 0x6f, 0x99, 0x7b, 0xce,
+	0xb9, 0xb9, 0x73, 0xcf, 0xf1, 0xc0, 0x14, 0xdf, 0x20, 0x97, 0xc2, 0x2f, 0xab, 0x42, 0x16, 0x64,
+	0xac, 0x4f, 0x51, 0xc9, 0xbc, 0xa7, 0xcb, 0xa2, 0x58, 0x66, 0xb8, 0x50, 0x40, 0x5c, 0xff, 0xbe,
+	0x90, 0x2c, 0x47, 0x21, 0xa3, 0xbc, 0xd4, 0xdc, 0xf9, 0x3f, 0x36, 0xb8, 0xd7, 0x0d, 0x9d, 0x3c,
+	0x01, 0x50, 0xba, 0x70, 0xc5, 0x78, 0x4a, 0x07, 0xb3, 0xc1, 0xf9, 0x38, 0xd0, 0x9d, 0x5e, 0x33,
+	0x9e, 0x6e, 0xe1, 0xa6, 0x03, 0xb5, 0x0c, 0xf8, 0x96, 0xe5, 0xb8, 0x85, 0x79, 0x94, 0x23, 0xb5,
+	0x0d, 0xf8, 0x87, 0x28, 0x47, 0xf2, 0x29, 0x9c, 0x54, 0x28, 0x8a, 0xba, 0x4a, 0x30, 0x94, 0xeb,
+	0x12, 0xa9, 0xa3, 0x18, 0xd3, 0xae, 0x78, 0xbb, 0x2e, 0xfb, 0x24, 0xd5, 0xc6, 0xed, 0x93, 0x54,
+	0xa7, 0x67, 0x40, 0x7a, 0x24, 0x51, 0x46, 0x09, 0xd2, 0xa1, 0x62, 0xbe, 0x67, 0x32, 0x15, 0x40,
+	0x3e, 0x81, 0x8d, 0x3c, 0xac, 0x59, 0x4a, 0x47, 0x8a, 0x38, 0xe9, 0x6a, 0x3f, 0xb3, 0x94, 0x50,
+	0x18, 0xe5, 0x28, 0x44, 0xb4, 0x44, 0xfa, 0x8e, 0x42, 0xbb, 0x23, 0x79, 0x01, 0xa3, 0x14, 0x65,
+	0xc4, 0x32, 0x41, 0xc7, 0x33, 0xfb, 0x7c, 0x72, 0xf9, 0xc4, 0xdf, 0xac, 0xd6, 0x57, 0x5b, 0xf3,
+	0xbf, 0xd1, 0xf8, 0x35, 0x97, 0xd5, 0x3a, 0xe8, 0xd8, 0xe4, 0x4b, 0x80, 0x34, 0x92, 0x18, 0x46,
+	0x69, 0x8a, 0x29, 0x85, 0xd9, 0xe0, 0x7c, 0x72, 0xe9, 0xf9, 0xda, 0x0b, 0xbf, 0xf3, 0xc2, 0xbf,
+	0xed, 0xbc, 0x08, 0xc6, 0x0d, 0xfb, 0xeb, 0x86, 0xec, 0x5d, 0xc1, 0xd4, 0xec, 0x49, 0x1e, 0x81,
+	0xbd, 0xc2, 0x75, 0xeb, 0x47, 0xf3, 0x93, 0x7c, 0x00, 0xee, 0x9b, 0x28, 0xab, 0x3b, 0x13, 0xf4,
+	0xe1, 0xca, 0xfa, 0x62, 0x30, 0xff, 0xcf, 0x86, 0xc9, 0xf7, 0x4c, 0xc8, 0x00, 0xff, 0xa8, 0x51,
+	0x48, 0xf2, 0x31, 0x40, 0x52, 0x64, 0x19, 0x26, 0x92, 0x15, 0xbc, 0x6d, 0x61, 0x54, 0xf6, 0x5d,
+	0xb1, 0x0e, 0xb8, 0x72, 0x78, 0xe1, 0xf6, 0xb1, 0x85, 0xef, 0x99, 0xe8, 0x1c, 0x30, 0x71, 0xd7,
+	0x15, 0x77, 0xdf, 0x95, 0x7e, 0x1c, 0x87, 0x33, 0xfb, 0x48, 0x1c, 0xd5, 0x7f, 0x8c, 0x76, 0xf3,
+	0x76, 0x01, 0xae, 0x60, 0x3c, 0xd1, 0x8e, 0xbe, 0x7d, 0xf7, 0x9a, 0xd8, 0x28, 0x6a, 0x2e, 0x59,
+	0x46, 0xc7, 0xf7, 0x2b, 0x14, 0xb1, 0xf1, 0x21, 0x63, 0x39, 0x93, 0xca, 0x5f, 0x37, 0xd0, 0x07,
+	0xf2, 0x18, 0x86, 0x49, 0x5d, 0x89, 0xa2, 0xa2, 0x13, 0x35, 0x54, 0x7b, 0x22, 0x9f, 0xc3, 0xbb,
+	0x39, 0xe3, 0x61, 0x91, 0x24, 0x75, 0x55, 0x21, 0x4f, 0x50, 0xd0, 0xa9, 0xd2, 0x9d, 0xe6, 0x8c,
+	0xff, 0xb8, 0xad, 0x36, 0xd9, 0x11, 0x88, 0x3c, 0xd4, 0xf3, 0x9f, 0xdc, 0x9f, 0x9d, 0x86, 0x7d,
+	0xd3, 0x90, 0xe7, 0xbf, 0xc2, 0x54, 0xdb, 0x2f, 0xca, 0x82, 0x0b, 0x24, 0xe7, 0x30, 0xd4, 0x79,
+	0xa5, 0x03, 0x15, 0xdf, 0x47, 0xbb, 0xf1, 0x0d, 0x5a, 0x9c, 0x3c, 0x85, 0x09, 0xc7, 0x3f, 0x65,
+	0xd8, 0x8e, 0xae, 0x73, 0x00, 0x4d, 0xe9, 0x95, 0xaa, 0xcc, 0xff, 0x1d, 0xc0, 0xf4, 0x97, 0x48,
+	0x26, 0x77, 0xc7, 0xb2, 0x65, 0xef, 0x64, 0xeb, 0x70, 0x6c, 0xac, 0x87, 0xc4, 0x46, 0x45, 0xd1,
+	0x56, 0x1d, 0xfb, 0x51, 0xec, 0x67, 0xc2, 0xd9, 0xcd, 0xc4, 0x19, 0x8c, 0xb2, 0x48, 0xc8, 0x70,
+	0x13, 0xa8, 0x61, 0x73, 0xfc, 0x2e, 0xdd, 0xa6, 0x61, 0xf8, 0xc0, 0x34, 0xcc, 0x97, 0x70, 0xd2,
+	0xde, 0xb6, 0x5d, 0xe5, 0x29, 0x58, 0xac, 0x7b, 0x15, 0x2d, 0x96, 0xee, 0x5c, 0xdf, 0xda, 0xfb,
+	0xb4, 0x3e, 0x03, 0x57, 0x0d, 0xa6, 0x3e, 0x94, 0x43, 0x9b, 0xd7, 0xf0, 0xfc, 0x14, 0xa6, 0x37,
+	0x32, 0x92, 0xa2, 0x5d, 0xeb, 0xfc, 0x2f, 0x0b, 0x4e, 0xda, 0x42, 0xfb, 0xcf, 0x1f, 0xc1, 0xb8,
+	0xac, 0xe3, 0x8c, 0x89, 0x3b, 0xd4, 0x03, 0x38, 0xc1, 0xb6, 0x40, 0x66, 0x30, 0x11, 0x75, 0x2c,
+	0x92, 0x8a, 0xc5, 0x58, 0x09, 0x35, 0x88, 0x13, 0x98, 0x25, 0xb2, 0x80, 0xf7, 0xd3, 0xaa, 0x28,
+	0x4b, 0x4c, 0x43, 0x93, 0x69, 0x2b, 0x26, 0x69, 0xa1, 0x1b, 0x43, 0xb0, 0x82, 0xb3, 0x4d, 0xff,
+	0x30, 0x5e, 0x87, 0xc6, 0x3d, 0x1d, 0x15, 0xa3, 0xe7, 0xc6, 0x65, 0x7a, 0xb3, 0xfa, 0x3f, 0x75,
+	0xba, 0x97, 0xeb, 0x57, 0x1b, 0x95, 0x7e, 0x1b, 0x3f, 0x2c, 0x0f, 0x61, 0xde, 0xb7, 0xe0, 0x1d,
+	0x17, 0xdd, 0xf7, 0xf8, 0x39, 0xc6, 0xe3, 0x77, 0xf9, 0xf7, 0x00, 0xe0, 0x75, 0x1d, 0xe3, 0xb5,
+	0x4e, 0xf4, 0x0b, 0x70, 0x9a, 0x6f, 0x81, 0x3c, 0x36, 0x86, 0x35, 0xde, 0x46, 0xef, 0x6c, 0xaf,
+	0xde, 0xee, 0xfb, 0x2b, 0x70, 0x95, 0xf5, 0xc4, 0x64, 0x98, 0xd1, 0xf7, 0xe8, 0x3e, 0xa0, 0xb5,
+	0x17, 0x03, 0x72, 0x05, 0xae, 0x5a, 0x49, 0x4f, 0x6d, 0x3a, 0xec, 0xd1, 0x7d, 0x40, 0xab, 0x5f,
+	0x5e, 0xfe, 0x76, 0xb1, 0x64, 0xf2, 0xae, 0x8e, 0xfd, 0xa4, 0xc8, 0x17, 0x49, 0xc1, 0x65, 0xc4,
+	0x38, 0x56, 0x75, 0xbe, 0x58, 0xd5, 0x31, 0x3e, 0xd3, 0xb2, 0x45, 0xb9, 0x5a, 0x2e, 0x36, 0x1d,
+	0xe2, 0xa1, 0xca, 0xf0, 0xf3, 0xff, 0x07, 0x00, 0xb5, 0x7d, 0xc8, 0x03, 0x02, 0x08, 0x00, 0x00,
+}
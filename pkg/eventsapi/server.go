@@ -0,0 +1,226 @@
+package eventsapi
+
+import (
+	"crypto/subtle"
+	"strings"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/storage"
+	"github.com/containerum/kube-events/pkg/stream"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const slowConsumerMessage = "subscriber is too slow, reconnect with last received id"
+
+type ServerConfig struct {
+	Hub *stream.Hub
+	// Querier serves List. List is unavailable if Querier is nil.
+	Querier storage.EventQuerier
+}
+
+// Server implements KubeEventsServer.
+type Server struct {
+	cfg ServerConfig
+	log *log.Entry
+}
+
+func NewServer(cfg ServerConfig) *Server {
+	return &Server{
+		cfg: cfg,
+		log: log.WithField("component", "grpc_api"),
+	}
+}
+
+// List returns page of stored events from newest to oldest.
+func (s *Server) List(ctx context.Context, req *ListRequest) (*ListResponse, error) {
+	if s.cfg.Querier == nil {
+		return nil, status.Error(codes.Unavailable, "events storage is not configured")
+	}
+	query, err := eventQuery(req)
+	if err != nil {
+		return nil, err
+	}
+	page, err := s.cfg.Querier.QueryEvents(query)
+	switch err {
+	case nil:
+	case storage.ErrUnknownCollection, storage.ErrInvalidCursor:
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	default:
+		s.log.WithError(err).Error("Query events failed")
+		return nil, status.Error(codes.Internal, "unable to query events")
+	}
+	resp := &ListResponse{
+		Events:     make([]*Event, 0, len(page.Events)),
+		NextCursor: page.NextCursor,
+	}
+	for _, event := range page.Events {
+		resp.Events = append(resp.Events, eventToProto(event))
+	}
+	return resp, nil
+}
+
+// Watch streams records published to hub. Records kept in hub history are replayed first if resume position is specified.
+func (s *Server) Watch(req *WatchRequest, srv KubeEvents_WatchServer) error {
+	filter := stream.Filter{
+		Collections: req.Collection,
+		Namespace:   req.ResourceNamespace,
+	}
+	for _, resourceType := range req.ResourceType {
+		filter.ResourceTypes = append(filter.ResourceTypes, kubeClientModel.ResourceType(resourceType))
+	}
+	for _, kind := range req.EventKind {
+		filter.Kinds = append(filter.Kinds, kubeClientModel.EventKind(kind))
+	}
+	resume := stream.Resume{LastID: req.LastId}
+	if req.Since != nil {
+		var err error
+		if resume.Since, err = ptypes.Timestamp(req.Since); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid since: %v", err)
+		}
+	}
+
+	sub, replay, _ := s.cfg.Hub.Subscribe(filter, resume)
+	defer sub.Cancel()
+
+	for _, record := range replay {
+		if err := srv.Send(recordToProto(record)); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case record, ok := <-sub.C():
+			if !ok {
+				if sub.Overflowed() {
+					return status.Error(codes.ResourceExhausted, slowConsumerMessage)
+				}
+				return nil
+			}
+			if err := srv.Send(recordToProto(record)); err != nil {
+				return err
+			}
+		case <-srv.Context().Done():
+			return srv.Context().Err()
+		}
+	}
+}
+
+// Stats returns counters of published records and subscribers.
+func (s *Server) Stats(ctx context.Context, req *StatsRequest) (*StatsResponse, error) {
+	stats := s.cfg.Hub.Stats()
+	return &StatsResponse{
+		Published:             stats.Published,
+		Subscribers:           stats.Subscribers,
+		DroppedSubscribers:    stats.DroppedSubscribers,
+		PublishedByCollection: stats.PublishedByCollection,
+	}, nil
+}
+
+func eventQuery(req *ListRequest) (storage.EventQuery, error) {
+	query := storage.EventQuery{
+		Collection:        req.Collection,
+		ResourceType:      kubeClientModel.ResourceType(req.ResourceType),
+		ResourceNamespace: req.ResourceNamespace,
+		ResourceName:      req.ResourceName,
+		ResourceUID:       req.ResourceUid,
+		Name:              req.EventName,
+		MinOccurrences:    int(req.MinOccurrences),
+		Limit:             int(req.Limit),
+		Cursor:            req.Cursor,
+	}
+	for _, kind := range req.EventKind {
+		query.Kinds = append(query.Kinds, kubeClientModel.EventKind(kind))
+	}
+
+	var err error
+	if query.Since, err = timeFromProto(req.Since); err != nil {
+		return query, status.Errorf(codes.InvalidArgument, "invalid since: %v", err)
+	}
+	if query.Until, err = timeFromProto(req.Until); err != nil {
+		return query, status.Errorf(codes.InvalidArgument, "invalid until: %v", err)
+	}
+	if query.SeenSince, err = timeFromProto(req.SeenSince); err != nil {
+		return query, status.Errorf(codes.InvalidArgument, "invalid seen_since: %v", err)
+	}
+	return query, nil
+}
+
+// timeFromProto returns zero time for nil timestamp.
+func timeFromProto(ts *timestamp.Timestamp) (time.Time, error) {
+	if ts == nil {
+		return time.Time{}, nil
+	}
+	return ptypes.Timestamp(ts)
+}
+
+func eventToProto(event kubeClientModel.Event) *Event {
+	pb := &Event{
+		EventKind:         string(event.Kind),
+		EventTime:         event.Time,
+		EventName:         event.Name,
+		ResourceType:      string(event.ResourceType),
+		ResourceName:      event.ResourceName,
+		ResourceNamespace: event.ResourceNamespace,
+		ResourceUid:       event.ResourceUID,
+		Message:           event.Message,
+		Details:           event.Details,
+	}
+	if !event.DateAdded.IsZero() {
+		pb.DateAdded, _ = ptypes.TimestampProto(event.DateAdded)
+	}
+	return pb
+}
+
+func recordToProto(record stream.Record) *WatchResponse {
+	return &WatchResponse{
+		Id:         record.ID,
+		Collection: record.Collection,
+		Event:      eventToProto(record.Event),
+	}
+}
+
+func validToken(ctx context.Context, tokens []string) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, header := range md.Get("authorization") {
+		if !strings.HasPrefix(header, "Bearer ") {
+			continue
+		}
+		token := strings.TrimPrefix(header, "Bearer ")
+		for _, allowed := range tokens {
+			if token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(allowed)) == 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RequireToken returns server options which reject calls without "authorization: Bearer <token>" metadata
+// with one of tokens. All calls are accepted if tokens are empty.
+func RequireToken(tokens []string) []grpc.ServerOption {
+	if len(tokens) == 0 {
+		return nil
+	}
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if !validToken(ctx, tokens) {
+				return nil, status.Error(codes.Unauthenticated, "invalid token")
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if !validToken(ss.Context(), tokens) {
+				return status.Error(codes.Unauthenticated, "invalid token")
+			}
+			return handler(srv, ss)
+		}),
+	}
+}
@@ -0,0 +1,167 @@
+package eventsapi
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/storage"
+	"github.com/containerum/kube-events/pkg/stream"
+	"github.com/golang/protobuf/ptypes"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type testQuerier struct {
+	queries []storage.EventQuery
+	page    storage.EventPage
+	err     error
+}
+
+func (q *testQuerier) QueryEvents(query storage.EventQuery) (storage.EventPage, error) {
+	q.queries = append(q.queries, query)
+	return q.page, q.err
+}
+
+func startServer(t *testing.T, cfg ServerConfig, tokens []string) (client KubeEventsClient, stop func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(RequireToken(tokens)...)
+	RegisterKubeEventsServer(srv, NewServer(cfg))
+	go srv.Serve(listener)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		srv.Stop()
+		t.Fatal(err)
+	}
+	return NewKubeEventsClient(conn), func() {
+		conn.Close()
+		srv.Stop()
+	}
+}
+
+func withToken(token string) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token), cancel
+}
+
+func TestList(t *testing.T) {
+	added := time.Date(2018, 10, 1, 0, 0, 0, 0, time.UTC)
+	querier := &testQuerier{page: storage.EventPage{
+		Events:     []kubeClientModel.Event{{Name: "a", Kind: kubeClientModel.EventWarning, DateAdded: added}},
+		NextCursor: "next",
+	}}
+	client, stop := startServer(t, ServerConfig{Hub: stream.NewHub(stream.HubConfig{}), Querier: querier}, []string{"secret"})
+	defer stop()
+	ctx, cancel := withToken("secret")
+	defer cancel()
+
+	since, _ := ptypes.TimestampProto(added)
+	resp, err := client.List(ctx, &ListRequest{
+		Collection:     "events",
+		ResourceType:   "pod",
+		EventKind:      []string{"warning", "error"},
+		Since:          since,
+		MinOccurrences: 5,
+		Limit:          50,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Events) != 1 || resp.Events[0].EventName != "a" || resp.Events[0].EventKind != "warning" ||
+		resp.NextCursor != "next" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	if dateAdded, _ := ptypes.Timestamp(resp.Events[0].DateAdded); !dateAdded.Equal(added) {
+		t.Errorf("unexpected date added %v", dateAdded)
+	}
+	query := querier.queries[0]
+	if query.Collection != "events" || query.ResourceType != "pod" || len(query.Kinds) != 2 ||
+		!query.Since.Equal(added) || !query.Until.IsZero() || query.MinOccurrences != 5 || query.Limit != 50 {
+		t.Errorf("unexpected query %+v", query)
+	}
+
+	querier.err = storage.ErrUnknownCollection
+	if _, err := client.List(ctx, &ListRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected invalid argument, got %v", err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	hub := stream.NewHub(stream.HubConfig{History: 10, SubscriberBuffer: 10})
+	client, stop := startServer(t, ServerConfig{Hub: hub}, nil)
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	hub.Publish("events", kubeClientModel.Event{Name: "a", ResourceNamespace: "ns"})
+	hub.Publish("events", kubeClientModel.Event{Name: "other", ResourceNamespace: "other"})
+
+	watch, err := client.Watch(ctx, &WatchRequest{ResourceNamespace: "ns", Since: ptypes.TimestampNow()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// records received before since are not replayed
+	hub.Publish("events", kubeClientModel.Event{Name: "b", ResourceNamespace: "ns"})
+	first, err := watch.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Event.EventName != "b" || first.Collection != "events" || first.Id == "" {
+		t.Fatalf("unexpected record %+v", first)
+	}
+
+	resumed, err := client.Watch(ctx, &WatchRequest{ResourceNamespace: "ns", LastId: first.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub.Publish("events", kubeClientModel.Event{Name: "c", ResourceNamespace: "ns"})
+	for _, w := range []KubeEvents_WatchClient{watch, resumed} {
+		record, err := w.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record.Event.EventName != "c" {
+			t.Errorf("unexpected record %+v", record)
+		}
+	}
+
+	stats, err := client.Stats(ctx, &StatsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Published != 4 || stats.Subscribers != 2 || stats.PublishedByCollection["events"] != 4 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	client, stop := startServer(t, ServerConfig{Hub: stream.NewHub(stream.HubConfig{})}, []string{"secret"})
+	defer stop()
+
+	ctx, cancel := withToken("wrong")
+	defer cancel()
+	if _, err := client.Stats(ctx, &StatsRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected unauthenticated, got %v", err)
+	}
+	watch, err := client.Watch(ctx, &WatchRequest{})
+	if err == nil {
+		_, err = watch.Recv()
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected unauthenticated watch, got %v", err)
+	}
+
+	ctx, cancel = withToken("secret")
+	defer cancel()
+	if _, err := client.Stats(ctx, &StatsRequest{}); err != nil {
+		t.Error(err)
+	}
+}
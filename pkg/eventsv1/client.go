@@ -0,0 +1,30 @@
+package eventsv1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NewRESTClient returns client of events.k8s.io/v1 API. Types must be registered in client-go scheme with AddToScheme.
+func NewRESTClient(c *rest.Config) (*rest.RESTClient, error) {
+	config := *c
+	gv := SchemeGroupVersion
+	config.GroupVersion = &gv
+	config.APIPath = "/apis"
+	config.NegotiatedSerializer = serializer.DirectCodecFactory{CodecFactory: scheme.Codecs}
+	if config.UserAgent == "" {
+		config.UserAgent = rest.DefaultKubernetesUserAgent()
+	}
+	return rest.RESTClientFor(&config)
+}
+
+// NewInformer returns informer of Events in all namespaces.
+func NewInformer(client rest.Interface) cache.SharedIndexInformer {
+	listWatch := cache.NewListWatchFromClient(client, "events", metav1.NamespaceAll, fields.Everything())
+	return cache.NewSharedIndexInformer(listWatch, &Event{}, 0,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+}
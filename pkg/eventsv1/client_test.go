@@ -0,0 +1,76 @@
+package eventsv1
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+const testEventList = `{"kind":"EventList","apiVersion":"events.k8s.io/v1","metadata":{"resourceVersion":"10"},"items":[{
+	"metadata":{"name":"pod.1","namespace":"ns","uid":"event-uid","resourceVersion":"9"},
+	"eventTime":"2018-10-01T00:00:00.000000Z",
+	"series":{"count":3,"lastObservedTime":"2018-10-01T00:05:00.000000Z"},
+	"reason":"BackOff","note":"Back-off restarting failed container","type":"Warning",
+	"regarding":{"kind":"Pod","namespace":"ns","name":"pod","uid":"pod-uid"}
+}]}`
+
+func TestInformerDecodesEvents(t *testing.T) {
+	if err := AddToScheme(scheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apis/events.k8s.io/v1/events" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("watch") == "true" {
+			// watch without changes
+			w.WriteHeader(http.StatusOK)
+			<-done
+			return
+		}
+		fmt.Fprint(w, testEventList)
+	}))
+	defer server.Close()
+	// watch connection is not closed by stopped informer, so server does not wait for it
+	defer close(done)
+
+	client, err := NewRESTClient(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	informer := NewInformer(client)
+	stop := make(chan struct{})
+	defer close(stop)
+	go informer.Run(stop)
+	if !cache.WaitForCacheSync(stopAfter(5*time.Second), informer.HasSynced) {
+		t.Fatal("informer is not synced")
+	}
+
+	items := informer.GetStore().List()
+	if len(items) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(items))
+	}
+	event := items[0].(*Event)
+	if event.Regarding.Kind != "Pod" || event.Note == "" || event.Series == nil || event.Series.Count != 3 ||
+		event.Series.LastObservedTime.Minute() != 5 {
+		t.Errorf("unexpected event %+v", event)
+	}
+	if copied := event.DeepCopy(); copied.Series == event.Series {
+		t.Error("series is not copied")
+	}
+}
+
+func stopAfter(timeout time.Duration) <-chan struct{} {
+	stop := make(chan struct{})
+	time.AfterFunc(timeout, func() { close(stop) })
+	return stop
+}
@@ -0,0 +1,50 @@
+// Package eventsv1 provides events.k8s.io/v1 Event types and client, which are missing in vendored client-go.
+// Types are decoded from JSON only, protobuf is not supported.
+package eventsv1
+
+import (
+	apiCore "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Event is a report of an event somewhere in the cluster.
+type Event struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// EventTime is the time when event was first observed.
+	EventTime metav1.MicroTime `json:"eventTime"`
+	// Series is data about the event series this event represents or nil if it's a singleton event.
+	Series              *EventSeries `json:"series,omitempty"`
+	ReportingController string       `json:"reportingController,omitempty"`
+	ReportingInstance   string       `json:"reportingInstance,omitempty"`
+	Action              string       `json:"action,omitempty"`
+	Reason              string       `json:"reason,omitempty"`
+	// Regarding is the object this event is about.
+	Regarding apiCore.ObjectReference `json:"regarding,omitempty"`
+	// Related is the optional secondary object for more complex actions.
+	Related *apiCore.ObjectReference `json:"related,omitempty"`
+	// Note is a human-readable description of the status of this operation.
+	Note string `json:"note,omitempty"`
+	Type string `json:"type,omitempty"`
+
+	// Deprecated fields are filled for compatibility with core/v1 Events.
+	DeprecatedSource         apiCore.EventSource `json:"deprecatedSource,omitempty"`
+	DeprecatedFirstTimestamp metav1.Time         `json:"deprecatedFirstTimestamp,omitempty"`
+	DeprecatedLastTimestamp  metav1.Time         `json:"deprecatedLastTimestamp,omitempty"`
+	DeprecatedCount          int32               `json:"deprecatedCount,omitempty"`
+}
+
+// EventSeries contains information on series of events, i.e. thing that was/is happening continuously for some time.
+type EventSeries struct {
+	Count            int32            `json:"count"`
+	LastObservedTime metav1.MicroTime `json:"lastObservedTime"`
+}
+
+// EventList is a list of Event objects.
+type EventList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []Event `json:"items"`
+}
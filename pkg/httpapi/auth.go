@@ -0,0 +1,42 @@
+package httpapi
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// bearerToken returns token from "Authorization: Bearer <token>" header or "access_token" URL parameter.
+// Parameter is accepted for browser WebSocket and EventSource clients which can't set headers.
+func bearerToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+	return r.URL.Query().Get("access_token")
+}
+
+func validToken(token string, tokens []string) bool {
+	if token == "" {
+		return false
+	}
+	for _, allowed := range tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(allowed)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireToken passes requests with one of tokens to handler. All requests are passed if tokens are empty.
+func RequireToken(tokens []string, handler http.Handler) http.Handler {
+	if len(tokens) == 0 {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !validToken(bearerToken(r), tokens) {
+			writeError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
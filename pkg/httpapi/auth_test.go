@@ -0,0 +1,35 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireToken(t *testing.T) {
+	handler := RequireToken([]string{"first", "second"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	for _, tc := range []struct {
+		url    string
+		header string
+		status int
+	}{
+		{url: "/", status: http.StatusUnauthorized},
+		{url: "/", header: "Bearer second", status: http.StatusNoContent},
+		{url: "/", header: "Bearer third", status: http.StatusUnauthorized},
+		{url: "/", header: "first", status: http.StatusUnauthorized},
+		{url: "/?access_token=first", status: http.StatusNoContent},
+		{url: "/?access_token=", status: http.StatusUnauthorized},
+	} {
+		r := httptest.NewRequest(http.MethodGet, tc.url, nil)
+		if tc.header != "" {
+			r.Header.Set("Authorization", tc.header)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != tc.status {
+			t.Errorf("%s %q: expected status %d, got %d", tc.url, tc.header, tc.status, w.Code)
+		}
+	}
+}
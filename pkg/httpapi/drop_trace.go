@@ -0,0 +1,50 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/containerum/kube-events/pkg/transform"
+)
+
+// ParseDropTraceQuery reads query of drop traces from URL parameters.
+func ParseDropTraceQuery(r *http.Request) (transform.DropTraceQuery, error) {
+	params := r.URL.Query()
+	query := transform.DropTraceQuery{
+		Resource:  params.Get("resource"),
+		Namespace: params.Get("namespace"),
+		Name:      params.Get("name"),
+		Predicate: params.Get("predicate"),
+	}
+	var err error
+	if since := params.Get("since"); since != "" {
+		if query.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return query, badRequest("invalid since: %v", err)
+		}
+	}
+	if limit := params.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil {
+			return query, badRequest("invalid limit: %v", err)
+		}
+	}
+	return query, nil
+}
+
+// NewDropTraceHandler serves last traces of events dropped by filters, newest first.
+//
+// GET ?resource=Event&namespace=ns&name=pod-1.15a3&predicate=event_reason&since=2018-10-01T00:00:00Z&limit=50
+func NewDropTraceHandler(traces func(query transform.DropTraceQuery) []transform.DropTrace) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		query, err := ParseDropTraceQuery(r)
+		if err != nil {
+			writeErr(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, traces(query))
+	})
+}
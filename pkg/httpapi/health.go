@@ -0,0 +1,21 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"github.com/containerum/kube-events/pkg/storage"
+)
+
+// NewSinkHealthHandler serves storages health. Status is 503 if any primary storage is unhealthy.
+func NewSinkHealthHandler(health func() []storage.SinkHealth) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sinks := health()
+		status := http.StatusOK
+		for _, sink := range sinks {
+			if sink.Primary && !sink.Healthy {
+				status = http.StatusServiceUnavailable
+			}
+		}
+		writeJSON(w, status, sinks)
+	})
+}
@@ -0,0 +1,145 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	maxIngestBodySize = 1 << 20
+	maxIngestEvents   = 1000
+)
+
+// RouteFunc returns collection for ingested event or error if event is not accepted.
+type RouteFunc func(event kubeClientModel.Event) (string, error)
+
+type IngestHandlerConfig struct {
+	// Tokens are accepted bearer tokens.
+	Tokens []string
+	Route  RouteFunc
+	// Collectors receive accepted events for each collection.
+	Collectors map[string]chan<- kubeClientModel.Event
+	// Timeout limits time of waiting for collectors.
+	Timeout time.Duration
+}
+
+type ingestHandler struct {
+	cfg IngestHandlerConfig
+	log *log.Entry
+}
+
+// NewIngestHandler accepts events from other services.
+//
+// POST with "Authorization: Bearer <token>" and kubeClientModel.Event or kubeClientModel.EventsList body.
+func NewIngestHandler(cfg IngestHandlerConfig) http.Handler {
+	return &ingestHandler{
+		cfg: cfg,
+		log: log.WithField("component", "ingest_api"),
+	}
+}
+
+func (h *ingestHandler) authorized(r *http.Request) bool {
+	return validToken(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), h.cfg.Tokens)
+}
+
+// ingestBody is a single event or events list
+type ingestBody struct {
+	kubeClientModel.Event
+	Events []kubeClientModel.Event `json:"events"`
+}
+
+// ValidateEvent checks required fields of ingested event and sets event time if it is empty.
+func ValidateEvent(event *kubeClientModel.Event) error {
+	switch event.Kind {
+	case kubeClientModel.EventError, kubeClientModel.EventWarning, kubeClientModel.EventInfo:
+		//pass
+	default:
+		return badRequest("invalid event_kind %q", event.Kind)
+	}
+	if event.Name == "" {
+		return badRequest("event_name is required")
+	}
+	if event.ResourceType == "" {
+		return badRequest("resource_type is required")
+	}
+	if event.Time == "" {
+		event.Time = time.Now().Format(time.RFC3339)
+	} else if _, err := time.Parse(time.RFC3339, event.Time); err != nil {
+		return badRequest("invalid event_time: %v", err)
+	}
+	event.DateAdded = time.Time{}
+	return nil
+}
+
+func (h *ingestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !h.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+
+	var body ingestBody
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxIngestBodySize)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body: "+err.Error())
+		return
+	}
+	events := body.Events
+	if events == nil {
+		events = []kubeClientModel.Event{body.Event}
+	}
+	if len(events) > maxIngestEvents {
+		writeError(w, http.StatusRequestEntityTooLarge, "too many events")
+		return
+	}
+
+	collections := make([]string, len(events))
+	for i := range events {
+		if err := ValidateEvent(&events[i]); err != nil {
+			writeErr(w, err)
+			return
+		}
+		collection, err := h.cfg.Route(events[i])
+		if err != nil {
+			writeErr(w, err)
+			return
+		}
+		if _, ok := h.cfg.Collectors[collection]; !ok {
+			writeError(w, http.StatusBadRequest, "events are not accepted for collection "+collection)
+			return
+		}
+		collections[i] = collection
+	}
+
+	timeout := time.NewTimer(h.cfg.Timeout)
+	defer timeout.Stop()
+	for i, event := range events {
+		select {
+		case h.cfg.Collectors[collections[i]] <- event:
+		case <-timeout.C:
+			h.log.WithField("accepted", i).Error("Ingest timed out")
+			writeJSON(w, http.StatusServiceUnavailable, ingestResponse{Accepted: i, Error: "ingestion is overloaded"})
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+	writeJSON(w, http.StatusAccepted, ingestResponse{Accepted: len(events)})
+}
+
+type ingestResponse struct {
+	Accepted int    `json:"accepted"`
+	Error    string `json:"error,omitempty"`
+}
+
+// RouteError makes RouteFunc error which is returned to client.
+func RouteError(format string, args ...interface{}) error {
+	return badRequest(format, args...)
+}
@@ -0,0 +1,96 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// ParseEventQuery reads query of stored events from URL parameters.
+// Parameters have same names as kubeClientModel.Event JSON fields.
+func ParseEventQuery(r *http.Request) (storage.EventQuery, error) {
+	params := r.URL.Query()
+	query := storage.EventQuery{
+		Collection:        params.Get("collection"),
+		ResourceType:      kubeClientModel.ResourceType(params.Get("resource_type")),
+		ResourceNamespace: params.Get("resource_namespace"),
+		ResourceName:      params.Get("resource_name"),
+		ResourceUID:       params.Get("resource_uid"),
+		Name:              params.Get("event_name"),
+		Cursor:            params.Get("cursor"),
+	}
+	for _, kind := range params["event_kind"] {
+		query.Kinds = append(query.Kinds, kubeClientModel.EventKind(kind))
+	}
+
+	var err error
+	if since := params.Get("since"); since != "" {
+		if query.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return query, badRequest("invalid since: %v", err)
+		}
+	}
+	if until := params.Get("until"); until != "" {
+		if query.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			return query, badRequest("invalid until: %v", err)
+		}
+	}
+	if seenSince := params.Get("seen_since"); seenSince != "" {
+		if query.SeenSince, err = time.Parse(time.RFC3339, seenSince); err != nil {
+			return query, badRequest("invalid seen_since: %v", err)
+		}
+	}
+	if minOccurrences := params.Get("min_occurrences"); minOccurrences != "" {
+		if query.MinOccurrences, err = strconv.Atoi(minOccurrences); err != nil {
+			return query, badRequest("invalid min_occurrences: %v", err)
+		}
+	}
+	if limit := params.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil {
+			return query, badRequest("invalid limit: %v", err)
+		}
+	}
+	return query, nil
+}
+
+type queryHandler struct {
+	querier storage.EventQuerier
+	log     *log.Entry
+}
+
+// NewQueryHandler serves pages of stored events.
+//
+// GET ?collection=events&resource_type=pod&resource_namespace=ns&event_kind=warning&since=2018-10-01T00:00:00Z&limit=50
+//
+// Kubernetes events may be also selected by series with min_occurrences=5&seen_since=2018-10-01T00:00:00Z.
+func NewQueryHandler(querier storage.EventQuerier) http.Handler {
+	return &queryHandler{
+		querier: querier,
+		log:     log.WithField("component", "query_api"),
+	}
+}
+
+func (h *queryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	query, err := ParseEventQuery(r)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	page, err := h.querier.QueryEvents(query)
+	switch err {
+	case nil:
+		writeJSON(w, http.StatusOK, page)
+	case storage.ErrUnknownCollection, storage.ErrInvalidCursor:
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		h.log.WithError(err).Error("Query events failed")
+		writeError(w, http.StatusInternalServerError, "unable to query events")
+	}
+}
@@ -0,0 +1,66 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/storage"
+)
+
+type testQuerier struct {
+	queries []storage.EventQuery
+	page    storage.EventPage
+	err     error
+}
+
+func (q *testQuerier) QueryEvents(query storage.EventQuery) (storage.EventPage, error) {
+	q.queries = append(q.queries, query)
+	return q.page, q.err
+}
+
+func TestQueryHandler(t *testing.T) {
+	querier := &testQuerier{page: storage.EventPage{Events: []kubeClientModel.Event{{Name: "a"}}, NextCursor: "next"}}
+	handler := NewQueryHandler(querier)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet,
+		"/?collection=events&resource_type=pod&event_kind=warning&event_kind=error&since=2018-10-01T00:00:00Z&limit=50", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body)
+	}
+	var page storage.EventPage
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Events) != 1 || page.NextCursor != "next" {
+		t.Errorf("unexpected page %+v", page)
+	}
+	query := querier.queries[0]
+	if query.Collection != "events" || query.ResourceType != "pod" || len(query.Kinds) != 2 ||
+		query.Limit != 50 || query.Since.Year() != 2018 {
+		t.Errorf("unexpected query %+v", query)
+	}
+
+	for url, status := range map[string]int{
+		"/?since=yesterday":      http.StatusBadRequest,
+		"/?limit=many":           http.StatusBadRequest,
+		"/?seen_since=now":       http.StatusBadRequest,
+		"/?min_occurrences=many": http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		if w.Code != status {
+			t.Errorf("%s: expected status %d, got %d", url, status, w.Code)
+		}
+	}
+
+	querier.err = storage.ErrUnknownCollection
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?collection=other", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,393 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/storage"
+	"github.com/containerum/kube-events/pkg/stream"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	streamBackfillLimit = storage.MaxQueryLimit
+	slowConsumerMessage = "subscriber is too slow, reconnect with last received id"
+)
+
+type StreamHandlerConfig struct {
+	Hub *stream.Hub
+	// Querier is used to send stored records when subscription is resumed by timestamp.
+	Querier storage.EventQuerier
+	// Collections are queried if subscriber does not specify collections.
+	Collections []string
+	KeepAlive   time.Duration
+}
+
+type streamHandler struct {
+	cfg StreamHandlerConfig
+	log *log.Entry
+}
+
+// NewStreamHandler streams transformed records over WebSocket or Server-Sent Events.
+//
+// GET ?collection=events&resource_namespace=ns&resource_type=pod&event_kind=warning&last_id=...&since=2018-10-01T00:00:00Z
+//
+// Subscription is resumed after last_id (or Last-Event-ID header). If it is unknown, stored and recent records added after since are sent.
+// If there are too many stored records, "truncated" notice is sent before them.
+func NewStreamHandler(cfg StreamHandlerConfig) http.Handler {
+	return &streamHandler{
+		cfg: cfg,
+		log: log.WithField("component", "stream_api"),
+	}
+}
+
+// ParseStreamFilter reads subscription filter from URL parameters.
+func ParseStreamFilter(r *http.Request) stream.Filter {
+	params := r.URL.Query()
+	filter := stream.Filter{
+		Collections: params["collection"],
+		Namespace:   params.Get("resource_namespace"),
+	}
+	for _, resourceType := range params["resource_type"] {
+		filter.ResourceTypes = append(filter.ResourceTypes, kubeClientModel.ResourceType(resourceType))
+	}
+	for _, kind := range params["event_kind"] {
+		filter.Kinds = append(filter.Kinds, kubeClientModel.EventKind(kind))
+	}
+	return filter
+}
+
+func parseResume(r *http.Request) (stream.Resume, error) {
+	resume := stream.Resume{
+		LastID: r.URL.Query().Get("last_id"),
+	}
+	if resume.LastID == "" {
+		resume.LastID = r.Header.Get("Last-Event-ID")
+	}
+	if since := r.URL.Query().Get("since"); since != "" {
+		var err error
+		if resume.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return resume, badRequest("invalid since: %v", err)
+		}
+	}
+	return resume, nil
+}
+
+// backfill returns stored records added after since, oldest first.
+// Truncated is true if collection has more than streamBackfillLimit records, then the oldest ones are not returned.
+func (h *streamHandler) backfill(filter stream.Filter, since time.Time) (records []stream.Record, truncated bool, err error) {
+	collections := filter.Collections
+	if len(collections) == 0 {
+		collections = h.cfg.Collections
+	}
+	for _, collection := range collections {
+		query := storage.EventQuery{
+			Collection:        collection,
+			ResourceNamespace: filter.Namespace,
+			Kinds:             filter.Kinds,
+			Since:             since,
+			Limit:             storage.MaxQueryLimit,
+		}
+		if len(filter.ResourceTypes) == 1 {
+			query.ResourceType = filter.ResourceTypes[0]
+		}
+		for collected := 0; ; {
+			page, err := h.cfg.Querier.QueryEvents(query)
+			if err != nil {
+				return nil, false, err
+			}
+			for _, event := range page.Events {
+				record := stream.Record{Collection: collection, Event: event}
+				if filter.Match(record) {
+					records = append(records, record)
+				}
+			}
+			collected += len(page.Events)
+			if page.NextCursor == "" {
+				break
+			}
+			if collected >= streamBackfillLimit {
+				truncated = true
+				break
+			}
+			query.Cursor = page.NextCursor
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Event.DateAdded.Before(records[j].Event.DateAdded)
+	})
+	return records, truncated, nil
+}
+
+type backfillResult struct {
+	records   []stream.Record
+	truncated bool
+	err       error
+}
+
+// backfillSubscribed queries stored records and buffers records published meanwhile, so subscription
+// does not overflow during long backfill.
+func (h *streamHandler) backfillSubscribed(sub *stream.Subscription, filter stream.Filter, since time.Time) (stored, received []stream.Record, truncated bool, err error) {
+	done := make(chan backfillResult, 1)
+	go func() {
+		records, truncated, err := h.backfill(filter, since)
+		done <- backfillResult{records: records, truncated: truncated, err: err}
+	}()
+	records := sub.C()
+	for {
+		select {
+		case result := <-done:
+			return result.records, received, result.truncated, result.err
+		case record, ok := <-records:
+			if !ok {
+				// subscription is cancelled, it is reported after backfill is sent
+				records = nil
+				continue
+			}
+			received = append(received, record)
+		}
+	}
+}
+
+// recordKey identifies record content. Time of adding is not serialized, so stored record and
+// the same record from hub history have equal keys.
+func recordKey(record stream.Record) string {
+	data, _ := json.Marshal(record.Event)
+	return record.Collection + "\x00" + string(data)
+}
+
+// withoutReplayed returns stored records which are not in replayed hub records.
+// Stored records don't have hub IDs, so records are matched by content. Equal records are counted,
+// one stored record is skipped for every replayed one, so legitimately repeated records are kept.
+func withoutReplayed(stored []stream.Record, replays ...[]stream.Record) []stream.Record {
+	replayed := make(map[string]int)
+	for _, replay := range replays {
+		for _, record := range replay {
+			replayed[recordKey(record)]++
+		}
+	}
+	if len(stored) == 0 || len(replayed) == 0 {
+		return stored
+	}
+	// the newest stored records are the ones which may be also replayed
+	skip := make([]bool, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		key := recordKey(stored[i])
+		if replayed[key] > 0 {
+			replayed[key]--
+			skip[i] = true
+		}
+	}
+	unique := make([]stream.Record, 0, len(stored))
+	for i, record := range stored {
+		if !skip[i] {
+			unique = append(unique, record)
+		}
+	}
+	return unique
+}
+
+// truncatedNotice tells subscriber that not all stored records were sent.
+type truncatedNotice struct {
+	Notice  string    `json:"notice"`
+	Message string    `json:"message"`
+	Before  time.Time `json:"before"`
+}
+
+type streamSender interface {
+	Send(record stream.Record) error
+	KeepAlive() error
+	Notice(event string, body interface{}) error
+	Error(message string)
+	Done() <-chan struct{}
+}
+
+func (h *streamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	resume, err := parseResume(r)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	filter := ParseStreamFilter(r)
+
+	sub, replay, resumed := h.cfg.Hub.Subscribe(filter, resume)
+	defer sub.Cancel()
+
+	var stored, received []stream.Record
+	var truncated bool
+	if !resumed && !resume.Since.IsZero() && h.cfg.Querier != nil {
+		if stored, received, truncated, err = h.backfillSubscribed(sub, filter, resume.Since); err != nil {
+			h.log.WithError(err).Error("Unable to query stored records")
+			writeError(w, http.StatusInternalServerError, "unable to query stored records")
+			return
+		}
+		// records received after since are both stored and in hub history
+		stored = withoutReplayed(stored, replay, received)
+	}
+
+	var sender streamSender
+	if isWebSocketUpgrade(r) {
+		ws, err := upgradeWebSocket(w, r)
+		if err != nil {
+			writeErr(w, err)
+			return
+		}
+		defer ws.Close(wsCloseNormal, "")
+		sender = &wsSender{ws: ws}
+	} else {
+		sse, err := newSSESender(w, r)
+		if err != nil {
+			writeErr(w, err)
+			return
+		}
+		sender = sse
+	}
+
+	if truncated {
+		notice := truncatedNotice{
+			Notice:  "truncated",
+			Message: "too many stored records since requested time, older records are not sent",
+		}
+		if len(stored) > 0 {
+			notice.Before = stored[0].Event.DateAdded
+		}
+		if err := sender.Notice(notice.Notice, notice); err != nil {
+			return
+		}
+	}
+	for _, records := range [][]stream.Record{stored, replay, received} {
+		for _, record := range records {
+			if err := sender.Send(record); err != nil {
+				return
+			}
+		}
+	}
+
+	keepAlive := time.NewTicker(h.cfg.KeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case record, ok := <-sub.C():
+			if !ok {
+				if sub.Overflowed() {
+					sender.Error(slowConsumerMessage)
+				}
+				return
+			}
+			if err := sender.Send(record); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if err := sender.KeepAlive(); err != nil {
+				return
+			}
+		case <-sender.Done():
+			return
+		}
+	}
+}
+
+type wsSender struct {
+	ws *wsConn
+}
+
+func (s *wsSender) Send(record stream.Record) error {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.ws.WriteText(payload)
+}
+
+func (s *wsSender) Notice(event string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return s.ws.WriteText(payload)
+}
+
+func (s *wsSender) KeepAlive() error {
+	return s.ws.Ping()
+}
+
+func (s *wsSender) Error(message string) {
+	s.ws.Close(wsCloseTryAgainLater, message)
+}
+
+func (s *wsSender) Done() <-chan struct{} {
+	return s.ws.Closed()
+}
+
+type sseSender struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	done    <-chan struct{}
+}
+
+func newSSESender(w http.ResponseWriter, r *http.Request) (*sseSender, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("streaming is not supported")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	return &sseSender{
+		w:       w,
+		flusher: flusher,
+		done:    r.Context().Done(),
+	}, nil
+}
+
+func (s *sseSender) Send(record stream.Record) error {
+	data, err := json.Marshal(record.Event)
+	if err != nil {
+		return err
+	}
+	if record.ID != "" {
+		if _, err := fmt.Fprintf(s.w, "id: %s\n", record.ID); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", record.Collection, data); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *sseSender) Notice(event string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *sseSender) KeepAlive() error {
+	if _, err := fmt.Fprint(s.w, ": keepalive\n\n"); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *sseSender) Error(message string) {
+	data, _ := json.Marshal(errorResponse{Error: message})
+	fmt.Fprintf(s.w, "event: error\ndata: %s\n\n", data)
+	s.flusher.Flush()
+}
+
+func (s *sseSender) Done() <-chan struct{} {
+	return s.done
+}
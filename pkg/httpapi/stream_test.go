@@ -0,0 +1,105 @@
+package httpapi
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/storage"
+	"github.com/containerum/kube-events/pkg/stream"
+)
+
+func TestWithoutReplayedKeepsRepeatedRecords(t *testing.T) {
+	record := func(name string) stream.Record {
+		return stream.Record{Collection: "events", Event: kubeClientModel.Event{Name: name}}
+	}
+	stored := []stream.Record{record("a"), record("b"), record("a"), record("c")}
+	replay := []stream.Record{record("a"), record("c")}
+
+	unique := withoutReplayed(stored, replay)
+	var names []string
+	for _, record := range unique {
+		names = append(names, record.Event.Name)
+	}
+	// only the newest stored "a" is replayed
+	if strings.Join(names, ",") != "a,b" {
+		t.Errorf("unexpected records %v", names)
+	}
+}
+
+// blockingQuerier returns stored events after release is closed.
+type blockingQuerier struct {
+	started chan struct{}
+	release chan struct{}
+	events  []kubeClientModel.Event
+}
+
+func (q *blockingQuerier) QueryEvents(query storage.EventQuery) (storage.EventPage, error) {
+	close(q.started)
+	<-q.release
+	return storage.EventPage{Events: q.events}, nil
+}
+
+func TestStreamBuffersRecordsDuringBackfill(t *testing.T) {
+	hub := stream.NewHub(stream.HubConfig{SubscriberBuffer: 1})
+	querier := &blockingQuerier{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+		events:  []kubeClientModel.Event{{Name: "stored"}},
+	}
+	server := httptest.NewServer(NewStreamHandler(StreamHandlerConfig{
+		Hub:         hub,
+		Querier:     querier,
+		Collections: []string{"events"},
+		KeepAlive:   time.Minute,
+	}))
+	defer server.Close()
+
+	responses := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.Get(server.URL + "/?since=2018-10-01T00:00:00Z")
+		if err != nil {
+			t.Error(err)
+			close(responses)
+			return
+		}
+		responses <- resp
+	}()
+
+	<-querier.started
+	const published = 5
+	for i := 0; i < published; i++ {
+		hub.Publish("events", kubeClientModel.Event{Name: "published"})
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(querier.release)
+
+	resp, ok := <-responses
+	if !ok {
+		return
+	}
+	defer resp.Body.Close()
+	var names []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() && len(names) < published+1 {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "event: error") {
+			t.Fatal("subscription overflowed during backfill")
+		}
+		if strings.HasPrefix(line, "data: ") {
+			switch {
+			case strings.Contains(line, `"event_name":"stored"`):
+				names = append(names, "stored")
+			case strings.Contains(line, `"event_name":"published"`):
+				names = append(names, "published")
+			}
+		}
+	}
+	if len(names) != published+1 || names[0] != "stored" {
+		t.Errorf("unexpected records %v", names)
+	}
+}
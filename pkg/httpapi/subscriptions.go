@@ -0,0 +1,291 @@
+package httpapi
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/subscription"
+	log "github.com/sirupsen/logrus"
+)
+
+// Headers set by Containerum API gateway
+const (
+	userIDHeader        = "X-User-ID"
+	userRoleHeader      = "X-User-Role"
+	userNamespaceHeader = "X-User-Namespace"
+)
+
+const maxSubscriptionBodySize = 64 << 10
+
+type SubscriptionHandlerConfig struct {
+	Store subscription.Store
+	// Tokens are accepted from trusted gateway which sets user headers. All requests are rejected if tokens are empty.
+	Tokens []string
+	// AllowPrivateTargets allows webhooks to loopback, link-local and cluster addresses.
+	AllowPrivateTargets bool
+	// OnChange is called after subscription was created, updated or deleted.
+	OnChange func()
+}
+
+type subscriptionHandler struct {
+	cfg SubscriptionHandlerConfig
+	log *log.Entry
+}
+
+// NewSubscriptionHandler serves tenant subscriptions. Tenant is identified by X-User-ID header.
+// Non-admin tenants may subscribe only to namespaces from X-User-Namespace header.
+// User headers are trusted only in requests with one of Tokens, so API must be called through
+// gateway which authenticates users, sets user headers and holds token.
+//
+//	GET    /subscriptions
+//	POST   /subscriptions
+//	GET    /subscriptions/{id}
+//	PUT    /subscriptions/{id}
+//	DELETE /subscriptions/{id}
+//	GET    /subscriptions/{id}/deliveries?failed=true&limit=50
+func NewSubscriptionHandler(cfg SubscriptionHandlerConfig) http.Handler {
+	return &subscriptionHandler{
+		cfg: cfg,
+		log: log.WithField("component", "subscription_api"),
+	}
+}
+
+type tenant struct {
+	id         string
+	admin      bool
+	namespaces []kubeClientModel.UserHeaderData
+}
+
+func tenantFromRequest(r *http.Request) (tenant, bool) {
+	t := tenant{
+		id:    r.Header.Get(userIDHeader),
+		admin: r.Header.Get(userRoleHeader) == "admin",
+	}
+	if t.id == "" {
+		return t, false
+	}
+	if header := r.Header.Get(userNamespaceHeader); header != "" {
+		data, err := base64.StdEncoding.DecodeString(header)
+		if err != nil {
+			return t, false
+		}
+		if err := json.Unmarshal(data, &t.namespaces); err != nil {
+			return t, false
+		}
+	}
+	return t, true
+}
+
+func (t tenant) canAccess(namespace string) bool {
+	if t.admin {
+		return true
+	}
+	for _, ns := range t.namespaces {
+		if ns.ID == namespace && ns.Access != kubeClientModel.None {
+			return true
+		}
+	}
+	return false
+}
+
+func generateSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+func (h *subscriptionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !validToken(bearerToken(r), h.cfg.Tokens) {
+		writeError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	t, ok := tenantFromRequest(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "user is not identified")
+		return
+	}
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/subscriptions"), "/")
+	parts := strings.Split(path, "/")
+	switch {
+	case path == "":
+		switch r.Method {
+		case http.MethodGet:
+			h.list(w, t)
+		case http.MethodPost:
+			h.create(w, r, t)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	case len(parts) == 1:
+		switch r.Method {
+		case http.MethodGet:
+			h.get(w, t, parts[0])
+		case http.MethodPut:
+			h.update(w, r, t, parts[0])
+		case http.MethodDelete:
+			h.delete(w, t, parts[0])
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	case len(parts) == 2 && parts[1] == "deliveries":
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		h.deliveries(w, r, t, parts[0])
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (h *subscriptionHandler) changed() {
+	if h.cfg.OnChange != nil {
+		h.cfg.OnChange()
+	}
+}
+
+func (h *subscriptionHandler) writeStoreError(w http.ResponseWriter, err error) {
+	if err == subscription.ErrNotFound {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	h.log.WithError(err).Error("Subscription store failed")
+	writeError(w, http.StatusInternalServerError, "storage error")
+}
+
+// readSubscription decodes and validates subscription from request body.
+func (h *subscriptionHandler) readSubscription(w http.ResponseWriter, r *http.Request, t tenant) (subscription.Subscription, error) {
+	var sub subscription.Subscription
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSubscriptionBodySize)).Decode(&sub); err != nil {
+		return sub, badRequest("invalid body: %v", err)
+	}
+	if err := sub.Validate(); err != nil {
+		return sub, badRequest("%v", err)
+	}
+	if !h.cfg.AllowPrivateTargets {
+		if err := subscription.CheckTarget(sub.URL); err != nil {
+			return sub, badRequest("url is not allowed: %v", err)
+		}
+	}
+	if !t.canAccess(sub.Namespace) {
+		return sub, badRequest("namespace %q is not accessible", sub.Namespace)
+	}
+	return sub, nil
+}
+
+func (h *subscriptionHandler) list(w http.ResponseWriter, t tenant) {
+	subs, err := h.cfg.Store.ListSubscriptions(t.id)
+	if err != nil {
+		h.writeStoreError(w, err)
+		return
+	}
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	writeJSON(w, http.StatusOK, subs)
+}
+
+func (h *subscriptionHandler) create(w http.ResponseWriter, r *http.Request, t tenant) {
+	sub, err := h.readSubscription(w, r, t)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	if sub.Secret == "" {
+		if sub.Secret, err = generateSecret(); err != nil {
+			writeErr(w, err)
+			return
+		}
+	}
+	sub.ID = subscription.NewID()
+	sub.Tenant = t.id
+	sub.CreatedAt = time.Now().UTC()
+	sub.UpdatedAt = sub.CreatedAt
+	if err := h.cfg.Store.CreateSubscription(sub); err != nil {
+		h.writeStoreError(w, err)
+		return
+	}
+	h.changed()
+	writeJSON(w, http.StatusCreated, sub)
+}
+
+func (h *subscriptionHandler) get(w http.ResponseWriter, t tenant, id string) {
+	sub, err := h.cfg.Store.GetSubscription(t.id, id)
+	if err != nil {
+		h.writeStoreError(w, err)
+		return
+	}
+	sub.Secret = ""
+	writeJSON(w, http.StatusOK, sub)
+}
+
+// update replaces subscription. Secret is kept if it is not set in request.
+func (h *subscriptionHandler) update(w http.ResponseWriter, r *http.Request, t tenant, id string) {
+	old, err := h.cfg.Store.GetSubscription(t.id, id)
+	if err != nil {
+		h.writeStoreError(w, err)
+		return
+	}
+	sub, err := h.readSubscription(w, r, t)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	if sub.Secret == "" {
+		sub.Secret = old.Secret
+	}
+	sub.ID = old.ID
+	sub.Tenant = old.Tenant
+	sub.CreatedAt = old.CreatedAt
+	sub.UpdatedAt = time.Now().UTC()
+	if err := h.cfg.Store.UpdateSubscription(sub); err != nil {
+		h.writeStoreError(w, err)
+		return
+	}
+	h.changed()
+	sub.Secret = ""
+	writeJSON(w, http.StatusOK, sub)
+}
+
+func (h *subscriptionHandler) delete(w http.ResponseWriter, t tenant, id string) {
+	if err := h.cfg.Store.DeleteSubscription(t.id, id); err != nil {
+		h.writeStoreError(w, err)
+		return
+	}
+	h.changed()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *subscriptionHandler) deliveries(w http.ResponseWriter, r *http.Request, t tenant, id string) {
+	query := subscription.DeliveryQuery{
+		Tenant:         t.id,
+		SubscriptionID: id,
+		FailedOnly:     r.URL.Query().Get("failed") == "true",
+	}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		var err error
+		if query.Limit, err = strconv.Atoi(limit); err != nil {
+			writeErr(w, badRequest("invalid limit: %v", err))
+			return
+		}
+	}
+	if _, err := h.cfg.Store.GetSubscription(t.id, id); err != nil {
+		h.writeStoreError(w, err)
+		return
+	}
+	deliveries, err := h.cfg.Store.ListDeliveries(query)
+	if err != nil {
+		h.writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
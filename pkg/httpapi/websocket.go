@@ -0,0 +1,225 @@
+package httpapi
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Minimal server side of RFC 6455. Server only sends text messages, client messages are discarded.
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	wsOpText  = 0x1
+	wsOpClose = 0x8
+	wsOpPing  = 0x9
+	wsOpPong  = 0xA
+
+	wsCloseNormal        = 1000
+	wsCloseTryAgainLater = 1013
+
+	wsMaxClientFrame = 64 << 10
+
+	// wsWriteTimeout limits writing of every frame, so client which does not read does not block sender
+	wsWriteTimeout = 10 * time.Second
+	// wsCloseTimeout limits sending of close frame
+	wsCloseTimeout = time.Second
+)
+
+var errWebSocketClosed = errors.New("websocket closed")
+
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+
+	// writeLock is held by frame writer, it is a channel so Close may skip close frame instead of waiting for writer
+	writeLock chan struct{}
+	closed    chan struct{}
+	once      sync.Once
+}
+
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
+
+func websocketAccept(key string) string {
+	hash := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || key == "" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return nil, badRequest("invalid websocket handshake")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("connection does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	_, err = fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: %s\r\n\r\n", websocketAccept(key))
+	if err == nil {
+		err = rw.Flush()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	ws := &wsConn{
+		conn:      conn,
+		rw:        rw,
+		writeLock: make(chan struct{}, 1),
+		closed:    make(chan struct{}),
+	}
+	go ws.readLoop()
+	return ws, nil
+}
+
+func (ws *wsConn) writeFrame(opcode byte, payload []byte) error {
+	ws.writeLock <- struct{}{}
+	defer func() { <-ws.writeLock }()
+	return ws.writeFrameLocked(opcode, payload, wsWriteTimeout)
+}
+
+func (ws *wsConn) writeFrameLocked(opcode byte, payload []byte, timeout time.Duration) error {
+	if err := ws.conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	header := []byte{0x80 | opcode}
+	switch length := len(payload); {
+	case length < 126:
+		header = append(header, byte(length))
+	case length <= 0xFFFF:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+	if _, err := ws.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := ws.rw.Write(payload); err != nil {
+		return err
+	}
+	return ws.rw.Flush()
+}
+
+func (ws *wsConn) WriteText(payload []byte) error {
+	select {
+	case <-ws.closed:
+		return errWebSocketClosed
+	default:
+		return ws.writeFrame(wsOpText, payload)
+	}
+}
+
+func (ws *wsConn) Ping() error {
+	return ws.writeFrame(wsOpPing, nil)
+}
+
+// Close sends close frame and closes connection. Close frame is not sent if other frame is being written,
+// so Close does not wait for client which does not read.
+func (ws *wsConn) Close(code uint16, reason string) {
+	ws.once.Do(func() {
+		close(ws.closed)
+		select {
+		case ws.writeLock <- struct{}{}:
+			payload := make([]byte, 2, 2+len(reason))
+			binary.BigEndian.PutUint16(payload, code)
+			payload = append(payload, reason...)
+			ws.writeFrameLocked(wsOpClose, payload, wsCloseTimeout)
+			ws.conn.Close()
+			<-ws.writeLock
+		default:
+			// closing connection interrupts blocked writer
+			ws.conn.Close()
+		}
+	})
+}
+
+// Closed is closed when connection is closed by any side.
+func (ws *wsConn) Closed() <-chan struct{} {
+	return ws.closed
+}
+
+func (ws *wsConn) readFrame() (opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(ws.rw, head[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode = head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(ws.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(ws.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(ws.rw, mask[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+	if length > wsMaxClientFrame {
+		// client messages are not used, skip them without buffering
+		_, err = io.CopyN(ioutil.Discard, ws.rw, int64(length))
+		return opcode, nil, err
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(ws.rw, payload); err != nil {
+		return 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return opcode, payload, nil
+}
+
+func (ws *wsConn) readLoop() {
+	defer ws.Close(wsCloseNormal, "")
+	for {
+		opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case wsOpClose:
+			return
+		case wsOpPing:
+			if err := ws.writeFrame(wsOpPong, payload); err != nil {
+				return
+			}
+		}
+	}
+}
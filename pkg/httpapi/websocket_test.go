@@ -0,0 +1,94 @@
+package httpapi
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebSocketHandshake(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgradeWebSocket(w, r)
+		if err != nil {
+			writeErr(w, err)
+			return
+		}
+		ws.WriteText([]byte("hello"))
+		<-ws.Closed()
+	}))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "GET / HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("unexpected status %d", resp.StatusCode)
+	}
+	// example from RFC 6455
+	if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("unexpected accept %q", accept)
+	}
+
+	frame := make([]byte, 7)
+	if _, err := io.ReadFull(reader, frame); err != nil {
+		t.Fatal(err)
+	}
+	if frame[0] != 0x80|wsOpText || frame[1] != 5 || string(frame[2:]) != "hello" {
+		t.Errorf("unexpected frame %v", frame)
+	}
+}
+
+func TestWebSocketCloseDoesNotWaitForWriter(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	ws := &wsConn{
+		conn:      server,
+		rw:        bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)),
+		writeLock: make(chan struct{}, 1),
+		closed:    make(chan struct{}),
+	}
+
+	// client does not read, so writer blocks
+	written := make(chan error, 1)
+	go func() {
+		written <- ws.WriteText(make([]byte, 1<<20))
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		ws.Close(wsCloseTryAgainLater, slowConsumerMessage)
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(wsWriteTimeout / 2):
+		t.Fatal("close is blocked by writer")
+	}
+	select {
+	case err := <-written:
+		if err == nil {
+			t.Error("expected write error")
+		}
+	case <-time.After(wsWriteTimeout / 2):
+		t.Fatal("writer is not interrupted")
+	}
+	if err := ws.WriteText([]byte("late")); err != errWebSocketClosed {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package informerwatch
 import (
 	"sync"
 
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/tools/cache"
@@ -16,7 +17,9 @@ type InformerWatch struct {
 	resultChan chan watch.Event
 }
 
-func NewInformerWatch(informer cache.SharedInformer) *InformerWatch {
+// NewInformerWatch makes watch from informer. onResync (if not nil) is called when informer re-delivers object
+// without changes (same resource version), what happens when informer relists resources.
+func NewInformerWatch(informer cache.SharedInformer, onResync func()) *InformerWatch {
 	iw := &InformerWatch{
 		informer:   informer,
 		stopChan:   make(chan struct{}),
@@ -30,6 +33,9 @@ func NewInformerWatch(informer cache.SharedInformer) *InformerWatch {
 			}
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
+			if onResync != nil && sameResourceVersion(oldObj, newObj) {
+				onResync()
+			}
 			iw.resultChan <- watch.Event{
 				Type:   watch.Modified,
 				Object: newObj.(runtime.Object),
@@ -46,6 +52,18 @@ func NewInformerWatch(informer cache.SharedInformer) *InformerWatch {
 	return iw
 }
 
+func sameResourceVersion(oldObj, newObj interface{}) bool {
+	oldMeta, err := meta.Accessor(oldObj)
+	if err != nil {
+		return false
+	}
+	newMeta, err := meta.Accessor(newObj)
+	if err != nil {
+		return false
+	}
+	return oldMeta.GetResourceVersion() == newMeta.GetResourceVersion()
+}
+
 func (iw *InformerWatch) ResultChan() <-chan watch.Event {
 	return iw.resultChan
 }
@@ -0,0 +1,18 @@
+// Package signature signs webhook payloads sent by storages and subscriptions.
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Header contains payload signature.
+const Header = "X-Kube-Events-Signature"
+
+// Sign returns signature of webhook body in "sha256=<hex HMAC-SHA256>" format.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
@@ -16,12 +17,78 @@ type EventBulkInserter interface {
 	BulkInsert(r []kubeClientModel.Event, collection string) error
 }
 
+// Upsert describes how record should be written in upsert mode.
+type Upsert struct {
+	// Key identifies record in collection. Record is inserted without matching if key is empty.
+	Key map[string]interface{}
+	// Set is a record or fields which will be set.
+	Set interface{}
+	// Inc contains counters which will be incremented.
+	Inc map[string]int
+	// Max contains fields (usually timestamps) which will be updated only if new value is greater.
+	Max map[string]interface{}
+	// SetOnInsert contains fields (i.e. time of adding) which are set only when record is inserted.
+	// Updates don't change them even if they are also fields of Set.
+	SetOnInsert map[string]interface{}
+	// Unique makes upsert which writes Set record only if the same record is not stored, Key is not used.
+	// Storages identify the same record by its fields, i.e. by hash of record.
+	Unique bool
+}
+
+// PartialError is returned by storage which wrote some records of batch. Failed are indexes of records which were not written.
+type PartialError struct {
+	Failed []int
+	Err    error
+}
+
+func (err *PartialError) Error() string {
+	return fmt.Sprintf("%d records are not written: %v", len(err.Failed), err.Err)
+}
+
+// FinalError is returned by storage which already retried failed write or which can't write records at all,
+// so write should not be retried by callers.
+type FinalError struct {
+	Err error
+}
+
+func (err *FinalError) Error() string {
+	return err.Err.Error()
+}
+
+// Final marks error as final. Nil is returned as is.
+func Final(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &FinalError{Err: err}
+}
+
+// IsFinal returns true if err should not be retried.
+func IsFinal(err error) bool {
+	_, ok := err.(*FinalError)
+	return ok
+}
+
+type EventBulkUpserter interface {
+	BulkUpsert(r []Upsert, collection string) error
+}
+
+// UpsertFunc describes how record will be upserted.
+type UpsertFunc func(r kubeClientModel.Event) Upsert
+
 type RecordBufferConfig struct {
 	Storage         EventBulkInserter
 	BufferCap       int
 	InsertPeriod    time.Duration
 	MinInsertEvents int
 	Collector       <-chan kubeClientModel.Event
+	// Upsert enables upsert mode if Storage also implements EventBulkUpserter.
+	Upsert UpsertFunc
+	// WriteRetries is a number of write retries after failure. Delay between retries is doubled starting from RetryDelay.
+	WriteRetries int
+	RetryDelay   time.Duration
+	// OnWrite (if not nil) is called after every write of collected records, err is the last write error.
+	OnWrite func(collection string, records int, err error)
 }
 
 type RecordBuffer struct {
@@ -29,10 +96,17 @@ type RecordBuffer struct {
 
 	bufferMu sync.Mutex
 	buffer   []kubeClientModel.Event
+	// flushed is set by Flush, periodic inserts are not started after it
+	flushed bool
 
 	readStop    chan struct{}
 	insertStop  chan struct{}
+	onceStop    sync.Once
 	insertTimer *time.Ticker
+	// drained is closed when collector is closed and all records are read
+	drained chan struct{}
+	// writes waits for running bulk inserts
+	writes sync.WaitGroup
 
 	log *log.Entry
 }
@@ -51,6 +125,7 @@ func NewRecordBuffer(cfg RecordBufferConfig) *RecordBuffer {
 		readStop:    make(chan struct{}),
 		insertStop:  make(chan struct{}),
 		insertTimer: time.NewTicker(cfg.InsertPeriod),
+		drained:     make(chan struct{}),
 		log:         rbLog,
 	}
 }
@@ -60,6 +135,7 @@ func (rb *RecordBuffer) readRecords() {
 		select {
 		case record, ok := <-rb.cfg.Collector:
 			if !ok {
+				close(rb.drained)
 				return
 			}
 			rb.log.Debugf("Collected record %+v", record)
@@ -80,38 +156,96 @@ func (rb *RecordBuffer) insertRecords(collection string) {
 		case <-rb.insertTimer.C:
 			// get a buffer length and copy slice pointer (it may be replaced in RecordBuffer)
 			rb.bufferMu.Lock()
+			if rb.flushed {
+				rb.bufferMu.Unlock()
+				return
+			}
 			oldBuf := rb.buffer
 			bufLen := len(rb.buffer)
-			rb.bufferMu.Unlock()
-
 			if bufLen < rb.cfg.MinInsertEvents {
+				rb.bufferMu.Unlock()
 				rb.log.Debugf("Wanted minimum %d records to be inserted, collected %d",
 					rb.cfg.MinInsertEvents, bufLen)
 				continue
 			}
 
-			// replace a buffer with empty one
-			newBuf := make([]kubeClientModel.Event, 0, rb.cfg.BufferCap)
-			rb.bufferMu.Lock()
-			rb.buffer = newBuf
+			// replace a buffer with empty one, insert is registered under lock so Flush waits for it
+			rb.buffer = make([]kubeClientModel.Event, 0, rb.cfg.BufferCap)
+			rb.writes.Add(1)
 			rb.bufferMu.Unlock()
 
 			// perform bulk insert
 			go func() {
-				rb.log.Debugf("Inserting %d events", bufLen)
-				dateAdded := time.Now()
-				for i := range oldBuf {
-					oldBuf[i].DateAdded = dateAdded
-				}
-				err := rb.cfg.Storage.BulkInsert(oldBuf, collection)
-				if err != nil {
-					rb.log.WithError(err).Debug("BulkInsert failed")
-				}
+				defer rb.writes.Done()
+				rb.insert(oldBuf, collection)
 			}()
 		}
 	}
 }
 
+func (rb *RecordBuffer) insert(records []kubeClientModel.Event, collection string) {
+	rb.log.Debugf("Inserting %d events", len(records))
+	dateAdded := time.Now()
+	for i := range records {
+		records[i].DateAdded = dateAdded
+	}
+	err := rb.writeWithRetries(records, collection)
+	if err != nil {
+		rb.log.WithError(err).Error("BulkInsert failed")
+	}
+	if rb.cfg.OnWrite != nil {
+		rb.cfg.OnWrite(collection, len(records), err)
+	}
+}
+
+// Drained is closed when collector is closed and all its records are buffered.
+func (rb *RecordBuffer) Drained() <-chan struct{} {
+	return rb.drained
+}
+
+// Flush stops periodic inserts, waits for running inserts and synchronously writes buffered records
+// regardless of MinInsertEvents. Records collected after Flush are not written.
+func (rb *RecordBuffer) Flush(collection string) {
+	rb.bufferMu.Lock()
+	rb.flushed = true
+	records := rb.buffer
+	rb.buffer = make([]kubeClientModel.Event, 0, rb.cfg.BufferCap)
+	rb.bufferMu.Unlock()
+	rb.writes.Wait()
+	if len(records) > 0 {
+		rb.insert(records, collection)
+	}
+}
+
+func (rb *RecordBuffer) write(records []kubeClientModel.Event, collection string) error {
+	upserter, ok := rb.cfg.Storage.(EventBulkUpserter)
+	if !ok || rb.cfg.Upsert == nil {
+		return rb.cfg.Storage.BulkInsert(records, collection)
+	}
+	upserts := make([]Upsert, len(records))
+	for i := range records {
+		upserts[i] = rb.cfg.Upsert(records[i])
+	}
+	return upserter.BulkUpsert(upserts, collection)
+}
+
+// writeWithRetries retries failed writes. After partial failure only records which were not written are retried,
+// so records are not duplicated.
+func (rb *RecordBuffer) writeWithRetries(records []kubeClientModel.Event, collection string) error {
+	retry := Retry{Attempts: rb.cfg.WriteRetries + 1, Delay: rb.cfg.RetryDelay}
+	return retry.Do(rb.log, func() error {
+		err := rb.write(records, collection)
+		if partial, ok := err.(*PartialError); ok {
+			failed := make([]kubeClientModel.Event, 0, len(partial.Failed))
+			for _, i := range partial.Failed {
+				failed = append(failed, records[i])
+			}
+			records = failed
+		}
+		return err
+	})
+}
+
 func (rb *RecordBuffer) RunCollection(collection string) {
 	rb.log.Debug("Starting reading/inserting records")
 	go rb.readRecords()
@@ -120,7 +254,10 @@ func (rb *RecordBuffer) RunCollection(collection string) {
 
 func (rb *RecordBuffer) Stop() {
 	rb.log.Debug("Stopping reading/inserting records")
-	rb.readStop <- struct{}{}
-	rb.insertStop <- struct{}{}
-	rb.insertTimer.Stop()
+	// channels are closed, not sent, so Stop does not block if reading stopped after collector was closed
+	rb.onceStop.Do(func() {
+		close(rb.readStop)
+		close(rb.insertStop)
+		rb.insertTimer.Stop()
+	})
 }
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+)
+
+func TestFlushStopsPeriodicInserts(t *testing.T) {
+	sink := &testSink{}
+	collector := make(chan kubeClientModel.Event, 100)
+	var failed int32
+	rb := NewRecordBuffer(RecordBufferConfig{
+		Storage:      sink,
+		BufferCap:    10,
+		InsertPeriod: time.Millisecond,
+		Collector:    collector,
+		OnWrite: func(collection string, records int, err error) {
+			if err != nil {
+				atomic.AddInt32(&failed, 1)
+			}
+		},
+	})
+	rb.RunCollection("events")
+	for i := 0; i < 100; i++ {
+		collector <- kubeClientModel.Event{Name: fmt.Sprint(i)}
+	}
+	close(collector)
+	<-rb.Drained()
+	rb.Flush("events")
+	sink.Close()
+	// ticks after flush must not write to closed sink
+	time.Sleep(10 * time.Millisecond)
+	rb.Stop()
+
+	if n := atomic.LoadInt32(&failed); n != 0 {
+		t.Errorf("%d writes failed after flush", n)
+	}
+	if records := sink.records(); len(records) != 100 {
+		t.Errorf("expected 100 records, got %d", len(records))
+	}
+}
@@ -0,0 +1,299 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+type Config struct {
+	// URLs of cluster nodes. Next node is used after request failure.
+	URLs []string
+	// Index name is "<IndexPrefix>-<collection>-<date added formatted with IndexDateFormat>".
+	// Date suffix is omitted if IndexDateFormat is empty. Upserts with key are always written to index without date suffix,
+	// so updates of record added on other day replace the same document.
+	IndexPrefix     string
+	IndexDateFormat string
+
+	// Basic auth is used if Username is set, API key auth is used if APIKey is set.
+	Username string
+	Password string
+	APIKey   string
+	Timeout  time.Duration
+
+	// MaxAttempts is a number of attempts of sending items failed with retryable status (429 and 5xx).
+	MaxAttempts int
+	RetryDelay  time.Duration
+}
+
+// Sink writes records with Elasticsearch or OpenSearch bulk API. It implements storage.EventBulkInserter and storage.EventBulkUpserter.
+type Sink struct {
+	cfg    Config
+	client *http.Client
+	next   uint32
+
+	templateMu sync.Mutex
+	// templated is set after index template is updated, documents are not written before it
+	templated bool
+
+	log *log.Entry
+}
+
+// document is an indexed record.
+type document struct {
+	kubeClientModel.Event
+	Collection string    `json:"collection"`
+	DateAdded  time.Time `json:"date_added"`
+}
+
+type bulkItem struct {
+	index string
+	id    string
+	doc   document
+}
+
+func NewSink(cfg Config) (*Sink, error) {
+	if len(cfg.URLs) == 0 {
+		return nil, fmt.Errorf("elasticsearch url is required")
+	}
+	if cfg.IndexPrefix == "" {
+		return nil, fmt.Errorf("elasticsearch index prefix is required")
+	}
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	for i := range cfg.URLs {
+		cfg.URLs[i] = strings.TrimSuffix(cfg.URLs[i], "/")
+	}
+	return &Sink{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		log:    log.WithField("component", "elasticsearch_sink"),
+	}, nil
+}
+
+func (s *Sink) index(collection string, dateAdded time.Time) string {
+	if s.cfg.IndexDateFormat == "" {
+		return s.stableIndex(collection)
+	}
+	return s.cfg.IndexPrefix + "-" + collection + "-" + dateAdded.UTC().Format(s.cfg.IndexDateFormat)
+}
+
+// stableIndex is an index of upserted documents.
+func (s *Sink) stableIndex(collection string) string {
+	return s.cfg.IndexPrefix + "-" + collection
+}
+
+// documentID is a hash of upsert key or of whole record if key is empty,
+// so records written again after failure or replay replace existing documents.
+func documentID(collection string, key map[string]interface{}, record kubeClientModel.Event) string {
+	hash := sha256.New()
+	io.WriteString(hash, collection)
+	if len(key) > 0 {
+		fields := make([]string, 0, len(key))
+		for field := range key {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			fmt.Fprintf(hash, "\x00%s=%v", field, key[field])
+		}
+	} else {
+		hash.Write([]byte{0})
+		json.NewEncoder(hash).Encode(record)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (s *Sink) BulkInsert(r []kubeClientModel.Event, collection string) error {
+	items := make([]bulkItem, len(r))
+	for i, record := range r {
+		items[i] = bulkItem{
+			index: s.index(collection, record.DateAdded),
+			id:    documentID(collection, nil, record),
+			doc:   document{Event: record, Collection: collection, DateAdded: record.DateAdded},
+		}
+	}
+	return s.bulk(items, collection)
+}
+
+// BulkUpsert replaces documents identified by upsert key. Counters and maximums are not supported, document is replaced by Upsert.Set.
+// Documents with key are written to index without date suffix. Unique upserts are written as inserted records,
+// which are identified by hash of record.
+func (s *Sink) BulkUpsert(r []storage.Upsert, collection string) error {
+	items := make([]bulkItem, 0, len(r))
+	for _, upsert := range r {
+		record, ok := upsert.Set.(kubeClientModel.Event)
+		if !ok {
+			continue
+		}
+		key := upsert.Key
+		if upsert.Unique {
+			key = nil
+		}
+		index := s.index(collection, record.DateAdded)
+		if len(key) > 0 {
+			index = s.stableIndex(collection)
+		}
+		items = append(items, bulkItem{
+			index: index,
+			id:    documentID(collection, key, record),
+			doc:   document{Event: record, Collection: collection, DateAdded: record.DateAdded},
+		})
+	}
+	return s.bulk(items, collection)
+}
+
+type bulkAction struct {
+	Index bulkActionMeta `json:"index"`
+}
+
+type bulkActionMeta struct {
+	Index string `json:"_index"`
+	ID    string `json:"_id"`
+}
+
+type bulkResponse struct {
+	Errors bool                        `json:"errors"`
+	Items  []map[string]bulkItemResult `json:"items"`
+}
+
+type bulkItemResult struct {
+	ID     string         `json:"_id"`
+	Status int            `json:"status"`
+	Error  *bulkItemError `json:"error,omitempty"`
+}
+
+type bulkItemError struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// bulk sends items and resends items failed with retryable status. Items rejected by cluster (i.e. mapping errors)
+// are not resent, they are returned as final error after other items are written.
+func (s *Sink) bulk(items []bulkItem, collection string) error {
+	if len(items) == 0 {
+		return nil
+	}
+	if err := s.ensureTemplate(); err != nil {
+		return err
+	}
+	var rejected []string
+	retry := storage.Retry{Attempts: s.cfg.MaxAttempts, Delay: s.cfg.RetryDelay}
+	err := retry.Do(s.log.WithField("collection", collection), func() error {
+		failed, reasons, err := s.send(items)
+		rejected = append(rejected, reasons...)
+		if len(failed) == 0 {
+			return err
+		}
+		items = failed
+		if err == nil {
+			err = fmt.Errorf("%d items failed", len(failed))
+		}
+		return err
+	})
+	if err == nil && len(rejected) > 0 {
+		return storage.Final(fmt.Errorf("%d documents rejected, first: %s", len(rejected), rejected[0]))
+	}
+	return err
+}
+
+// send returns items failed with retryable status and reasons of rejected items.
+func (s *Sink) send(items []bulkItem) (failed []bulkItem, rejected []string, err error) {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, item := range items {
+		action := bulkAction{Index: bulkActionMeta{Index: item.index, ID: item.id}}
+		if err := enc.Encode(action); err != nil {
+			return nil, nil, err
+		}
+		if err := enc.Encode(item.doc); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	resp, err := s.do(http.MethodPost, "/_bulk", "application/x-ndjson", body.Bytes())
+	if err != nil {
+		return items, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		if retryableStatus(resp.StatusCode) {
+			return items, nil, fmt.Errorf("bulk request returned %s", resp.Status)
+		}
+		return nil, nil, storage.Final(fmt.Errorf("bulk request returned %s", resp.Status))
+	}
+	var result bulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return items, nil, err
+	}
+	if !result.Errors {
+		return nil, nil, nil
+	}
+
+	for i, resultItem := range result.Items {
+		if i >= len(items) {
+			break
+		}
+		for _, status := range resultItem {
+			switch {
+			case status.Status < 300:
+				//pass
+			case retryableStatus(status.Status):
+				failed = append(failed, items[i])
+			default:
+				reason := ""
+				if status.Error != nil {
+					reason = status.Error.Type + ": " + status.Error.Reason
+				}
+				s.log.WithFields(log.Fields{
+					"id":     status.ID,
+					"status": status.Status,
+					"reason": reason,
+				}).Error("Document rejected")
+				rejected = append(rejected, fmt.Sprintf("%s: status %d %s", status.ID, status.Status, reason))
+			}
+		}
+	}
+	return failed, rejected, nil
+}
+
+// do sends request to next cluster node.
+func (s *Sink) do(method, path, contentType string, body []byte) (*http.Response, error) {
+	url := s.cfg.URLs[int(atomic.LoadUint32(&s.next))%len(s.cfg.URLs)] + path
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	switch {
+	case s.cfg.APIKey != "":
+		req.Header.Set("Authorization", "ApiKey "+s.cfg.APIKey)
+	case s.cfg.Username != "":
+		req.SetBasicAuth(s.cfg.Username, s.cfg.Password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil || resp.StatusCode >= 500 {
+		atomic.AddUint32(&s.next, 1)
+	}
+	return resp, err
+}
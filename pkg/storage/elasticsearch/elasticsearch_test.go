@@ -0,0 +1,209 @@
+package elasticsearch
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/storage"
+)
+
+// testCluster stores documents by index and id. Items are answered with statuses from reply (200 if not set).
+// Template requests are answered with templateStatus (200 if not set).
+type testCluster struct {
+	mu             sync.Mutex
+	documents      map[string]map[string]document
+	requests       int
+	reply          func(request, item int) int
+	templates      int
+	templateStatus int
+}
+
+func (c *testCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut && r.URL.Path == "/_index_template/kube-events" {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.templateStatus != 0 {
+			w.WriteHeader(c.templateStatus)
+			return
+		}
+		c.templates++
+		return
+	}
+	if r.Method != http.MethodPost || r.URL.Path != "/_bulk" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests++
+	var response bulkResponse
+	scanner := bufio.NewScanner(r.Body)
+	for item := 0; scanner.Scan(); item++ {
+		var action bulkAction
+		if err := json.Unmarshal(scanner.Bytes(), &action); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var doc document
+		if !scanner.Scan() || json.Unmarshal(scanner.Bytes(), &doc) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		status := http.StatusOK
+		if c.reply != nil {
+			status = c.reply(c.requests, item)
+		}
+		result := bulkItemResult{ID: action.Index.ID, Status: status}
+		if status < 300 {
+			if c.documents[action.Index.Index] == nil {
+				c.documents[action.Index.Index] = make(map[string]document)
+			}
+			c.documents[action.Index.Index][action.Index.ID] = doc
+		} else {
+			response.Errors = true
+			result.Error = &bulkItemError{Type: "mapper_parsing_exception", Reason: "failed to parse"}
+		}
+		response.Items = append(response.Items, map[string]bulkItemResult{"index": result})
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func newTestSink(t *testing.T, cluster *testCluster) (*Sink, func()) {
+	cluster.documents = make(map[string]map[string]document)
+	server := httptest.NewServer(cluster)
+	sink, err := NewSink(Config{
+		URLs:            []string{server.URL},
+		IndexPrefix:     "kube-events",
+		IndexDateFormat: "2006.01.02",
+		MaxAttempts:     3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sink, server.Close
+}
+
+func TestUpsertIndexIsStable(t *testing.T) {
+	cluster := &testCluster{}
+	sink, stop := newTestSink(t, cluster)
+	defer stop()
+
+	day := time.Date(2018, 7, 1, 23, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		record := kubeClientModel.Event{Name: "BackOff", ResourceUID: "uid", Message: fmt.Sprint(i), DateAdded: day.Add(time.Duration(i) * 2 * time.Hour)}
+		err := sink.BulkUpsert([]storage.Upsert{{Key: map[string]interface{}{"resourceuid": "uid"}, Set: record}}, "events")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.BulkInsert([]kubeClientModel.Event{{Name: "Created", DateAdded: day}}, "deployments"); err != nil {
+		t.Fatal(err)
+	}
+
+	events := cluster.documents["kube-events-events"]
+	if len(cluster.documents) != 2 || len(events) != 1 {
+		t.Fatalf("expected one upserted document in stable index, got %v", cluster.documents)
+	}
+	for _, doc := range events {
+		if doc.Message != "1" {
+			t.Errorf("expected document to be replaced by last upsert, got message %q", doc.Message)
+		}
+	}
+	if len(cluster.documents["kube-events-deployments-2018.07.01"]) != 1 {
+		t.Errorf("expected inserted document in dated index, got %v", cluster.documents)
+	}
+}
+
+func TestUniqueUpsertReplacesInsertedRecord(t *testing.T) {
+	cluster := &testCluster{}
+	sink, stop := newTestSink(t, cluster)
+	defer stop()
+
+	record := kubeClientModel.Event{Name: "Created", ResourceUID: "uid", DateAdded: time.Date(2018, 7, 1, 12, 0, 0, 0, time.UTC)}
+	if err := sink.BulkInsert([]kubeClientModel.Event{record}, "deployments"); err != nil {
+		t.Fatal(err)
+	}
+	// record is imported again
+	if err := sink.BulkUpsert([]storage.Upsert{{Set: record, Unique: true}}, "deployments"); err != nil {
+		t.Fatal(err)
+	}
+	if len(cluster.documents) != 1 || len(cluster.documents["kube-events-deployments-2018.07.01"]) != 1 {
+		t.Errorf("expected one document in dated index, got %v", cluster.documents)
+	}
+}
+
+func TestRetryableItemsAreResent(t *testing.T) {
+	cluster := &testCluster{
+		reply: func(request, item int) int {
+			if request == 1 && item == 1 {
+				return http.StatusTooManyRequests
+			}
+			return http.StatusCreated
+		},
+	}
+	sink, stop := newTestSink(t, cluster)
+	defer stop()
+
+	records := []kubeClientModel.Event{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	if err := sink.BulkInsert(records, "events"); err != nil {
+		t.Fatal(err)
+	}
+	if cluster.requests != 2 {
+		t.Errorf("expected 2 bulk requests, got %d", cluster.requests)
+	}
+	if total := len(cluster.documents["kube-events-events-0001.01.01"]); total != 3 {
+		t.Errorf("expected 3 documents, got %d", total)
+	}
+}
+
+func TestRejectedItemsAreFinalError(t *testing.T) {
+	cluster := &testCluster{
+		reply: func(request, item int) int {
+			if item == 0 {
+				return http.StatusBadRequest
+			}
+			return http.StatusCreated
+		},
+	}
+	sink, stop := newTestSink(t, cluster)
+	defer stop()
+
+	err := sink.BulkInsert([]kubeClientModel.Event{{Name: "a"}, {Name: "b"}}, "events")
+	if !storage.IsFinal(err) {
+		t.Fatalf("expected final error, got %v", err)
+	}
+	if cluster.requests != 1 {
+		t.Errorf("rejected item must not be resent, got %d requests", cluster.requests)
+	}
+}
+
+func TestTemplateIsUpdatedBeforeFirstWrite(t *testing.T) {
+	cluster := &testCluster{templateStatus: http.StatusServiceUnavailable}
+	sink, stop := newTestSink(t, cluster)
+	defer stop()
+
+	records := []kubeClientModel.Event{{Name: "a"}}
+	if err := sink.BulkInsert(records, "events"); err == nil || storage.IsFinal(err) {
+		t.Fatalf("expected retryable error, got %v", err)
+	}
+	if cluster.requests != 0 {
+		t.Fatalf("documents are written without template")
+	}
+
+	cluster.templateStatus = 0
+	for i := 0; i < 2; i++ {
+		if err := sink.BulkInsert(records, "events"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cluster.templates != 1 || cluster.requests != 2 {
+		t.Errorf("expected one template and 2 bulk requests, got %d and %d", cluster.templates, cluster.requests)
+	}
+}
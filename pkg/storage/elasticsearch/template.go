@@ -0,0 +1,78 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+var keywordField = map[string]interface{}{"type": "keyword"}
+
+// mappings index fields filtered and sorted by Mongo indexes as keywords and dates.
+var mappings = map[string]interface{}{
+	"dynamic_templates": []interface{}{
+		map[string]interface{}{
+			"details": map[string]interface{}{
+				"path_match": "details.*",
+				"mapping":    keywordField,
+			},
+		},
+	},
+	"properties": map[string]interface{}{
+		"collection":         keywordField,
+		"event_kind":         keywordField,
+		"event_name":         keywordField,
+		"resource_type":      keywordField,
+		"resource_name":      keywordField,
+		"resource_namespace": keywordField,
+		"resource_uid":       keywordField,
+		"message":            map[string]interface{}{"type": "text"},
+		"event_time":         map[string]interface{}{"type": "date", "ignore_malformed": true},
+		"date_added":         map[string]interface{}{"type": "date"},
+	},
+}
+
+// EnsureTemplate creates or replaces index template applied to all sink indexes.
+// If it is not called, template is updated before the first write.
+func (s *Sink) EnsureTemplate() error {
+	s.templateMu.Lock()
+	defer s.templateMu.Unlock()
+	return s.putTemplate()
+}
+
+// ensureTemplate updates index template if it was not updated, so indexes are not created with dynamic mappings.
+func (s *Sink) ensureTemplate() error {
+	s.templateMu.Lock()
+	defer s.templateMu.Unlock()
+	if s.templated {
+		return nil
+	}
+	return s.putTemplate()
+}
+
+// putTemplate must be called with locked templateMu.
+func (s *Sink) putTemplate() error {
+	body, err := json.Marshal(map[string]interface{}{
+		"index_patterns": []string{s.cfg.IndexPrefix + "-*"},
+		"template": map[string]interface{}{
+			"mappings": mappings,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(http.MethodPut, "/_index_template/"+s.cfg.IndexPrefix, "application/json", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("index template request returned %s: %s", resp.Status, msg)
+	}
+	s.log.WithField("template", s.cfg.IndexPrefix).Info("Index template updated")
+	s.templated = true
+	return nil
+}
@@ -0,0 +1,296 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	log "github.com/sirupsen/logrus"
+)
+
+// FanOutSink describes storage written by FanOut.
+type FanOutSink struct {
+	Name    string
+	Storage EventBulkInserter
+	// Primary sink is written synchronously and its errors are returned to record buffer after retries.
+	// Other sinks are written from own queue, so they never delay or fail writes to primary sinks.
+	Primary bool
+	// Collections and Kinds select records written to sink. Empty fields match all records.
+	Collections []string
+	Kinds       []kubeClientModel.EventKind
+	// QueueSize is a number of batches queued for secondary sink. Batches are dropped if queue is full.
+	QueueSize int
+	// WriteRetries is a number of sink write retries. Delay between retries is doubled starting from RetryDelay.
+	// Only records which were not written are retried if sink returns PartialError.
+	WriteRetries int
+	RetryDelay   time.Duration
+	// OnDrop (if not nil) is called when batch is dropped because queue is full.
+	OnDrop func(sink, collection string, records int)
+}
+
+func (s FanOutSink) match(collection string, record kubeClientModel.Event) bool {
+	if len(s.Collections) > 0 && !containsString(s.Collections, collection) {
+		return false
+	}
+	if len(s.Kinds) == 0 {
+		return true
+	}
+	for _, kind := range s.Kinds {
+		if kind == record.Kind {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// SinkHealth is a state of FanOut sink.
+type SinkHealth struct {
+	Name           string    `json:"name"`
+	Primary        bool      `json:"primary"`
+	Healthy        bool      `json:"healthy"`
+	Queued         int       `json:"queued"`
+	WrittenBatches uint64    `json:"written_batches"`
+	FailedBatches  uint64    `json:"failed_batches"`
+	DroppedBatches uint64    `json:"dropped_batches"`
+	LastError      string    `json:"last_error,omitempty"`
+	LastErrorTime  time.Time `json:"last_error_time,omitempty"`
+	LastWriteTime  time.Time `json:"last_write_time,omitempty"`
+}
+
+// batch contains records and upserts (in upsert mode) with same indexes.
+type batch struct {
+	collection string
+	records    []kubeClientModel.Event
+	upserts    []Upsert
+}
+
+type fanOutSink struct {
+	cfg   FanOutSink
+	queue chan batch
+
+	mu     sync.Mutex
+	health SinkHealth
+
+	log *log.Entry
+}
+
+// FanOut writes records to several storages with independent queues, retries and health.
+// Every sink is retried separately, so batch is not written again to sinks which succeeded.
+// Errors are returned after retries as FinalError, so record buffer does not retry batch again.
+type FanOut struct {
+	sinks []*fanOutSink
+	wg    sync.WaitGroup
+
+	// mu is held by writes, so queues and sinks are closed by Stop only after writes are finished
+	mu      sync.RWMutex
+	stopped bool
+}
+
+func NewFanOut(sinks []FanOutSink) *FanOut {
+	fanOut := &FanOut{}
+	for _, cfg := range sinks {
+		s := &fanOutSink{
+			cfg: cfg,
+			health: SinkHealth{
+				Name:    cfg.Name,
+				Primary: cfg.Primary,
+				Healthy: true,
+			},
+			log: log.WithFields(log.Fields{
+				"component": "fanout_sink",
+				"sink":      cfg.Name,
+			}),
+		}
+		if !cfg.Primary {
+			s.queue = make(chan batch, cfg.QueueSize)
+			fanOut.wg.Add(1)
+			go func() {
+				defer fanOut.wg.Done()
+				s.run()
+			}()
+		}
+		fanOut.sinks = append(fanOut.sinks, s)
+	}
+	return fanOut
+}
+
+func (f *FanOut) BulkInsert(r []kubeClientModel.Event, collection string) error {
+	return f.write(batch{collection: collection, records: r})
+}
+
+// BulkUpsert passes upserts to sinks which implement EventBulkUpserter. Other sinks receive records from Upsert.Set.
+func (f *FanOut) BulkUpsert(r []Upsert, collection string) error {
+	records := make([]kubeClientModel.Event, len(r))
+	for i, upsert := range r {
+		records[i], _ = upsert.Set.(kubeClientModel.Event)
+	}
+	return f.write(batch{collection: collection, records: records, upserts: r})
+}
+
+func (f *FanOut) write(b batch) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.stopped {
+		return Final(errors.New("storage is stopped"))
+	}
+	var errs []string
+	for _, s := range f.sinks {
+		routed, ok := s.route(b)
+		if !ok {
+			continue
+		}
+		if !s.cfg.Primary {
+			s.enqueue(routed)
+			continue
+		}
+		if err := s.writeWithRetries(routed); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", s.cfg.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return Final(errors.New(strings.Join(errs, "; ")))
+	}
+	return nil
+}
+
+// Health returns state of all sinks.
+func (f *FanOut) Health() []SinkHealth {
+	ret := make([]SinkHealth, 0, len(f.sinks))
+	for _, s := range f.sinks {
+		s.mu.Lock()
+		health := s.health
+		s.mu.Unlock()
+		health.Queued = len(s.queue)
+		ret = append(ret, health)
+	}
+	return ret
+}
+
+// Stop waits for running writes and until queued batches are written to secondary sinks,
+// then closes sinks which implement io.Closer. Writes after Stop fail.
+func (f *FanOut) Stop() {
+	f.mu.Lock()
+	if f.stopped {
+		f.mu.Unlock()
+		return
+	}
+	f.stopped = true
+	for _, s := range f.sinks {
+		if s.queue != nil {
+			close(s.queue)
+		}
+	}
+	f.mu.Unlock()
+	f.wg.Wait()
+	for _, s := range f.sinks {
+		if closer, ok := s.cfg.Storage.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				s.log.WithError(err).Error("Unable to close sink")
+			}
+		}
+	}
+}
+
+// subset returns batch of records with indexes.
+func (b batch) subset(indexes []int) batch {
+	ret := batch{collection: b.collection}
+	for _, i := range indexes {
+		ret.records = append(ret.records, b.records[i])
+		if b.upserts != nil {
+			ret.upserts = append(ret.upserts, b.upserts[i])
+		}
+	}
+	return ret
+}
+
+// route returns batch part selected by sink routing.
+func (s *fanOutSink) route(b batch) (batch, bool) {
+	routed := batch{collection: b.collection}
+	for i, record := range b.records {
+		if !s.cfg.match(b.collection, record) {
+			continue
+		}
+		routed.records = append(routed.records, record)
+		if b.upserts != nil {
+			routed.upserts = append(routed.upserts, b.upserts[i])
+		}
+	}
+	return routed, len(routed.records) > 0
+}
+
+func (s *fanOutSink) write(b batch) error {
+	if upserter, ok := s.cfg.Storage.(EventBulkUpserter); ok && b.upserts != nil {
+		return upserter.BulkUpsert(b.upserts, b.collection)
+	}
+	return s.cfg.Storage.BulkInsert(b.records, b.collection)
+}
+
+func (s *fanOutSink) enqueue(b batch) {
+	select {
+	case s.queue <- b:
+	default:
+		s.mu.Lock()
+		s.health.DroppedBatches++
+		s.mu.Unlock()
+		s.log.WithFields(log.Fields{
+			"collection": b.collection,
+			"records":    len(b.records),
+		}).Error("Sink queue is full, batch dropped")
+		if s.cfg.OnDrop != nil {
+			s.cfg.OnDrop(s.cfg.Name, b.collection, len(b.records))
+		}
+	}
+}
+
+func (s *fanOutSink) run() {
+	for b := range s.queue {
+		if err := s.writeWithRetries(b); err != nil {
+			s.log.WithError(err).WithFields(log.Fields{
+				"collection": b.collection,
+				"records":    len(b.records),
+			}).Error("Sink write failed, batch dropped")
+		}
+	}
+}
+
+// writeWithRetries writes batch and retries records which were not written.
+func (s *fanOutSink) writeWithRetries(b batch) error {
+	retry := Retry{Attempts: s.cfg.WriteRetries + 1, Delay: s.cfg.RetryDelay}
+	err := retry.Do(s.log, func() error {
+		err := s.write(b)
+		if partial, ok := err.(*PartialError); ok {
+			b = b.subset(partial.Failed)
+		}
+		return err
+	})
+	s.onWrite(err)
+	return err
+}
+
+func (s *fanOutSink) onWrite(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.health.Healthy = err == nil
+	if err != nil {
+		s.health.FailedBatches++
+		s.health.LastError = err.Error()
+		s.health.LastErrorTime = now
+		return
+	}
+	s.health.WrittenBatches++
+	s.health.LastWriteTime = now
+}
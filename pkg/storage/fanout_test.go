@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+)
+
+// testSink fails first failures writes. If partial is set, only the first record of batch fails.
+type testSink struct {
+	mu       sync.Mutex
+	failures int
+	partial  bool
+	written  []string
+	closed   bool
+}
+
+func (s *testSink) BulkInsert(records []kubeClientModel.Event, collection string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("write to closed sink")
+	}
+	if s.failures > 0 {
+		s.failures--
+		if !s.partial {
+			return errors.New("write failed")
+		}
+		for _, record := range records[1:] {
+			s.written = append(s.written, record.Name)
+		}
+		return &PartialError{Failed: []int{0}, Err: errors.New("first record failed")}
+	}
+	for _, record := range records {
+		s.written = append(s.written, record.Name)
+	}
+	return nil
+}
+
+func (s *testSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *testSink) records() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.written...)
+}
+
+func testRecords(names ...string) []kubeClientModel.Event {
+	records := make([]kubeClientModel.Event, len(names))
+	for i, name := range names {
+		records[i].Name = name
+	}
+	return records
+}
+
+func assertRecords(t *testing.T, sink string, got []string, expected ...string) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Fatalf("%s: expected records %v, got %v", sink, expected, got)
+	}
+	for i := range got {
+		if got[i] != expected[i] {
+			t.Fatalf("%s: expected records %v, got %v", sink, expected, got)
+		}
+	}
+}
+
+func TestFanOutRetriesOnlyFailedSinksAndRecords(t *testing.T) {
+	healthy := &testSink{}
+	partial := &testSink{failures: 1, partial: true}
+	secondary := &testSink{failures: 1}
+	fanOut := NewFanOut([]FanOutSink{
+		{Name: "healthy", Storage: healthy, Primary: true, WriteRetries: 2},
+		{Name: "partial", Storage: partial, Primary: true, WriteRetries: 2},
+		{Name: "secondary", Storage: secondary, QueueSize: 1, WriteRetries: 2},
+	})
+
+	if err := fanOut.BulkInsert(testRecords("a", "b", "c"), "events"); err != nil {
+		t.Fatal(err)
+	}
+	fanOut.Stop()
+
+	assertRecords(t, "healthy", healthy.records(), "a", "b", "c")
+	assertRecords(t, "partial", partial.records(), "b", "c", "a")
+	assertRecords(t, "secondary", secondary.records(), "a", "b", "c")
+}
+
+func TestFanOutReturnsFinalError(t *testing.T) {
+	failing := &testSink{failures: 10}
+	fanOut := NewFanOut([]FanOutSink{
+		{Name: "failing", Storage: failing, Primary: true, WriteRetries: 1},
+	})
+	defer fanOut.Stop()
+
+	err := fanOut.BulkInsert(testRecords("a"), "events")
+	if !IsFinal(err) {
+		t.Fatalf("expected final error, got %v", err)
+	}
+	if failing.failures != 8 {
+		t.Errorf("expected 2 attempts, got %d", 10-failing.failures)
+	}
+}
+
+func TestFanOutStopDuringWrites(t *testing.T) {
+	secondary := &testSink{}
+	fanOut := NewFanOut([]FanOutSink{
+		{Name: "primary", Storage: &testSink{}, Primary: true},
+		{Name: "secondary", Storage: secondary, QueueSize: 1},
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if err := fanOut.BulkInsert(testRecords("a"), "events"); err != nil && !IsFinal(err) {
+					t.Errorf("unexpected error %v", err)
+				}
+			}
+		}()
+	}
+	time.Sleep(time.Millisecond)
+	fanOut.Stop()
+	wg.Wait()
+
+	if err := fanOut.BulkInsert(testRecords("a"), "events"); err == nil {
+		t.Error("expected error after stop")
+	}
+	fanOut.Stop()
+}
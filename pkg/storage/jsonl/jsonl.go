@@ -0,0 +1,376 @@
+package jsonl
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	log "github.com/sirupsen/logrus"
+)
+
+// Stdout is a path which selects writing to stdout.
+const Stdout = "-"
+
+// Fsync modes
+const (
+	// FsyncNone leaves flushing of written data to OS.
+	FsyncNone = "none"
+	// FsyncBatch syncs file after every written batch.
+	FsyncBatch = "batch"
+	// FsyncInterval syncs file periodically.
+	FsyncInterval = "interval"
+)
+
+// backupTimeFormat is a time format of rotated file name suffix, it keeps lexical order of names.
+const backupTimeFormat = "20060102T150405.000"
+
+// maxBackupSeq limits number of files rotated in the same millisecond.
+const maxBackupSeq = 10000
+
+type Config struct {
+	// Path of file. Records are written to stdout if empty or Stdout.
+	Path string
+
+	// MaxSize is a file size in bytes which triggers rotation. File is not rotated by size if zero.
+	MaxSize int64
+	// MaxAge is an age of file which triggers rotation on next write. File is not rotated by time if zero.
+	MaxAge time.Duration
+	// MaxBackups is a number of kept rotated files. All rotated files are kept if zero.
+	MaxBackups int
+	// Compress enables gzip of rotated files.
+	Compress bool
+
+	Fsync         string
+	FsyncInterval time.Duration
+}
+
+// line is a written record with collection.
+type line struct {
+	Collection string `json:"collection"`
+	kubeClientModel.Event
+}
+
+// Sink writes records as JSON lines to stdout or file for log shippers. It implements storage.EventBulkInserter.
+// Rotated files are named "<name>-<time>-<seq><ext>" and are compressed in background.
+type Sink struct {
+	cfg Config
+
+	mu       sync.Mutex
+	out      io.Writer
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	closed   bool
+
+	// rotated contains rotated files waiting for processRotated, which is notified by rotatedSignal
+	rotated       []string
+	rotatedSignal chan struct{}
+	stop          chan struct{}
+	wg            sync.WaitGroup
+
+	log *log.Entry
+}
+
+func NewSink(cfg Config) (*Sink, error) {
+	switch cfg.Fsync {
+	case "":
+		cfg.Fsync = FsyncNone
+	case FsyncNone, FsyncBatch:
+		//pass
+	case FsyncInterval:
+		if cfg.FsyncInterval <= 0 {
+			return nil, fmt.Errorf("fsync interval must be positive")
+		}
+	default:
+		return nil, fmt.Errorf("unknown fsync mode %q", cfg.Fsync)
+	}
+	s := &Sink{
+		cfg:  cfg,
+		stop: make(chan struct{}),
+		log:  log.WithField("component", "jsonl_sink"),
+	}
+	if s.isStdout() {
+		s.out = os.Stdout
+		return s, nil
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	s.rotatedSignal = make(chan struct{}, 1)
+	s.wg.Add(1)
+	go s.processRotated()
+	if cfg.Fsync == FsyncInterval {
+		s.wg.Add(1)
+		go s.syncPeriodically()
+	}
+	return s, nil
+}
+
+func (s *Sink) isStdout() bool {
+	return s.cfg.Path == "" || s.cfg.Path == Stdout
+}
+
+func (s *Sink) open() error {
+	file, err := os.OpenFile(s.cfg.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.file, s.out = file, file
+	s.size = info.Size()
+	s.openedAt = time.Now()
+	return nil
+}
+
+func (s *Sink) BulkInsert(r []kubeClientModel.Event, collection string) error {
+	if len(r) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return fmt.Errorf("jsonl sink is closed")
+	}
+
+	buf := bufio.NewWriter(s.out)
+	for _, record := range r {
+		text, err := json.Marshal(line{Collection: collection, Event: record})
+		if err != nil {
+			return err
+		}
+		text = append(text, '\n')
+		if s.needRotation(int64(len(text))) {
+			if err := buf.Flush(); err != nil {
+				return err
+			}
+			if err := s.rotate(); err != nil {
+				return err
+			}
+			buf.Reset(s.out)
+		}
+		if _, err := buf.Write(text); err != nil {
+			return err
+		}
+		s.size += int64(len(text))
+	}
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	if s.cfg.Fsync == FsyncBatch && s.file != nil {
+		return s.file.Sync()
+	}
+	return nil
+}
+
+// needRotation returns true if non-empty file is too big or too old to write next line.
+func (s *Sink) needRotation(lineSize int64) bool {
+	if s.file == nil || s.size == 0 {
+		return false
+	}
+	if s.cfg.MaxSize > 0 && s.size+lineSize > s.cfg.MaxSize {
+		return true
+	}
+	return s.cfg.MaxAge > 0 && time.Since(s.openedAt) >= s.cfg.MaxAge
+}
+
+// backupPath returns rotated file path, i.e. "events-20060102T150405.000-0000.jsonl" for "events.jsonl".
+// Sequence number distinguishes files rotated in the same millisecond.
+func (s *Sink) backupPath(t time.Time) (string, error) {
+	ext := filepath.Ext(s.cfg.Path)
+	prefix := strings.TrimSuffix(s.cfg.Path, ext) + "-" + t.UTC().Format(backupTimeFormat)
+	for seq := 0; seq < maxBackupSeq; seq++ {
+		path := fmt.Sprintf("%s-%04d%s", prefix, seq, ext)
+		if !exists(path) && !exists(path+".gz") {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("too many files rotated at %s", t.UTC().Format(backupTimeFormat))
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return !os.IsNotExist(err)
+}
+
+func (s *Sink) rotate() error {
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	backup, err := s.backupPath(time.Now())
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(s.cfg.Path, backup); err != nil {
+		return err
+	}
+	s.log.WithField("file", backup).Debug("File rotated")
+	if err := s.open(); err != nil {
+		return err
+	}
+	// rotation does not wait for compression of previous files
+	s.rotated = append(s.rotated, backup)
+	select {
+	case s.rotatedSignal <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// processRotated compresses rotated files and removes old ones. Files rotated before Close are processed before return.
+func (s *Sink) processRotated() {
+	defer s.wg.Done()
+	for {
+		stopped := false
+		select {
+		case <-s.rotatedSignal:
+		case <-s.stop:
+			stopped = true
+		}
+		s.mu.Lock()
+		rotated := s.rotated
+		s.rotated = nil
+		s.mu.Unlock()
+		for _, backup := range rotated {
+			if s.cfg.Compress {
+				if err := compress(backup); err != nil {
+					s.log.WithError(err).WithField("file", backup).Error("Unable to compress rotated file")
+				}
+			}
+		}
+		if len(rotated) > 0 && s.cfg.MaxBackups > 0 {
+			s.removeOldBackups()
+		}
+		if stopped {
+			return
+		}
+	}
+}
+
+func compress(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return err
+	}
+	if err := dst.Sync(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (s *Sink) removeOldBackups() {
+	ext := filepath.Ext(s.cfg.Path)
+	pattern := strings.TrimSuffix(s.cfg.Path, ext) + "-*" + ext
+	// backup may be both compressed and not while it is compressed, pattern without extension also matches compressed files
+	files := make(map[string][]string)
+	for _, p := range []string{pattern, pattern + ".gz"} {
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			s.log.WithError(err).Error("Unable to list rotated files")
+			return
+		}
+		for _, match := range matches {
+			backup := strings.TrimSuffix(match, ".gz")
+			if !containsString(files[backup], match) {
+				files[backup] = append(files[backup], match)
+			}
+		}
+	}
+	backups := make([]string, 0, len(files))
+	for backup := range files {
+		backups = append(backups, backup)
+	}
+	// names contain rotation time, so oldest files are first
+	sort.Strings(backups)
+	for len(backups) > s.cfg.MaxBackups {
+		for _, file := range files[backups[0]] {
+			if err := os.Remove(file); err != nil {
+				s.log.WithError(err).WithField("file", file).Error("Unable to remove rotated file")
+			}
+		}
+		backups = backups[1:]
+	}
+}
+
+func containsString(list []string, str string) bool {
+	for _, item := range list {
+		if item == str {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Sink) syncPeriodically() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.cfg.FsyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			if !s.closed {
+				if err := s.file.Sync(); err != nil {
+					s.log.WithError(err).Error("Unable to sync file")
+				}
+			}
+			s.mu.Unlock()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Close syncs and closes file and waits for compression of rotated files.
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	if s.closed || s.file == nil {
+		s.closed = true
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.stop)
+	err := s.file.Sync()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
@@ -0,0 +1,110 @@
+package jsonl
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+)
+
+func countLines(t *testing.T, path string) int {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if filepath.Ext(path) == ".gz" {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gz
+	}
+	lines := 0
+	for scanner := bufio.NewScanner(r); scanner.Scan(); {
+		lines++
+	}
+	return lines
+}
+
+func TestRotationInSameMillisecondKeepsAllFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "events.jsonl")
+	// every record is written to new file
+	sink, err := NewSink(Config{Path: path, MaxSize: 1, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const total = 100
+	for i := 0; i < total; i++ {
+		if err := sink.BulkInsert([]kubeClientModel.Event{{Name: "Created"}}, "events"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := filepath.Glob(filepath.Join(dir, "events-*.jsonl.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != total-1 {
+		t.Fatalf("expected %d compressed backups, got %d", total-1, len(backups))
+	}
+	lines := countLines(t, path)
+	for _, backup := range backups {
+		lines += countLines(t, backup)
+	}
+	if lines != total {
+		t.Errorf("expected %d lines, got %d", total, lines)
+	}
+}
+
+func TestRemoveOldBackupsCountsCompressedOnce(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// path without extension matches compressed backups by both patterns, c is being compressed
+	path := filepath.Join(dir, "events")
+	files := []string{"events-a", "events-b.gz", "events-c", "events-c.gz", "events-d.gz"}
+	for _, name := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sink, err := NewSink(Config{Path: path, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	sink.removeOldBackups()
+
+	left, err := filepath.Glob(filepath.Join(dir, "events-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"events-c", "events-c.gz", "events-d.gz"}
+	if len(left) != len(expected) {
+		t.Fatalf("expected backups %v, got %v", expected, left)
+	}
+	for i := range left {
+		if filepath.Base(left[i]) != expected[i] {
+			t.Fatalf("expected backups %v, got %v", expected, left)
+		}
+	}
+}
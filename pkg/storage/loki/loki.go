@@ -0,0 +1,271 @@
+package loki
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// Push request formats
+const (
+	FormatProtobuf = "protobuf"
+	FormatJSON     = "json"
+)
+
+type Config struct {
+	// URL of push API, i.e. "http://loki:3100/loki/api/v1/push".
+	URL    string
+	Format string
+	// TenantID is sent in X-Scope-OrgID header if set.
+	TenantID string
+	Username string
+	Password string
+	// Labels are added to every stream, i.e. cluster name.
+	Labels  map[string]string
+	Timeout time.Duration
+
+	// MaxAttempts is a number of push attempts failed with 429 or 5xx status.
+	MaxAttempts int
+	RetryDelay  time.Duration
+}
+
+// Sink pushes records to Loki. Stream labels are namespace, resource_type, kind and collection, so number of streams is bounded.
+// It implements storage.EventBulkInserter.
+type Sink struct {
+	cfg    Config
+	client *http.Client
+
+	// lastTimestamp keeps last pushed timestamp of every stream, so entries are not rejected as out of order.
+	mu            sync.Mutex
+	lastTimestamp map[string]time.Time
+
+	log *log.Entry
+}
+
+type entry struct {
+	timestamp time.Time
+	line      string
+}
+
+type stream struct {
+	labels    string
+	labelMap  map[string]string
+	entries   []entry
+	lastAdded time.Time
+}
+
+// line is a log line of record.
+type line struct {
+	Name         string            `json:"event_name"`
+	ResourceName string            `json:"resource_name,omitempty"`
+	ResourceUID  string            `json:"resource_uid,omitempty"`
+	Message      string            `json:"message,omitempty"`
+	Details      map[string]string `json:"details,omitempty"`
+}
+
+func NewSink(cfg Config) (*Sink, error) {
+	switch cfg.Format {
+	case "":
+		cfg.Format = FormatProtobuf
+	case FormatProtobuf, FormatJSON:
+		//pass
+	default:
+		return nil, fmt.Errorf("unknown loki format %q", cfg.Format)
+	}
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("loki url is required")
+	}
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	return &Sink{
+		cfg:           cfg,
+		client:        &http.Client{Timeout: cfg.Timeout},
+		lastTimestamp: make(map[string]time.Time),
+		log:           log.WithField("component", "loki_sink"),
+	}, nil
+}
+
+func (s *Sink) streamLabels(record kubeClientModel.Event, collection string) map[string]string {
+	labels := make(map[string]string, len(s.cfg.Labels)+4)
+	for k, v := range s.cfg.Labels {
+		labels[k] = v
+	}
+	labels["collection"] = collection
+	if record.ResourceNamespace != "" {
+		labels["namespace"] = record.ResourceNamespace
+	}
+	if record.ResourceType != "" {
+		labels["resource_type"] = string(record.ResourceType)
+	}
+	if record.Kind != "" {
+		labels["kind"] = string(record.Kind)
+	}
+	return labels
+}
+
+// formatLabels formats labels in Prometheus format with sorted names.
+func formatLabels(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + strconv.Quote(labels[name])
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+func recordTimestamp(record kubeClientModel.Event) time.Time {
+	if t, err := time.Parse(time.RFC3339Nano, record.Time); err == nil {
+		return t
+	}
+	if !record.DateAdded.IsZero() {
+		return record.DateAdded
+	}
+	return time.Now()
+}
+
+// streams groups records by labels. Entries are sorted by time and are not older than last pushed entry of stream.
+func (s *Sink) streams(records []kubeClientModel.Event, collection string) ([]*stream, error) {
+	byLabels := make(map[string]*stream)
+	var streams []*stream
+	for _, record := range records {
+		labelMap := s.streamLabels(record, collection)
+		labels := formatLabels(labelMap)
+		st, ok := byLabels[labels]
+		if !ok {
+			st = &stream{labels: labels, labelMap: labelMap}
+			byLabels[labels] = st
+			streams = append(streams, st)
+		}
+		text, err := json.Marshal(line{
+			Name:         record.Name,
+			ResourceName: record.ResourceName,
+			ResourceUID:  record.ResourceUID,
+			Message:      record.Message,
+			Details:      record.Details,
+		})
+		if err != nil {
+			return nil, err
+		}
+		st.entries = append(st.entries, entry{timestamp: recordTimestamp(record), line: string(text)})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, st := range streams {
+		sort.SliceStable(st.entries, func(i, j int) bool {
+			return st.entries[i].timestamp.Before(st.entries[j].timestamp)
+		})
+		last := s.lastTimestamp[st.labels]
+		for i := range st.entries {
+			if st.entries[i].timestamp.Before(last) {
+				st.entries[i].timestamp = last
+			}
+		}
+		st.lastAdded = st.entries[len(st.entries)-1].timestamp
+	}
+	return streams, nil
+}
+
+type jsonPushRequest struct {
+	Streams []jsonStream `json:"streams"`
+}
+
+type jsonStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// encode returns push request body and content type. Protobuf request is compressed with snappy as required by Loki.
+func (s *Sink) encode(streams []*stream) ([]byte, string, error) {
+	if s.cfg.Format == FormatProtobuf {
+		return snappyEncode(encodePushRequest(streams)), "application/x-protobuf", nil
+	}
+	req := jsonPushRequest{Streams: make([]jsonStream, 0, len(streams))}
+	for _, st := range streams {
+		js := jsonStream{Stream: st.labelMap}
+		for _, e := range st.entries {
+			js.Values = append(js.Values, [2]string{strconv.FormatInt(e.timestamp.UnixNano(), 10), e.line})
+		}
+		req.Streams = append(req.Streams, js)
+	}
+	body, err := json.Marshal(req)
+	return body, "application/json", err
+}
+
+func (s *Sink) BulkInsert(r []kubeClientModel.Event, collection string) error {
+	if len(r) == 0 {
+		return nil
+	}
+	streams, err := s.streams(r, collection)
+	if err != nil {
+		return err
+	}
+	body, contentType, err := s.encode(streams)
+	if err != nil {
+		return err
+	}
+
+	retry := storage.Retry{Attempts: s.cfg.MaxAttempts, Delay: s.cfg.RetryDelay}
+	err = retry.Do(s.log.WithField("collection", collection), func() error {
+		return s.push(body, contentType)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	for _, st := range streams {
+		if st.lastAdded.After(s.lastTimestamp[st.labels]) {
+			s.lastTimestamp[st.labels] = st.lastAdded
+		}
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// push returns final error if response status is not 429 or 5xx.
+func (s *Sink) push(body []byte, contentType string) error {
+	req, err := http.NewRequest(http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return storage.Final(err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if s.cfg.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", s.cfg.TenantID)
+	}
+	if s.cfg.Username != "" {
+		req.SetBasicAuth(s.cfg.Username, s.cfg.Password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("loki returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return storage.Final(err)
+	}
+	return err
+}
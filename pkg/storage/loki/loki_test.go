@@ -0,0 +1,291 @@
+package loki
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+)
+
+// snappyDecode decodes snappy block format.
+func snappyDecode(src []byte) ([]byte, error) {
+	length, n := binary.Uvarint(src)
+	if n <= 0 {
+		return nil, errors.New("invalid length")
+	}
+	src = src[n:]
+	dst := make([]byte, 0, length)
+	for len(src) > 0 {
+		tag := src[0]
+		switch tag & 3 {
+		case 0:
+			size := int(tag >> 2)
+			src = src[1:]
+			if size >= 60 {
+				extra := size - 59
+				if len(src) < extra {
+					return nil, errors.New("short literal length")
+				}
+				size = 0
+				for i := extra - 1; i >= 0; i-- {
+					size = size<<8 | int(src[i])
+				}
+				src = src[extra:]
+			}
+			size++
+			if len(src) < size {
+				return nil, errors.New("short literal")
+			}
+			dst = append(dst, src[:size]...)
+			src = src[size:]
+		case 2:
+			if len(src) < 3 {
+				return nil, errors.New("short copy")
+			}
+			size := int(tag>>2) + 1
+			offset := int(src[1]) | int(src[2])<<8
+			src = src[3:]
+			if offset == 0 || offset > len(dst) {
+				return nil, fmt.Errorf("invalid offset %d", offset)
+			}
+			for i := 0; i < size; i++ {
+				dst = append(dst, dst[len(dst)-offset])
+			}
+		default:
+			return nil, fmt.Errorf("unsupported tag %d", tag&3)
+		}
+	}
+	if uint64(len(dst)) != length {
+		return nil, fmt.Errorf("decoded %d bytes, expected %d", len(dst), length)
+	}
+	return dst, nil
+}
+
+// protoFields returns fields of protobuf message by number. Varints are returned as 8-byte little endian values.
+func protoFields(msg []byte) (map[int][][]byte, error) {
+	fields := make(map[int][][]byte)
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return nil, errors.New("invalid key")
+		}
+		msg = msg[n:]
+		field := int(key >> 3)
+		switch key & 7 {
+		case wireVarint:
+			value, n := binary.Uvarint(msg)
+			if n <= 0 {
+				return nil, errors.New("invalid varint")
+			}
+			msg = msg[n:]
+			var buf [8]byte
+			binary.LittleEndian.PutUint64(buf[:], value)
+			fields[field] = append(fields[field], buf[:])
+		case wireBytes:
+			size, n := binary.Uvarint(msg)
+			if n <= 0 || uint64(len(msg)-n) < size {
+				return nil, errors.New("invalid bytes field")
+			}
+			fields[field] = append(fields[field], msg[n:n+int(size)])
+			msg = msg[n+int(size):]
+		default:
+			return nil, fmt.Errorf("unsupported wire type %d", key&7)
+		}
+	}
+	return fields, nil
+}
+
+func protoVarint(fields map[int][][]byte, field int) int64 {
+	if len(fields[field]) == 0 {
+		return 0
+	}
+	return int64(binary.LittleEndian.Uint64(fields[field][0]))
+}
+
+type pushedEntry struct {
+	timestamp time.Time
+	line      string
+}
+
+// decodeProtobufPush returns entries by stream labels.
+func decodeProtobufPush(body []byte) (map[string][]pushedEntry, error) {
+	data, err := snappyDecode(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := protoFields(data)
+	if err != nil {
+		return nil, err
+	}
+	streams := make(map[string][]pushedEntry)
+	for _, rawStream := range req[1] {
+		st, err := protoFields(rawStream)
+		if err != nil {
+			return nil, err
+		}
+		labels := string(st[1][0])
+		for _, rawEntry := range st[2] {
+			e, err := protoFields(rawEntry)
+			if err != nil {
+				return nil, err
+			}
+			ts, err := protoFields(e[1][0])
+			if err != nil {
+				return nil, err
+			}
+			streams[labels] = append(streams[labels], pushedEntry{
+				timestamp: time.Unix(protoVarint(ts, 1), protoVarint(ts, 2)),
+				line:      string(e[2][0]),
+			})
+		}
+	}
+	return streams, nil
+}
+
+func decodeJSONPush(body []byte) (map[string][]pushedEntry, error) {
+	var req jsonPushRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	streams := make(map[string][]pushedEntry)
+	for _, st := range req.Streams {
+		labels := formatLabels(st.Stream)
+		for _, value := range st.Values {
+			nanos, err := strconv.ParseInt(value[0], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			streams[labels] = append(streams[labels], pushedEntry{timestamp: time.Unix(0, nanos), line: value[1]})
+		}
+	}
+	return streams, nil
+}
+
+// testLoki decodes pushes and keeps entries of every stream.
+type testLoki struct {
+	t       *testing.T
+	mu      sync.Mutex
+	streams map[string][]pushedEntry
+}
+
+func (l *testLoki) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var streams map[string][]pushedEntry
+	switch r.Header.Get("Content-Type") {
+	case "application/x-protobuf":
+		streams, err = decodeProtobufPush(body)
+	case "application/json":
+		streams, err = decodeJSONPush(body)
+	default:
+		err = fmt.Errorf("unexpected content type %q", r.Header.Get("Content-Type"))
+	}
+	if err != nil {
+		l.t.Errorf("unable to decode push: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for labels, entries := range streams {
+		// Loki rejects entries older than last entry of stream
+		for _, e := range entries {
+			if existing := l.streams[labels]; len(existing) > 0 && e.timestamp.Before(existing[len(existing)-1].timestamp) {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, "entry out of order for stream %s", labels)
+				return
+			}
+			l.streams[labels] = append(l.streams[labels], e)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestSnappyRoundTrip(t *testing.T) {
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+	inputs := [][]byte{
+		nil,
+		[]byte("abc"),
+		bytes.Repeat([]byte("kube-events "), 1000),
+		random,
+		append(bytes.Repeat([]byte{'a'}, 300), random[:70000]...),
+	}
+	for i, input := range inputs {
+		decoded, err := snappyDecode(snappyEncode(input))
+		if err != nil {
+			t.Fatalf("input %d: %v", i, err)
+		}
+		if !bytes.Equal(decoded, input) {
+			t.Fatalf("input %d: decoded data differs", i)
+		}
+	}
+}
+
+func TestPushOrderingAndClamping(t *testing.T) {
+	for _, format := range []string{FormatProtobuf, FormatJSON} {
+		loki := &testLoki{t: t, streams: make(map[string][]pushedEntry)}
+		server := httptest.NewServer(loki)
+		sink, err := NewSink(Config{URL: server.URL, Format: format, Labels: map[string]string{"cluster": "test"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		base := time.Date(2018, 7, 1, 12, 0, 0, 0, time.UTC)
+		record := func(name string, offset time.Duration) kubeClientModel.Event {
+			return kubeClientModel.Event{
+				Name:              name,
+				Kind:              kubeClientModel.EventWarning,
+				ResourceType:      kubeClientModel.TypePod,
+				ResourceNamespace: "ns",
+				Time:              base.Add(offset).Format(time.RFC3339Nano),
+			}
+		}
+		// unordered batch is sorted
+		if err := sink.BulkInsert([]kubeClientModel.Event{record("b", 2*time.Second), record("a", time.Second)}, "events"); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		// older record of next batch is clamped to last pushed timestamp
+		if err := sink.BulkInsert([]kubeClientModel.Event{record("c", 0)}, "events"); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		server.Close()
+
+		if len(loki.streams) != 1 {
+			t.Fatalf("%s: expected 1 stream, got %v", format, loki.streams)
+		}
+		labels := `{cluster="test", collection="events", kind="warning", namespace="ns", resource_type="pod"}`
+		entries := loki.streams[labels]
+		if len(entries) != 3 {
+			t.Fatalf("%s: expected 3 entries in stream %s, got %v", format, labels, loki.streams)
+		}
+		expected := []struct {
+			name   string
+			offset time.Duration
+		}{{"a", time.Second}, {"b", 2 * time.Second}, {"c", 2 * time.Second}}
+		for i, e := range entries {
+			var l line
+			if err := json.Unmarshal([]byte(e.line), &l); err != nil {
+				t.Fatalf("%s: invalid line %q: %v", format, e.line, err)
+			}
+			if l.Name != expected[i].name || !e.timestamp.Equal(base.Add(expected[i].offset)) {
+				t.Errorf("%s: entry %d: expected %s at %v, got %s at %v", format, i,
+					expected[i].name, base.Add(expected[i].offset), l.Name, e.timestamp)
+			}
+		}
+	}
+}
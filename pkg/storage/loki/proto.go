@@ -0,0 +1,63 @@
+package loki
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// Loki push request is encoded manually to avoid generated code:
+//
+//	message PushRequest { repeated StreamAdapter streams = 1; }
+//	message StreamAdapter { string labels = 1; repeated EntryAdapter entries = 2; }
+//	message EntryAdapter { google.protobuf.Timestamp timestamp = 1; string line = 2; }
+//	message Timestamp { int64 seconds = 1; int32 nanos = 2; }
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+type protoBuffer []byte
+
+func (b protoBuffer) varint(v uint64) protoBuffer {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func (b protoBuffer) tag(field, wireType int) protoBuffer {
+	return b.varint(uint64(field<<3 | wireType))
+}
+
+func (b protoBuffer) bytesField(field int, value []byte) protoBuffer {
+	return append(b.tag(field, wireBytes).varint(uint64(len(value))), value...)
+}
+
+func (b protoBuffer) varintField(field int, value uint64) protoBuffer {
+	if value == 0 {
+		return b
+	}
+	return b.tag(field, wireVarint).varint(value)
+}
+
+func encodeTimestamp(t time.Time) protoBuffer {
+	return protoBuffer(nil).
+		varintField(1, uint64(t.Unix())).
+		varintField(2, uint64(t.Nanosecond()))
+}
+
+func encodeEntry(e entry) protoBuffer {
+	return protoBuffer(nil).
+		bytesField(1, encodeTimestamp(e.timestamp)).
+		bytesField(2, []byte(e.line))
+}
+
+func encodePushRequest(streams []*stream) []byte {
+	var req protoBuffer
+	for _, s := range streams {
+		msg := protoBuffer(nil).bytesField(1, []byte(s.labels))
+		for _, e := range s.entries {
+			msg = msg.bytesField(2, encodeEntry(e))
+		}
+		req = req.bytesField(1, msg)
+	}
+	return req
+}
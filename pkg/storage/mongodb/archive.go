@@ -0,0 +1,43 @@
+package mongodb
+
+import (
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/storage"
+)
+
+// IterateRecords calls fn for records matched by query in order of adding. Query cursor and limit are ignored.
+func (s *Storage) IterateRecords(query storage.EventQuery, fn func(record kubeClientModel.Event) error) error {
+	if !isKnownCollection(query.Collection) {
+		return storage.ErrUnknownCollection
+	}
+	query.Cursor = ""
+	selector, err := querySelector(query)
+	if err != nil {
+		return err
+	}
+	s.log.WithField("selector", selector).Debug("Iterate records")
+
+	iter := s.db.C(query.Collection).Find(selector).Sort("dateadded", "_id").Iter()
+	var record kubeClientModel.Event
+	for iter.Next(&record) {
+		if err := fn(record); err != nil {
+			iter.Close()
+			return err
+		}
+		record = kubeClientModel.Event{}
+	}
+	return iter.Close()
+}
+
+// RetentionBounds returns shortest and longest retention of collection records.
+func (s *Storage) RetentionBounds(collection string) (shortest, longest time.Duration) {
+	shortest, rules := s.retention.collectionRetention(collection)
+	for _, rule := range rules {
+		if rule.ExpireAfter < shortest {
+			shortest = rule.ExpireAfter
+		}
+	}
+	return shortest, s.retention.expireAfter(collection)
+}
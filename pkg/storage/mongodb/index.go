@@ -3,7 +3,6 @@ package mongodb
 import (
 	"errors"
 	"strings"
-	"time"
 
 	kubeClientModel "github.com/containerum/kube-client/pkg/model"
 
@@ -21,16 +20,20 @@ var (
 		Unique: true,
 	}
 
+	// records without UID are not indexed, so they don't collide on null key
 	uniqueEventsIndex = mgo.Index{
-		Name:   "unique_resourceuid",
-		Key:    []string{"resourceuid"},
+		Name: "unique_resourceuid",
+		Key:  []string{"resourceuid"},
+		PartialFilter: bson.M{
+			"resourceuid": bson.M{"$exists": true},
+		},
 		Unique: true,
 	}
 
+	// ExpireAfter is set from storage retention
 	dateExpirationIndex = mgo.Index{
-		Name:        "date_expiration",
-		Key:         []string{"dateadded"},
-		ExpireAfter: 30 * 24 * time.Hour,
+		Name: "date_expiration",
+		Key:  []string{"dateadded"},
 	}
 )
 
@@ -39,7 +42,7 @@ func (s *Storage) ensureIndexes() error {
 	var errs []string
 	for _, collectionName := range Collections {
 		collection := s.db.C(collectionName)
-		if err := collection.EnsureIndex(dateExpirationIndex); err != nil {
+		if err := s.ensureExpirationIndex(collection); err != nil {
 			errs = append(errs, err.Error())
 		}
 		switch collectionName {
@@ -0,0 +1,64 @@
+package mongodb
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// Leader lease is stored in system collection like migration lock
+const leaderLeaseID = "leader_lease"
+
+type leaderLease struct {
+	Owner   string    `bson:"owner"`
+	Expires time.Time `bson:"expires"`
+}
+
+// AcquireLeadership takes or renews leader lease for identity. It returns false if lease is held by another instance.
+func (s *Storage) AcquireLeadership(identity string, ttl time.Duration) (bool, error) {
+	systemCollection := s.db.C(SystemCollection)
+	now := time.Now()
+	err := systemCollection.Update(
+		bson.M{"_id": leaderLeaseID, "owner": identity},
+		bson.M{"$set": bson.M{"expires": now.Add(ttl)}})
+	if err == nil {
+		return true, nil
+	}
+	if err != mgo.ErrNotFound {
+		return false, err
+	}
+
+	err = systemCollection.Remove(bson.M{"_id": leaderLeaseID, "expires": bson.M{"$lt": now}})
+	if err != nil && err != mgo.ErrNotFound {
+		return false, err
+	}
+	err = systemCollection.Insert(bson.M{
+		"_id":     leaderLeaseID,
+		"owner":   identity,
+		"expires": now.Add(ttl),
+	})
+	if mgo.IsDup(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Leader returns identity of current leader or empty string if lease is expired.
+func (s *Storage) Leader() (string, error) {
+	var lease leaderLease
+	err := s.db.C(SystemCollection).FindId(leaderLeaseID).One(&lease)
+	if err == mgo.ErrNotFound || (err == nil && lease.Expires.Before(time.Now())) {
+		return "", nil
+	}
+	return lease.Owner, err
+}
+
+// ReleaseLeadership removes leader lease if it is held by identity, so other instance can take it without waiting for expiration.
+func (s *Storage) ReleaseLeadership(identity string) error {
+	err := s.db.C(SystemCollection).Remove(bson.M{"_id": leaderLeaseID, "owner": identity})
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return err
+}
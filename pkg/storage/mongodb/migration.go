@@ -0,0 +1,324 @@
+package mongodb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	log "github.com/sirupsen/logrus"
+)
+
+// Schema version and migration lock are stored in system collection
+const (
+	schemaVersionID  = "schema_version"
+	migrationLockID  = "migration_lock"
+	migrationLockTTL = 10 * time.Minute
+)
+
+var ErrMigrationLocked = errors.New("migrations are running by another instance")
+
+// MigrationStep is a single schema change.
+type MigrationStep interface {
+	Apply(db *mgo.Database) error
+	String() string
+}
+
+// Migration changes schema to Version.
+type Migration struct {
+	Version     int
+	Description string
+	Steps       []MigrationStep
+}
+
+type DropIndex struct {
+	Collection string
+	Name       string
+}
+
+func (step DropIndex) Apply(db *mgo.Database) error {
+	collection := db.C(step.Collection)
+	indexes, err := collection.Indexes()
+	if err != nil {
+		return err
+	}
+	for _, index := range indexes {
+		if index.Name == step.Name {
+			return collection.DropIndexName(step.Name)
+		}
+	}
+	return nil
+}
+
+func (step DropIndex) String() string {
+	return fmt.Sprintf("drop index %q in %q", step.Name, step.Collection)
+}
+
+type EnsureIndex struct {
+	Collection string
+	Index      mgo.Index
+}
+
+func (step EnsureIndex) Apply(db *mgo.Database) error {
+	return db.C(step.Collection).EnsureIndex(step.Index)
+}
+
+func (step EnsureIndex) String() string {
+	return fmt.Sprintf("create index %q %v in %q", step.Index.Name, step.Index.Key, step.Collection)
+}
+
+type RenameField struct {
+	Collection string
+	From, To   string
+}
+
+func (step RenameField) Apply(db *mgo.Database) error {
+	_, err := db.C(step.Collection).UpdateAll(
+		bson.M{step.From: bson.M{"$exists": true}},
+		bson.M{"$rename": bson.M{step.From: step.To}})
+	return err
+}
+
+func (step RenameField) String() string {
+	return fmt.Sprintf("rename field %q to %q in %q", step.From, step.To, step.Collection)
+}
+
+// Backfill sets Field in records matched by Selector which have no such field.
+// Field value is Value or value of FromField if it is set.
+type Backfill struct {
+	Collection string
+	Selector   bson.M
+	Field      string
+	Value      interface{}
+	FromField  string
+}
+
+func (step Backfill) selector() bson.M {
+	selector := bson.M{step.Field: bson.M{"$exists": false}}
+	for k, v := range step.Selector {
+		selector[k] = v
+	}
+	return selector
+}
+
+func (step Backfill) Apply(db *mgo.Database) error {
+	collection := db.C(step.Collection)
+	if step.FromField == "" {
+		_, err := collection.UpdateAll(step.selector(), bson.M{"$set": bson.M{step.Field: step.Value}})
+		return err
+	}
+	var doc bson.M
+	iter := collection.Find(step.selector()).Select(bson.M{step.FromField: 1}).Iter()
+	for iter.Next(&doc) {
+		value, ok := doc[step.FromField]
+		if !ok {
+			continue
+		}
+		if err := collection.UpdateId(doc["_id"], bson.M{"$set": bson.M{step.Field: value}}); err != nil {
+			iter.Close()
+			return err
+		}
+	}
+	return iter.Close()
+}
+
+func (step Backfill) String() string {
+	from := fmt.Sprintf("%v", step.Value)
+	if step.FromField != "" {
+		from = "field " + step.FromField
+	}
+	return fmt.Sprintf("backfill field %q from %s in %q", step.Field, from, step.Collection)
+}
+
+func resourceCollections() []string {
+	return []string{
+		DeploymentCollection,
+		ResourceQuotasCollection,
+		IngressCollection,
+		ServiceCollection,
+		PVCCollection,
+		SecretsCollection,
+		ConfigMapsCollection,
+	}
+}
+
+// Migrations must be sorted by version
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "Backfill occurrence counters of upserted records",
+		Steps: func() []MigrationStep {
+			steps := []MigrationStep{
+				Backfill{Collection: EventsCollection, Field: "occurrences", Value: 1},
+				Backfill{Collection: EventsCollection, Field: "lastseen", FromField: "dateadded"},
+			}
+			created := bson.M{"eventname": kubeClientModel.ResourceCreated}
+			for _, collection := range resourceCollections() {
+				steps = append(steps,
+					Backfill{Collection: collection, Selector: created, Field: "occurrences", Value: 1},
+					Backfill{Collection: collection, Selector: created, Field: "lastmodified", FromField: "dateadded"})
+			}
+			return steps
+		}(),
+	},
+	{
+		Version:     2,
+		Description: "Recreate unique events UID index as partial index of records with UID",
+		Steps: []MigrationStep{
+			DropIndex{Collection: EventsCollection, Name: uniqueEventsIndex.Name},
+			EnsureIndex{Collection: EventsCollection, Index: uniqueEventsIndex},
+		},
+	},
+	{
+		Version:     3,
+		Description: "Backfill first-seen time of events",
+		Steps: []MigrationStep{
+			Backfill{Collection: EventsCollection, Field: "firstseen", FromField: "dateadded"},
+		},
+	},
+}
+
+type schemaVersion struct {
+	Version int       `bson:"version"`
+	Updated time.Time `bson:"updated"`
+}
+
+// SchemaVersion returns version of last applied migration.
+func (s *Storage) SchemaVersion() (int, error) {
+	var version schemaVersion
+	err := s.db.C(SystemCollection).FindId(schemaVersionID).One(&version)
+	if err == mgo.ErrNotFound {
+		return 0, nil
+	}
+	return version.Version, err
+}
+
+// PendingMigrations returns migrations which are not applied yet.
+func (s *Storage) PendingMigrations() ([]Migration, error) {
+	version, err := s.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, migration := range Migrations {
+		if migration.Version > version {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+func migrationLockOwner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s/%d", host, os.Getpid())
+}
+
+func (s *Storage) lockMigrations() error {
+	systemCollection := s.db.C(SystemCollection)
+	now := time.Now()
+	err := systemCollection.Remove(bson.M{"_id": migrationLockID, "expires": bson.M{"$lt": now}})
+	if err != nil && err != mgo.ErrNotFound {
+		return err
+	}
+	err = systemCollection.Insert(bson.M{
+		"_id":     migrationLockID,
+		"owner":   migrationLockOwner(),
+		"expires": now.Add(migrationLockTTL),
+	})
+	if mgo.IsDup(err) {
+		return ErrMigrationLocked
+	}
+	return err
+}
+
+// renewMigrationLock prolongs migration lock until stop is closed, so long migrations keep it.
+func (s *Storage) renewMigrationLock(stop <-chan struct{}) {
+	ticker := time.NewTicker(migrationLockTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			err := s.db.C(SystemCollection).Update(
+				bson.M{"_id": migrationLockID, "owner": migrationLockOwner()},
+				bson.M{"$set": bson.M{"expires": time.Now().Add(migrationLockTTL)}})
+			if err != nil {
+				s.log.WithError(err).Error("Unable to renew migration lock")
+			}
+		}
+	}
+}
+
+func (s *Storage) unlockMigrations() {
+	err := s.db.C(SystemCollection).Remove(bson.M{"_id": migrationLockID, "owner": migrationLockOwner()})
+	if err != nil && err != mgo.ErrNotFound {
+		s.log.WithError(err).Error("Unable to release migration lock")
+	}
+}
+
+// Migrate applies pending migrations under lock and returns applied ones.
+// In dry run mode pending migrations are only returned.
+func (s *Storage) Migrate(dryRun bool) ([]Migration, error) {
+	if dryRun {
+		return s.PendingMigrations()
+	}
+
+	if err := s.lockMigrations(); err != nil {
+		return nil, err
+	}
+	defer s.unlockMigrations()
+	renewStop := make(chan struct{})
+	defer close(renewStop)
+	go s.renewMigrationLock(renewStop)
+
+	// version may be changed by another instance before lock was acquired
+	pending, err := s.PendingMigrations()
+	if err != nil {
+		return nil, err
+	}
+	for i, migration := range pending {
+		migrationLog := s.log.WithFields(log.Fields{
+			"version":     migration.Version,
+			"description": migration.Description,
+		})
+		migrationLog.Info("Applying migration")
+		for _, step := range migration.Steps {
+			migrationLog.Debugf("Migration step: %s", step)
+			if err := step.Apply(s.db); err != nil {
+				return pending[:i], fmt.Errorf("migration %d: %s: %v", migration.Version, step, err)
+			}
+		}
+		_, err := s.db.C(SystemCollection).UpsertId(schemaVersionID, schemaVersion{
+			Version: migration.Version,
+			Updated: time.Now(),
+		})
+		if err != nil {
+			return pending[:i], err
+		}
+	}
+	return pending, nil
+}
+
+// migrateOnStart applies pending migrations, waiting while another instance holds migration lock.
+func (s *Storage) migrateOnStart() error {
+	deadline := time.Now().Add(migrationLockTTL)
+	for {
+		applied, err := s.Migrate(false)
+		if err == ErrMigrationLocked && time.Now().Before(deadline) {
+			s.log.Info("Waiting for migrations running by another instance")
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) > 0 {
+			s.log.WithField("count", len(applied)).Info("Migrations applied")
+		}
+		return nil
+	}
+}
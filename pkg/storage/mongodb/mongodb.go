@@ -1,8 +1,14 @@
 package mongodb
 
 import (
+	"fmt"
+	"sync"
+	"time"
+
 	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/storage"
 	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -33,11 +39,15 @@ var Collections = []string{
 }
 
 type Storage struct {
-	db  *mgo.Database
-	log *log.Entry
+	db        *mgo.Database
+	retention Retention
+	stop      chan struct{}
+	onceClose sync.Once
+	log       *log.Entry
 }
 
-func OpenConnection(cfg *mgo.DialInfo) (*Storage, error) {
+// Dial connects to MongoDB without preparing collections, indexes and schema.
+func Dial(cfg *mgo.DialInfo, retention Retention) (*Storage, error) {
 	connLog := log.WithField("component", "mongo-storage")
 	connLog.WithFields(log.Fields{
 		"addrs":    cfg.Addrs,
@@ -50,46 +60,50 @@ func OpenConnection(cfg *mgo.DialInfo) (*Storage, error) {
 	}
 	db := session.DB(cfg.Database)
 
-	storage := &Storage{
-		db:  db,
-		log: connLog,
-	}
+	return &Storage{
+		db:        db,
+		retention: retention,
+		stop:      make(chan struct{}),
+		log:       connLog,
+	}, nil
+}
 
-	if err := storage.createCollectionIfNotExist(EventsCollection); err != nil {
-		return nil, err
-	}
-	if err := storage.createCollectionIfNotExist(DeploymentCollection); err != nil {
-		return nil, err
-	}
-	if err := storage.createCollectionIfNotExist(ResourceQuotasCollection); err != nil {
-		return nil, err
+// Copy returns storage with a new session to the same database. Copy may be used after storage is closed and is closed separately.
+func (s *Storage) Copy() *Storage {
+	return &Storage{
+		db:        s.db.With(s.db.Session.Copy()),
+		retention: s.retention,
+		stop:      make(chan struct{}),
+		log:       s.log,
 	}
-	if err := storage.createCollectionIfNotExist(ServiceCollection); err != nil {
-		return nil, err
-	}
-	if err := storage.createCollectionIfNotExist(IngressCollection); err != nil {
-		return nil, err
-	}
-	if err := storage.createCollectionIfNotExist(PVCCollection); err != nil {
-		return nil, err
-	}
-	if err := storage.createCollectionIfNotExist(SecretsCollection); err != nil {
+}
+
+func OpenConnection(cfg *mgo.DialInfo, retention Retention) (*Storage, error) {
+	storage, err := Dial(cfg, retention)
+	if err != nil {
 		return nil, err
 	}
-	if err := storage.createCollectionIfNotExist(ConfigMapsCollection); err != nil {
-		return nil, err
+
+	for _, collection := range Collections {
+		if err := storage.createCollectionIfNotExist(collection); err != nil {
+			return nil, err
+		}
 	}
-	if err := storage.createCollectionIfNotExist(UserCollection); err != nil {
+
+	if err := storage.migrateOnStart(); err != nil {
 		return nil, err
 	}
-	if err := storage.createCollectionIfNotExist(SystemCollection); err != nil {
+
+	if err := storage.ensureIndexes(); err != nil {
 		return nil, err
 	}
 
-	if err := storage.ensureIndexes(); err != nil {
+	if err := storage.ensureSubscriptionIndexes(); err != nil {
 		return nil, err
 	}
 
+	go storage.runSweeper()
+
 	return storage, nil
 }
 
@@ -109,7 +123,7 @@ func (s *Storage) BulkInsert(r []kubeClientModel.Event, collection string) error
 	bulk.Insert(docs...)
 	result, err := bulk.Run()
 	if err != nil {
-		return err
+		return partialError(err, nil)
 	}
 	s.log.WithFields(log.Fields{
 		"matched":  result.Matched,
@@ -118,8 +132,199 @@ func (s *Storage) BulkInsert(r []kubeClientModel.Event, collection string) error
 	return nil
 }
 
+func (s *Storage) BulkUpsert(r []storage.Upsert, collection string) error {
+	s.log.WithField("record_count", len(r)).Debugf("Bulk upsert")
+	// bulk is unordered, so one failed upsert doesn't abort the rest of batch;
+	// updates of the same record are merged to make order irrelevant
+	bulk := s.db.C(collection).Bulk()
+	bulk.Unordered()
+	keyed := make([]storage.Upsert, len(r))
+	for i, upsert := range r {
+		if record, ok := upsert.Set.(kubeClientModel.Event); ok && upsert.Unique {
+			upsert.Key = recordKey(record)
+		}
+		keyed[i] = upsert
+	}
+	merged, sources := mergeUpserts(keyed)
+	for _, upsert := range merged {
+		if len(upsert.Key) == 0 {
+			bulk.Insert(upsert.Set)
+			continue
+		}
+		set, err := withoutFields(upsert.Set, upsert.SetOnInsert)
+		if err != nil {
+			return err
+		}
+		update := bson.M{"$set": set}
+		if len(upsert.SetOnInsert) > 0 {
+			update["$setOnInsert"] = upsert.SetOnInsert
+		}
+		if len(upsert.Inc) > 0 {
+			update["$inc"] = upsert.Inc
+		}
+		if len(upsert.Max) > 0 {
+			update["$max"] = upsert.Max
+		}
+		bulk.Upsert(bson.M(upsert.Key), update)
+	}
+	result, err := bulk.Run()
+	if err != nil {
+		return partialError(err, sources)
+	}
+	s.log.WithFields(log.Fields{
+		"matched":  result.Matched,
+		"modified": result.Modified,
+	}).Debug("Bulk upsert run")
+	return nil
+}
+
+// withoutFields returns document without fields, so they may be set by other update operator.
+func withoutFields(doc interface{}, fields map[string]interface{}) (interface{}, error) {
+	if len(fields) == 0 {
+		return doc, nil
+	}
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var ret bson.M
+	if err := bson.Unmarshal(data, &ret); err != nil {
+		return nil, err
+	}
+	for field := range fields {
+		delete(ret, field)
+	}
+	return ret, nil
+}
+
+// recordKey matches record with all fields and time of adding.
+func recordKey(record kubeClientModel.Event) bson.M {
+	key := bson.M{
+		"eventkind":    record.Kind,
+		"eventtime":    record.Time,
+		"resourcetype": record.ResourceType,
+		"resourcename": record.ResourceName,
+		"dateadded":    record.DateAdded,
+	}
+	// empty fields are omitted in stored records
+	optional := map[string]string{
+		"eventname":         record.Name,
+		"resourcenamespace": record.ResourceNamespace,
+		"resourceuid":       record.ResourceUID,
+		"message":           record.Message,
+	}
+	for field, value := range optional {
+		if value != "" {
+			key[field] = value
+		} else {
+			key[field] = bson.M{"$exists": false}
+		}
+	}
+	return key
+}
+
+// partialError converts bulk error to storage.PartialError with indexes of failed records.
+// Duplicate key errors are ignored, because such records are already stored.
+// sources (if not nil) maps operation indexes to record indexes.
+func partialError(err error, sources [][]int) error {
+	bulkErr, ok := err.(*mgo.BulkError)
+	if !ok {
+		return err
+	}
+	partial := &storage.PartialError{Err: err}
+	for _, ecase := range bulkErr.Cases() {
+		if mgo.IsDup(ecase.Err) {
+			continue
+		}
+		if ecase.Index < 0 {
+			return err
+		}
+		if sources == nil {
+			partial.Failed = append(partial.Failed, ecase.Index)
+			continue
+		}
+		partial.Failed = append(partial.Failed, sources[ecase.Index]...)
+	}
+	if len(partial.Failed) == 0 {
+		return nil
+	}
+	return partial
+}
+
+// mergeUpserts merges upserts with the same key in collected order: last record is set,
+// counters are summed and maximums of comparable values are kept. Upserts without key are kept as is.
+// Sources contain indexes of merged upserts in r.
+func mergeUpserts(r []storage.Upsert) (merged []storage.Upsert, sources [][]int) {
+	merged = make([]storage.Upsert, 0, len(r))
+	byKey := make(map[string]int)
+	for idx, upsert := range r {
+		if len(upsert.Key) == 0 {
+			merged = append(merged, upsert)
+			sources = append(sources, []int{idx})
+			continue
+		}
+		// fmt prints maps with sorted keys
+		key := fmt.Sprintf("%v", upsert.Key)
+		i, ok := byKey[key]
+		if !ok {
+			byKey[key] = len(merged)
+			merged = append(merged, upsert)
+			sources = append(sources, []int{idx})
+			continue
+		}
+		sources[i] = append(sources[i], idx)
+		prev := merged[i]
+		// fields set on insert are taken from the first upsert
+		next := storage.Upsert{Key: upsert.Key, Set: upsert.Set, SetOnInsert: prev.SetOnInsert}
+		if next.SetOnInsert == nil {
+			next.SetOnInsert = upsert.SetOnInsert
+		}
+		if len(prev.Inc)+len(upsert.Inc) > 0 {
+			next.Inc = make(map[string]int)
+			for field, v := range prev.Inc {
+				next.Inc[field] += v
+			}
+			for field, v := range upsert.Inc {
+				next.Inc[field] += v
+			}
+		}
+		if len(prev.Max)+len(upsert.Max) > 0 {
+			next.Max = make(map[string]interface{})
+			for field, v := range prev.Max {
+				next.Max[field] = v
+			}
+			for field, v := range upsert.Max {
+				if old, ok := next.Max[field]; !ok || greater(v, old) {
+					next.Max[field] = v
+				}
+			}
+		}
+		merged[i] = next
+	}
+	return merged, sources
+}
+
+// greater compares values of $max fields. Values of unknown types are treated as greater, so the latest one is kept.
+func greater(a, b interface{}) bool {
+	switch a := a.(type) {
+	case int:
+		if b, ok := b.(int); ok {
+			return a > b
+		}
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			return a.After(b)
+		}
+	}
+	return true
+}
+
+// Close stops sweeper and closes session. It is safe to call Close several times.
 func (s *Storage) Close() error {
-	s.log.Debugf("Closing storage")
-	s.db.Session.Close()
+	s.onceClose.Do(func() {
+		s.log.Debugf("Closing storage")
+		close(s.stop)
+		s.db.Session.Close()
+	})
 	return nil
 }
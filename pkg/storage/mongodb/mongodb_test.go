@@ -0,0 +1,82 @@
+package mongodb
+
+import (
+	"testing"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/storage"
+	"github.com/globalsign/mgo/bson"
+)
+
+func TestSeriesUpdateKeepsDateAdded(t *testing.T) {
+	first := time.Date(2018, 7, 1, 10, 0, 0, 0, time.UTC)
+	upsert := func(added time.Time, message string) storage.Upsert {
+		return storage.Upsert{
+			Key:         map[string]interface{}{"resourceuid": "uid"},
+			Set:         kubeClientModel.Event{ResourceUID: "uid", Message: message, DateAdded: added},
+			SetOnInsert: map[string]interface{}{"dateadded": added},
+		}
+	}
+	merged, _ := mergeUpserts([]storage.Upsert{upsert(first, "a"), upsert(first.Add(time.Hour), "b")})
+	if len(merged) != 1 || merged[0].SetOnInsert["dateadded"] != first {
+		t.Fatalf("unexpected merged upserts %+v", merged)
+	}
+
+	set, err := withoutFields(merged[0].Set, merged[0].SetOnInsert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := set.(bson.M)
+	if _, ok := fields["dateadded"]; ok || fields["message"] != "b" {
+		t.Errorf("unexpected set fields %v", fields)
+	}
+}
+
+func TestQuerySelectorSkipsControlDocuments(t *testing.T) {
+	selector, err := querySelector(storage.EventQuery{Collection: SystemCollection})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, ok := selector["_id"].(bson.M)
+	if !ok || id["$type"] != "objectId" {
+		t.Errorf("control documents are not excluded by selector %v", selector)
+	}
+}
+
+func TestQuerySelectorSeries(t *testing.T) {
+	seen := time.Date(2018, 7, 1, 10, 0, 0, 0, time.UTC)
+	selector, err := querySelector(storage.EventQuery{Collection: EventsCollection, MinOccurrences: 5, SeenSince: seen})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if occurrences, ok := selector["occurrences"].(bson.M); !ok || occurrences["$gte"] != 5 {
+		t.Errorf("unexpected occurrences selector %v", selector["occurrences"])
+	}
+	if lastSeen, ok := selector["lastseen"].(bson.M); !ok || lastSeen["$gte"] != seen {
+		t.Errorf("unexpected last seen selector %v", selector["lastseen"])
+	}
+}
+
+func TestStoredSeriesAreReturnedInDetails(t *testing.T) {
+	data, err := bson.Marshal(bson.M{
+		"_id":         bson.NewObjectId(),
+		"eventname":   "BackOff",
+		"details":     bson.M{"reason": "BackOff"},
+		"occurrences": 3,
+		"firstseen":   time.Date(2018, 7, 1, 10, 0, 0, 0, time.UTC),
+		"lastseen":    time.Date(2018, 7, 1, 11, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored storedEvent
+	if err := bson.Unmarshal(data, &stored); err != nil {
+		t.Fatal(err)
+	}
+	details := stored.withSeriesDetails().Details
+	if details[DetailsCount] != "3" || details[DetailsFirstSeen] != "2018-07-01T10:00:00Z" ||
+		details[DetailsLastSeen] != "2018-07-01T11:00:00Z" || details["reason"] != "BackOff" {
+		t.Errorf("unexpected details %v", details)
+	}
+}
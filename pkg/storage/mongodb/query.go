@@ -0,0 +1,182 @@
+package mongodb
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/storage"
+	"github.com/globalsign/mgo/bson"
+)
+
+// Details keys of kubernetes event series. Series are stored in typed fields "occurrences", "firstseen" and "lastseen",
+// so they can be queried, and are returned in details of queried events.
+const (
+	DetailsCount     = "count"
+	DetailsFirstSeen = "first_seen"
+	DetailsLastSeen  = "last_seen"
+)
+
+type storedEvent struct {
+	ID                    bson.ObjectId `bson:"_id"`
+	kubeClientModel.Event `bson:",inline"`
+
+	Occurrences int       `bson:"occurrences,omitempty"`
+	FirstSeen   time.Time `bson:"firstseen,omitempty"`
+	LastSeen    time.Time `bson:"lastseen,omitempty"`
+}
+
+// withSeriesDetails returns event with series fields in details.
+func (event storedEvent) withSeriesDetails() kubeClientModel.Event {
+	ret := event.Event
+	details := make(map[string]string, len(ret.Details)+3)
+	for k, v := range ret.Details {
+		details[k] = v
+	}
+	if event.Occurrences > 0 {
+		details[DetailsCount] = strconv.Itoa(event.Occurrences)
+	}
+	if !event.FirstSeen.IsZero() {
+		details[DetailsFirstSeen] = event.FirstSeen.UTC().Format(time.RFC3339)
+	}
+	if !event.LastSeen.IsZero() {
+		details[DetailsLastSeen] = event.LastSeen.UTC().Format(time.RFC3339)
+	}
+	if len(details) > 0 {
+		ret.Details = details
+	}
+	return ret
+}
+
+// Cursor points to last returned record. Records are sorted by date added and ID,
+// because records inserted by one bulk have same date added.
+func encodeCursor(event storedEvent) string {
+	return base64.RawURLEncoding.EncodeToString(
+		[]byte(fmt.Sprintf("%d:%s", event.DateAdded.UnixNano(), event.ID.Hex())))
+}
+
+func decodeCursor(cursor string) (time.Time, bson.ObjectId, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", storage.ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 || !bson.IsObjectIdHex(parts[1]) {
+		return time.Time{}, "", storage.ErrInvalidCursor
+	}
+	nsec, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, "", storage.ErrInvalidCursor
+	}
+	return time.Unix(0, nsec), bson.ObjectIdHex(parts[1]), nil
+}
+
+func isKnownCollection(collection string) bool {
+	for _, v := range Collections {
+		if v == collection {
+			return true
+		}
+	}
+	return false
+}
+
+// querySelector builds selector with equality conditions first, so compound indexes created by ensureIndexes
+// ("resourcetype", "resourcename", "resourcenamespace", "dateadded") can be used for filtering and sorting.
+func querySelector(query storage.EventQuery) (bson.M, error) {
+	selector := bson.M{}
+	if query.ResourceType != "" {
+		selector["resourcetype"] = query.ResourceType
+	}
+	if query.ResourceName != "" {
+		selector["resourcename"] = query.ResourceName
+	}
+	if query.ResourceNamespace != "" {
+		selector["resourcenamespace"] = query.ResourceNamespace
+	}
+	if query.ResourceUID != "" {
+		selector["resourceuid"] = query.ResourceUID
+	}
+	if query.Name != "" {
+		selector["eventname"] = query.Name
+	}
+	if len(query.Kinds) > 0 {
+		selector["eventkind"] = bson.M{"$in": query.Kinds}
+	}
+	if query.MinOccurrences > 0 {
+		selector["occurrences"] = bson.M{"$gte": query.MinOccurrences}
+	}
+	if !query.SeenSince.IsZero() {
+		selector["lastseen"] = bson.M{"$gte": query.SeenSince}
+	}
+
+	dateAdded := bson.M{}
+	if !query.Since.IsZero() {
+		dateAdded["$gte"] = query.Since
+	}
+	if !query.Until.IsZero() {
+		dateAdded["$lt"] = query.Until
+	}
+	if len(dateAdded) > 0 {
+		selector["dateadded"] = dateAdded
+	}
+
+	if query.Cursor != "" {
+		lastDateAdded, lastID, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		selector["$or"] = []bson.M{
+			{"dateadded": bson.M{"$lt": lastDateAdded}},
+			{"dateadded": lastDateAdded, "_id": bson.M{"$lt": lastID}},
+		}
+	}
+
+	// records have generated object ids, control documents (schema version, migration lock and leader lease
+	// in system collection) have string ids
+	selector["_id"] = bson.M{"$type": "objectId"}
+	return selector, nil
+}
+
+func (s *Storage) QueryEvents(query storage.EventQuery) (storage.EventPage, error) {
+	if !isKnownCollection(query.Collection) {
+		return storage.EventPage{}, storage.ErrUnknownCollection
+	}
+	if query.Limit <= 0 {
+		query.Limit = storage.DefaultQueryLimit
+	}
+	if query.Limit > storage.MaxQueryLimit {
+		query.Limit = storage.MaxQueryLimit
+	}
+	selector, err := querySelector(query)
+	if err != nil {
+		return storage.EventPage{}, err
+	}
+	s.log.WithField("selector", selector).Debug("Query events")
+
+	var stored []storedEvent
+	err = s.db.C(query.Collection).Find(selector).
+		Sort("-dateadded", "-_id").
+		Limit(query.Limit).
+		All(&stored)
+	if err != nil {
+		return storage.EventPage{}, err
+	}
+
+	page := storage.EventPage{
+		Events: make([]kubeClientModel.Event, 0, len(stored)),
+	}
+	for _, event := range stored {
+		if query.Collection == EventsCollection {
+			page.Events = append(page.Events, event.withSeriesDetails())
+			continue
+		}
+		page.Events = append(page.Events, event.Event)
+	}
+	if len(stored) == query.Limit {
+		page.NextCursor = encodeCursor(stored[len(stored)-1])
+	}
+	return page, nil
+}
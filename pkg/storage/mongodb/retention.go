@@ -0,0 +1,190 @@
+package mongodb
+
+import (
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultRetention = 30 * 24 * time.Hour
+
+// RetentionRule overrides retention of records.
+// Empty Collection matches all collections, empty Kind and Name match all records in collection.
+type RetentionRule struct {
+	Collection  string
+	Kind        kubeClientModel.EventKind
+	Name        string
+	ExpireAfter time.Duration
+}
+
+func (rule RetentionRule) matchesAllRecords() bool {
+	return rule.Kind == "" && rule.Name == ""
+}
+
+func (rule RetentionRule) filter() bson.M {
+	filter := bson.M{}
+	if rule.Kind != "" {
+		filter["eventkind"] = rule.Kind
+	}
+	if rule.Name != "" {
+		filter["eventname"] = rule.Name
+	}
+	return filter
+}
+
+// Retention describes how long records are kept in storage.
+// Collection TTL index expires records after longest retention applicable to collection,
+// records with shorter retention are removed by sweeper.
+type Retention struct {
+	// Default retention for records not matched by any rule.
+	Default     time.Duration
+	Rules       []RetentionRule
+	SweepPeriod time.Duration
+}
+
+// collectionRetention returns retention of records not matched by kind or name rules and kind or name rules for collection.
+// Collection-specific rules take precedence over rules for all collections.
+func (r Retention) collectionRetention(collection string) (time.Duration, []RetentionRule) {
+	collectionDefault := r.Default
+	if collectionDefault <= 0 {
+		collectionDefault = defaultRetention
+	}
+	var rules []RetentionRule
+	recordRules := map[RetentionRule]int{}
+	for _, rule := range r.Rules {
+		if rule.Collection != "" && rule.Collection != collection {
+			continue
+		}
+		if rule.matchesAllRecords() {
+			if rule.Collection == collection {
+				collectionDefault = rule.ExpireAfter
+			}
+			continue
+		}
+		key := RetentionRule{Kind: rule.Kind, Name: rule.Name}
+		if i, exists := recordRules[key]; exists {
+			if rule.Collection == collection {
+				rules[i] = rule
+			}
+			continue
+		}
+		recordRules[key] = len(rules)
+		rules = append(rules, rule)
+	}
+	return collectionDefault, rules
+}
+
+// expireAfter returns TTL for collection index.
+func (r Retention) expireAfter(collection string) time.Duration {
+	ttl, rules := r.collectionRetention(collection)
+	for _, rule := range rules {
+		if rule.ExpireAfter > ttl {
+			ttl = rule.ExpireAfter
+		}
+	}
+	return ttl
+}
+
+func (s *Storage) ensureExpirationIndex(collection *mgo.Collection) error {
+	expireAfter := s.retention.expireAfter(collection.Name)
+	index := dateExpirationIndex
+	index.ExpireAfter = expireAfter
+
+	indexes, err := collection.Indexes()
+	if err != nil {
+		return err
+	}
+	for _, existing := range indexes {
+		if existing.Name != index.Name {
+			continue
+		}
+		if existing.ExpireAfter == expireAfter {
+			return nil
+		}
+		s.log.WithFields(log.Fields{
+			"collection": collection.Name,
+			"old_ttl":    existing.ExpireAfter,
+			"new_ttl":    expireAfter,
+		}).Info("Changing records TTL")
+		return s.db.Run(bson.D{
+			{Name: "collMod", Value: collection.Name},
+			{Name: "index", Value: bson.M{
+				"keyPattern":         bson.M{"dateadded": 1},
+				"expireAfterSeconds": int(expireAfter / time.Second),
+			}},
+		}, nil)
+	}
+	return collection.EnsureIndex(index)
+}
+
+// sweep removes records which retention is shorter than collection TTL.
+func (s *Storage) sweep() {
+	now := time.Now()
+	for _, collectionName := range Collections {
+		ttl := s.retention.expireAfter(collectionName)
+		collectionDefault, rules := s.retention.collectionRetention(collectionName)
+		collection := s.db.C(collectionName)
+
+		var ruleFilters []bson.M
+		for _, rule := range rules {
+			ruleFilters = append(ruleFilters, rule.filter())
+		}
+		if collectionDefault < ttl {
+			selector := bson.M{"dateadded": bson.M{"$lt": now.Add(-collectionDefault)}}
+			if len(ruleFilters) > 0 {
+				selector["$nor"] = ruleFilters
+			}
+			s.sweepCollection(collection, selector)
+		}
+
+		for _, rule := range rules {
+			if rule.ExpireAfter >= ttl {
+				continue
+			}
+			selector := rule.filter()
+			selector["dateadded"] = bson.M{"$lt": now.Add(-rule.ExpireAfter)}
+			// records matched by rule with longer retention must be kept
+			var keep []bson.M
+			for _, other := range rules {
+				if other.ExpireAfter > rule.ExpireAfter {
+					keep = append(keep, other.filter())
+				}
+			}
+			if len(keep) > 0 {
+				selector["$nor"] = keep
+			}
+			s.sweepCollection(collection, selector)
+		}
+	}
+}
+
+func (s *Storage) sweepCollection(collection *mgo.Collection, selector bson.M) {
+	info, err := collection.RemoveAll(selector)
+	if err != nil {
+		s.log.WithError(err).WithField("collection", collection.Name).Error("Sweep failed")
+		return
+	}
+	s.log.WithFields(log.Fields{
+		"collection": collection.Name,
+		"removed":    info.Removed,
+	}).Debug("Swept expired records")
+}
+
+func (s *Storage) runSweeper() {
+	if s.retention.SweepPeriod <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.retention.SweepPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.sweep()
+		}
+	}
+}
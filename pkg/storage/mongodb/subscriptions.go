@@ -0,0 +1,104 @@
+package mongodb
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/containerum/kube-events/pkg/subscription"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	SubscriptionsCollection = "subscriptions"
+	DeliveriesCollection    = "deliveries"
+)
+
+// DeliveryLogRetention is a time of keeping delivery log entries.
+const DeliveryLogRetention = 7 * 24 * time.Hour
+
+var deliveryExpirationIndex = mgo.Index{
+	Name:        "time_expiration",
+	Key:         []string{"time"},
+	ExpireAfter: DeliveryLogRetention,
+}
+
+func (s *Storage) ensureSubscriptionIndexes() error {
+	var errs []string
+	if err := s.db.C(SubscriptionsCollection).EnsureIndexKey("tenant"); err != nil {
+		errs = append(errs, err.Error())
+	}
+	deliveries := s.db.C(DeliveriesCollection)
+	if err := deliveries.EnsureIndex(deliveryExpirationIndex); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if err := deliveries.EnsureIndexKey("tenant", "subscriptionid", "-time"); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ","))
+	}
+	return nil
+}
+
+func subscriptionError(err error) error {
+	if err == mgo.ErrNotFound {
+		return subscription.ErrNotFound
+	}
+	return err
+}
+
+func (s *Storage) CreateSubscription(sub subscription.Subscription) error {
+	s.log.WithField("tenant", sub.Tenant).Debug("Create subscription")
+	return s.db.C(SubscriptionsCollection).Insert(sub)
+}
+
+func (s *Storage) UpdateSubscription(sub subscription.Subscription) error {
+	s.log.WithField("tenant", sub.Tenant).Debug("Update subscription")
+	return subscriptionError(s.db.C(SubscriptionsCollection).Update(bson.M{"_id": sub.ID, "tenant": sub.Tenant}, sub))
+}
+
+func (s *Storage) DeleteSubscription(tenant, id string) error {
+	s.log.WithField("tenant", tenant).Debug("Delete subscription")
+	return subscriptionError(s.db.C(SubscriptionsCollection).Remove(bson.M{"_id": id, "tenant": tenant}))
+}
+
+func (s *Storage) GetSubscription(tenant, id string) (subscription.Subscription, error) {
+	var sub subscription.Subscription
+	err := s.db.C(SubscriptionsCollection).Find(bson.M{"_id": id, "tenant": tenant}).One(&sub)
+	return sub, subscriptionError(err)
+}
+
+func (s *Storage) ListSubscriptions(tenant string) ([]subscription.Subscription, error) {
+	selector := bson.M{}
+	if tenant != "" {
+		selector["tenant"] = tenant
+	}
+	subs := []subscription.Subscription{}
+	err := s.db.C(SubscriptionsCollection).Find(selector).Sort("createdat").All(&subs)
+	return subs, err
+}
+
+func (s *Storage) LogDelivery(delivery subscription.Delivery) error {
+	return s.db.C(DeliveriesCollection).Insert(delivery)
+}
+
+func (s *Storage) ListDeliveries(query subscription.DeliveryQuery) ([]subscription.Delivery, error) {
+	if query.Limit <= 0 {
+		query.Limit = subscription.DefaultDeliveryLimit
+	}
+	if query.Limit > subscription.MaxDeliveryLimit {
+		query.Limit = subscription.MaxDeliveryLimit
+	}
+	selector := bson.M{
+		"tenant":         query.Tenant,
+		"subscriptionid": query.SubscriptionID,
+	}
+	if query.FailedOnly {
+		selector["success"] = false
+	}
+	deliveries := []subscription.Delivery{}
+	err := s.db.C(DeliveriesCollection).Find(selector).Sort("-time").Limit(query.Limit).All(&deliveries)
+	return deliveries, err
+}
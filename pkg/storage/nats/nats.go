@@ -0,0 +1,304 @@
+package nats
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// MsgIDHeader is a JetStream deduplication header.
+	MsgIDHeader = "Nats-Msg-Id"
+	// CollectionHeader contains record collection.
+	CollectionHeader = "Kube-Events-Collection"
+
+	DefaultSubjectPrefix = "kube-events"
+)
+
+type Config struct {
+	// URLs of servers, i.e. "nats://nats:4222". Servers are used in turn after connection failures.
+	URLs          []string
+	SubjectPrefix string
+	// JetStream enables waiting for JetStream acks. Messages are published without acks if false.
+	JetStream bool
+	Username  string
+	Password  string
+	Token     string
+	TLS       *tls.Config
+	// Timeout is a connection and ack timeout.
+	Timeout time.Duration
+	// LazyConnect defers connection to the first publish, so unavailable server does not fail NewSink.
+	LazyConnect bool
+
+	// MaxAttempts is a number of attempts to publish records. Only not acknowledged records are published again.
+	MaxAttempts int
+	RetryDelay  time.Duration
+}
+
+// Sink publishes records to NATS subjects "<prefix>.<collection>.<namespace>.<kind>". It implements storage.EventBulkInserter.
+// Message ID is derived from collection and record, so JetStream drops records published again in duplicate window.
+type Sink struct {
+	cfg Config
+
+	mu      sync.Mutex
+	client  *client
+	nextURL int
+
+	log *log.Entry
+}
+
+// pubAck is a JetStream publish ack.
+type pubAck struct {
+	Stream    string `json:"stream"`
+	Sequence  uint64 `json:"seq"`
+	Duplicate bool   `json:"duplicate"`
+	Error     *struct {
+		Code        int    `json:"code"`
+		Description string `json:"description"`
+	} `json:"error"`
+}
+
+func NewSink(cfg Config) (*Sink, error) {
+	if len(cfg.URLs) == 0 {
+		return nil, fmt.Errorf("nats url is required")
+	}
+	if cfg.SubjectPrefix == "" {
+		cfg.SubjectPrefix = DefaultSubjectPrefix
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	s := &Sink{
+		cfg: cfg,
+		log: log.WithField("component", "nats_sink"),
+	}
+	if cfg.LazyConnect {
+		return s, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// subjectToken replaces characters not allowed in subject token. Empty token is replaced with "_".
+func subjectToken(token string) string {
+	token = strings.Map(func(r rune) rune {
+		switch {
+		case r == '.' || r == '*' || r == '>' || r <= ' ' || r == 127:
+			return '_'
+		}
+		return r
+	}, token)
+	if token == "" {
+		return "_"
+	}
+	return token
+}
+
+// Subject returns subject of record.
+func (s *Sink) Subject(record kubeClientModel.Event, collection string) string {
+	return strings.Join([]string{
+		s.cfg.SubjectPrefix,
+		subjectToken(collection),
+		subjectToken(record.ResourceNamespace),
+		subjectToken(string(record.Kind)),
+	}, ".")
+}
+
+func messageID(data []byte, collection string) string {
+	hash := sha256.New()
+	io.WriteString(hash, collection)
+	hash.Write([]byte{0})
+	hash.Write(data)
+	return hex.EncodeToString(hash.Sum(nil)[:16])
+}
+
+// connect returns current connection or connects to next server. It must be called with locked mutex.
+func (s *Sink) connect() (*client, error) {
+	if s.client != nil && !s.client.isClosed() {
+		return s.client, nil
+	}
+	if s.client != nil {
+		s.log.WithError(s.client.closeErr()).Info("Connection lost, reconnecting")
+		s.client = nil
+	}
+	url := s.cfg.URLs[s.nextURL%len(s.cfg.URLs)]
+	s.nextURL++
+	c, err := dial(url, s.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to %s: %v", url, err)
+	}
+	if s.cfg.JetStream && !c.info.Headers {
+		c.close()
+		return nil, fmt.Errorf("nats server %s does not support headers required by JetStream", url)
+	}
+	s.log.WithField("url", url).Info("Connected to NATS")
+	s.client = c
+	return c, nil
+}
+
+func (s *Sink) BulkInsert(r []kubeClientModel.Event, collection string) error {
+	if len(r) == 0 {
+		return nil
+	}
+	msgs := make([]message, len(r))
+	for i, record := range r {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		msgs[i] = message{
+			subject: s.Subject(record, collection),
+			headers: map[string]string{
+				MsgIDHeader:      messageID(data, collection),
+				CollectionHeader: collection,
+			},
+			data: data,
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rejected := 0
+	retry := storage.Retry{Attempts: s.cfg.MaxAttempts, Delay: s.cfg.RetryDelay}
+	err := retry.Do(s.log.WithField("collection", collection), func() error {
+		c, err := s.connect()
+		if err != nil {
+			return err
+		}
+		var tooLarge int
+		msgs, tooLarge = s.reject(c, msgs)
+		rejected += tooLarge
+		msgs, err = s.publish(c, msgs)
+		return err
+	})
+	if err == nil && rejected > 0 {
+		return storage.Final(fmt.Errorf("%d records exceed nats max payload %d", rejected, s.client.info.MaxPayload))
+	}
+	return err
+}
+
+// reject removes messages which exceed max payload of server, so they do not block other records.
+func (s *Sink) reject(c *client, msgs []message) ([]message, int) {
+	fitting := msgs[:0:0]
+	for _, msg := range msgs {
+		if c.fits(msg) {
+			fitting = append(fitting, msg)
+			continue
+		}
+		s.log.WithFields(log.Fields{
+			"subject": msg.subject,
+			"size":    len(msg.data),
+		}).Error("Record exceeds nats max payload, dropped")
+	}
+	return fitting, len(msgs) - len(fitting)
+}
+
+// publish returns messages which are not published.
+func (s *Sink) publish(c *client, msgs []message) ([]message, error) {
+	if len(msgs) == 0 {
+		return nil, nil
+	}
+	if !s.cfg.JetStream {
+		if err := c.publish(msgs, s.cfg.Timeout); err != nil {
+			return msgs, err
+		}
+		return nil, nil
+	}
+
+	type request struct {
+		replyTo string
+		reply   <-chan reply
+	}
+	requests := make([]request, len(msgs))
+	var failed []message
+	var firstErr error
+	fail := func(msg message, err error) {
+		failed = append(failed, msg)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	for i, msg := range msgs {
+		replyTo, ch, err := c.request(msg)
+		if err != nil {
+			// messages are deduplicated, so all of them can be published again
+			c.close()
+			return msgs, err
+		}
+		requests[i] = request{replyTo: replyTo, reply: ch}
+	}
+
+	timer := time.NewTimer(s.cfg.Timeout)
+	defer timer.Stop()
+	timeout := false
+	for i, req := range requests {
+		if timeout {
+			c.cancel(req.replyTo)
+			fail(msgs[i], fmt.Errorf("jetstream ack timeout"))
+			continue
+		}
+		select {
+		case rep := <-req.reply:
+			if err := checkAck(rep); err != nil {
+				fail(msgs[i], err)
+			}
+		case <-c.closed:
+			c.cancel(req.replyTo)
+			fail(msgs[i], c.closeErr())
+		case <-timer.C:
+			timeout = true
+			c.cancel(req.replyTo)
+			fail(msgs[i], fmt.Errorf("jetstream ack timeout"))
+		}
+	}
+	if len(failed) > 0 {
+		return failed, fmt.Errorf("%d of %d records not published: %v", len(failed), len(msgs), firstErr)
+	}
+	return nil, nil
+}
+
+func checkAck(rep reply) error {
+	switch rep.status {
+	case "":
+		//pass
+	case "503":
+		return fmt.Errorf("no jetstream stream for subject")
+	default:
+		return fmt.Errorf("jetstream returned status %s", rep.status)
+	}
+	var ack pubAck
+	if err := json.Unmarshal(rep.data, &ack); err != nil {
+		return fmt.Errorf("invalid jetstream ack: %v", err)
+	}
+	if ack.Error != nil {
+		return fmt.Errorf("jetstream error %d: %s", ack.Error.Code, ack.Error.Description)
+	}
+	return nil
+}
+
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != nil {
+		s.client.close()
+		s.client = nil
+	}
+	return nil
+}
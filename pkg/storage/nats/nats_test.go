@@ -0,0 +1,304 @@
+package nats
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/storage"
+)
+
+type testMessage struct {
+	subject string
+	headers string
+	data    string
+}
+
+// testServer is a stand-in NATS server. It answers messages with reply subject as JetStream does.
+type testServer struct {
+	t          *testing.T
+	listener   net.Listener
+	maxPayload int
+
+	mu sync.Mutex
+	// stalledPongs is a number of PINGs after handshake which are not answered
+	stalledPongs int
+	// ackStatus returns status of reply to published message, empty status acks message
+	ackStatus func(msg testMessage) string
+	conns     int
+	messages  []testMessage
+}
+
+func newTestServer(t *testing.T, maxPayload int) *testServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &testServer{t: t, listener: listener, maxPayload: maxPayload}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.mu.Lock()
+			server.conns++
+			server.mu.Unlock()
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *testServer) url() string {
+	return "nats://" + s.listener.Addr().String()
+}
+
+func (s *testServer) published() []testMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]testMessage(nil), s.messages...)
+}
+
+func (s *testServer) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
+
+func (s *testServer) serve(conn net.Conn) {
+	defer conn.Close()
+	fmt.Fprintf(conn, "INFO {\"headers\":true,\"max_payload\":%d}\r\n", s.maxPayload)
+	r := bufio.NewReader(conn)
+	handshake := true
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "CONNECT", "SUB":
+			//pass
+		case "PING":
+			s.mu.Lock()
+			stall := !handshake && s.stalledPongs > 0
+			if stall {
+				s.stalledPongs--
+			}
+			s.mu.Unlock()
+			handshake = false
+			if !stall {
+				io.WriteString(conn, "PONG\r\n")
+			}
+		case "PUB", "HPUB":
+			if err := s.receive(conn, r, fields); err != nil {
+				s.t.Errorf("invalid publish %q: %v", line, err)
+				return
+			}
+		default:
+			s.t.Errorf("unexpected operation %q", line)
+			return
+		}
+	}
+}
+
+// receive reads "PUB <subject> [reply] <size>" or "HPUB <subject> [reply] <header size> <total size>" payload.
+func (s *testServer) receive(conn net.Conn, r *bufio.Reader, fields []string) error {
+	withHeaders := fields[0] == "HPUB"
+	args := fields[1:]
+	total, err := strconv.Atoi(args[len(args)-1])
+	if err != nil {
+		return err
+	}
+	headerSize := 0
+	if withHeaders {
+		headerSize, err = strconv.Atoi(args[len(args)-2])
+		if err != nil {
+			return err
+		}
+		args = args[:len(args)-2]
+	} else {
+		args = args[:len(args)-1]
+	}
+	if total > s.maxPayload {
+		return fmt.Errorf("payload %d exceeds max payload %d", total, s.maxPayload)
+	}
+	payload := make([]byte, total+2)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return err
+	}
+	msg := testMessage{subject: args[0], headers: string(payload[:headerSize]), data: string(payload[headerSize:total])}
+
+	s.mu.Lock()
+	status := ""
+	if s.ackStatus != nil {
+		status = s.ackStatus(msg)
+	}
+	if status == "" {
+		s.messages = append(s.messages, msg)
+	}
+	s.mu.Unlock()
+
+	if len(args) < 2 {
+		return nil
+	}
+	replyTo := args[1]
+	if status != "" {
+		header := "NATS/1.0 " + status + "\r\n\r\n"
+		_, err = fmt.Fprintf(conn, "HMSG %s 1 %d %d\r\n%s\r\n", replyTo, len(header), len(header), header)
+		return err
+	}
+	ack := fmt.Sprintf(`{"stream":"events","seq":%d}`, len(s.messages))
+	_, err = fmt.Fprintf(conn, "MSG %s 1 %d\r\n%s\r\n", replyTo, len(ack), ack)
+	return err
+}
+
+func newTestSink(t *testing.T, server *testServer, jetStream bool) *Sink {
+	sink, err := NewSink(Config{
+		URLs:        []string{server.url()},
+		JetStream:   jetStream,
+		Timeout:     100 * time.Millisecond,
+		MaxAttempts: 3,
+		RetryDelay:  time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sink
+}
+
+func recordNames(t *testing.T, messages []testMessage) []string {
+	var names []string
+	for _, msg := range messages {
+		var record kubeClientModel.Event
+		if err := json.Unmarshal([]byte(msg.data), &record); err != nil {
+			t.Fatalf("invalid message data %q: %v", msg.data, err)
+		}
+		names = append(names, record.Name)
+	}
+	return names
+}
+
+func TestPublish(t *testing.T) {
+	server := newTestServer(t, 1<<20)
+	defer server.listener.Close()
+	sink := newTestSink(t, server, false)
+	defer sink.Close()
+
+	records := []kubeClientModel.Event{
+		{Name: "a", ResourceNamespace: "default", Kind: kubeClientModel.EventWarning},
+		{Name: "b", ResourceNamespace: "kube.system", Kind: kubeClientModel.EventInfo},
+	}
+	if err := sink.BulkInsert(records, "events"); err != nil {
+		t.Fatal(err)
+	}
+	published := server.published()
+	if names := recordNames(t, published); len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Fatalf("expected records a and b, got %v", names)
+	}
+	if published[1].subject != "kube-events.events.kube_system.info" {
+		t.Errorf("unexpected subject %q", published[1].subject)
+	}
+	if !strings.Contains(published[0].headers, MsgIDHeader+": ") {
+		t.Errorf("expected message id header, got %q", published[0].headers)
+	}
+}
+
+func TestOversizedRecordDoesNotBlockBatch(t *testing.T) {
+	server := newTestServer(t, 512)
+	defer server.listener.Close()
+	for _, jetStream := range []bool{false, true} {
+		server.mu.Lock()
+		server.messages = nil
+		server.mu.Unlock()
+		sink := newTestSink(t, server, jetStream)
+
+		records := []kubeClientModel.Event{{Name: "a"}, {Name: "large", Message: strings.Repeat("x", 1024)}, {Name: "b"}}
+		err := sink.BulkInsert(records, "events")
+		if !storage.IsFinal(err) {
+			t.Errorf("jetstream %v: expected final error, got %v", jetStream, err)
+		}
+		if names := recordNames(t, server.published()); len(names) != 2 || names[0] != "a" || names[1] != "b" {
+			t.Errorf("jetstream %v: expected records a and b, got %v", jetStream, names)
+		}
+		sink.Close()
+	}
+	if conns := server.connections(); conns != 2 {
+		t.Errorf("oversized record must not break connection, got %d connections", conns)
+	}
+}
+
+func TestPongTimeoutReconnects(t *testing.T) {
+	server := newTestServer(t, 1<<20)
+	defer server.listener.Close()
+	server.stalledPongs = 1
+	sink := newTestSink(t, server, false)
+	defer sink.Close()
+
+	if err := sink.BulkInsert([]kubeClientModel.Event{{Name: "a"}}, "events"); err != nil {
+		t.Fatal(err)
+	}
+	if conns := server.connections(); conns != 2 {
+		t.Fatalf("expected reconnect after ping timeout, got %d connections", conns)
+	}
+	// late PONG of first connection can not acknowledge next publish
+	if err := sink.BulkInsert([]kubeClientModel.Event{{Name: "b"}}, "events"); err != nil {
+		t.Fatal(err)
+	}
+	if names := recordNames(t, server.published()); len(names) != 3 || names[2] != "b" {
+		t.Errorf("expected record a published twice and record b, got %v", names)
+	}
+}
+
+func TestJetStreamRetriesNotAcknowledged(t *testing.T) {
+	server := newTestServer(t, 1<<20)
+	defer server.listener.Close()
+	failed := false
+	server.ackStatus = func(msg testMessage) string {
+		if !failed && strings.Contains(msg.data, `"b"`) {
+			failed = true
+			return "503"
+		}
+		return ""
+	}
+	sink := newTestSink(t, server, true)
+	defer sink.Close()
+
+	if err := sink.BulkInsert([]kubeClientModel.Event{{Name: "a"}, {Name: "b"}, {Name: "c"}}, "events"); err != nil {
+		t.Fatal(err)
+	}
+	if names := recordNames(t, server.published()); len(names) != 3 || names[2] != "b" {
+		t.Errorf("expected only record b to be published again, got %v", names)
+	}
+}
+
+func TestLazyConnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// nothing listens on address
+	addr := listener.Addr().String()
+	listener.Close()
+
+	sink, err := NewSink(Config{URLs: []string{"nats://" + addr}, Timeout: time.Second, LazyConnect: true})
+	if err != nil {
+		t.Fatalf("lazy sink must not connect, got %v", err)
+	}
+	defer sink.Close()
+	if err := sink.BulkInsert([]kubeClientModel.Event{{Name: "a"}}, "events"); err == nil {
+		t.Error("expected connection error")
+	}
+}
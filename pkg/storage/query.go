@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+)
+
+const (
+	DefaultQueryLimit = 100
+	MaxQueryLimit     = 1000
+)
+
+var (
+	ErrUnknownCollection = errors.New("unknown collection")
+	ErrInvalidCursor     = errors.New("invalid cursor")
+)
+
+// EventQuery describes filter of stored events. Empty fields match all records.
+type EventQuery struct {
+	Collection        string
+	ResourceType      kubeClientModel.ResourceType
+	ResourceNamespace string
+	ResourceName      string
+	ResourceUID       string
+	Kinds             []kubeClientModel.EventKind
+	Name              string
+	// MinOccurrences selects kubernetes events which occurred at least MinOccurrences times.
+	MinOccurrences int
+	// SeenSince selects kubernetes events which were last seen at SeenSince or later.
+	SeenSince time.Time
+	// Records added in [Since, Until) are returned.
+	Since time.Time
+	Until time.Time
+	// Limit is a maximum number of events in page.
+	Limit int
+	// Cursor is a NextCursor of previous page.
+	Cursor string
+}
+
+// EventPage contains events sorted from newest to oldest.
+type EventPage struct {
+	Events     []kubeClientModel.Event `json:"events"`
+	NextCursor string                  `json:"next_cursor,omitempty"`
+}
+
+type EventQuerier interface {
+	QueryEvents(query EventQuery) (EventPage, error)
+}
@@ -0,0 +1,283 @@
+package redis
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+const DefaultKeyPrefix = "kube-events:"
+
+// Flattened record fields
+const (
+	FieldKind              = "event_kind"
+	FieldTime              = "event_time"
+	FieldName              = "event_name"
+	FieldResourceType      = "resource_type"
+	FieldResourceName      = "resource_name"
+	FieldResourceNamespace = "resource_namespace"
+	FieldResourceUID       = "resource_uid"
+	FieldMessage           = "message"
+	// FieldDetailsPrefix is a prefix of record details fields, i.e. "details.reason".
+	FieldDetailsPrefix = "details."
+)
+
+type Config struct {
+	Addr     string
+	Username string
+	Password string
+	DB       int
+	// TLS enables TLS connection if set.
+	TLS *tls.Config
+	// KeyPrefix is a prefix of stream key. Stream key is "<prefix><collection>".
+	KeyPrefix string
+	// MaxLen limits stream length, older entries are trimmed on add. Stream is not trimmed if zero.
+	MaxLen int64
+	// ApproxTrim enables efficient trimming with "MAXLEN ~", stream may be slightly longer than MaxLen.
+	ApproxTrim bool
+	Timeout    time.Duration
+	// LazyConnect defers connection to the first write, so unavailable server does not fail NewSink.
+	LazyConnect bool
+
+	// MaxAttempts is a number of attempts to add records. Only records failed with connection or temporary errors
+	// (i.e. LOADING) are added again, records rejected with other errors (i.e. WRONGTYPE) are not retried.
+	MaxAttempts int
+	RetryDelay  time.Duration
+}
+
+// Sink adds records to Redis streams, one stream per collection. It implements storage.EventBulkInserter.
+// Record fields are flattened to stream entry fields, so consumers do not need to parse JSON.
+// Batch is sent in one pipeline. Records may be added twice if connection fails before replies are read.
+type Sink struct {
+	cfg Config
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+
+	log *log.Entry
+}
+
+func NewSink(cfg Config) (*Sink, error) {
+	if cfg.Addr == "" {
+		return nil, fmt.Errorf("redis address is required")
+	}
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = DefaultKeyPrefix
+	}
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	s := &Sink{
+		cfg: cfg,
+		log: log.WithField("component", "redis_sink"),
+	}
+	if cfg.LazyConnect {
+		return s, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Fields returns flattened record fields. Main fields are always present, details are sorted by name.
+func Fields(record kubeClientModel.Event) []string {
+	fields := []string{
+		FieldKind, string(record.Kind),
+		FieldTime, record.Time,
+		FieldName, record.Name,
+		FieldResourceType, string(record.ResourceType),
+		FieldResourceName, record.ResourceName,
+		FieldResourceNamespace, record.ResourceNamespace,
+		FieldResourceUID, record.ResourceUID,
+		FieldMessage, record.Message,
+	}
+	names := make([]string, 0, len(record.Details))
+	for name := range record.Details {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fields = append(fields, FieldDetailsPrefix+name, record.Details[name])
+	}
+	return fields
+}
+
+// StreamKey returns stream key of collection.
+func (s *Sink) StreamKey(collection string) string {
+	return s.cfg.KeyPrefix + collection
+}
+
+func (s *Sink) xadd(record kubeClientModel.Event, collection string) []string {
+	args := []string{"XADD", s.StreamKey(collection)}
+	if s.cfg.MaxLen > 0 {
+		args = append(args, "MAXLEN")
+		if s.cfg.ApproxTrim {
+			args = append(args, "~")
+		}
+		args = append(args, strconv.FormatInt(s.cfg.MaxLen, 10))
+	}
+	args = append(args, "*")
+	return append(args, Fields(record)...)
+}
+
+// connect connects to server if not connected. It must be called with locked mutex.
+func (s *Sink) connect() error {
+	if s.conn != nil {
+		return nil
+	}
+	dialer := &net.Dialer{Timeout: s.cfg.Timeout}
+	var conn net.Conn
+	var err error
+	if s.cfg.TLS != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.cfg.Addr, s.cfg.TLS)
+	} else {
+		conn, err = dialer.Dial("tcp", s.cfg.Addr)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to connect to redis: %v", err)
+	}
+	s.conn, s.r, s.w = conn, bufio.NewReader(conn), bufio.NewWriter(conn)
+
+	var commands [][]string
+	if s.cfg.Password != "" {
+		if s.cfg.Username != "" {
+			commands = append(commands, []string{"AUTH", s.cfg.Username, s.cfg.Password})
+		} else {
+			commands = append(commands, []string{"AUTH", s.cfg.Password})
+		}
+	}
+	if s.cfg.DB != 0 {
+		commands = append(commands, []string{"SELECT", strconv.Itoa(s.cfg.DB)})
+	}
+	commands = append(commands, []string{"PING"})
+	replies, err := s.pipeline(commands)
+	if err == nil {
+		for _, reply := range replies {
+			if replyErr, ok := reply.(error); ok {
+				err = replyErr
+				break
+			}
+		}
+	}
+	if err != nil {
+		s.disconnect()
+		return fmt.Errorf("unable to connect to redis: %v", err)
+	}
+	s.log.WithField("addr", s.cfg.Addr).Info("Connected to Redis")
+	return nil
+}
+
+func (s *Sink) disconnect() {
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.conn, s.r, s.w = nil, nil, nil
+}
+
+// pipeline sends commands and reads their replies. Error replies are returned in replies.
+// Returned error means that connection is broken, replies contain replies read before failure.
+func (s *Sink) pipeline(commands [][]string) ([]interface{}, error) {
+	if s.cfg.Timeout > 0 {
+		s.conn.SetDeadline(time.Now().Add(s.cfg.Timeout))
+		defer s.conn.SetDeadline(time.Time{})
+	}
+	for _, command := range commands {
+		if err := writeCommand(s.w, command...); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.w.Flush(); err != nil {
+		return nil, err
+	}
+	replies := make([]interface{}, 0, len(commands))
+	for range commands {
+		reply, err := readReply(s.r)
+		if replyErr, ok := err.(redisError); ok {
+			reply, err = replyErr, nil
+		}
+		if err != nil {
+			return replies, err
+		}
+		replies = append(replies, reply)
+	}
+	return replies, nil
+}
+
+func (s *Sink) BulkInsert(r []kubeClientModel.Event, collection string) error {
+	if len(r) == 0 {
+		return nil
+	}
+	commands := make([][]string, len(r))
+	for i, record := range r {
+		commands[i] = s.xadd(record, collection)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rejected []string
+	retry := storage.Retry{Attempts: s.cfg.MaxAttempts, Delay: s.cfg.RetryDelay}
+	err := retry.Do(s.log.WithField("collection", collection), func() error {
+		failed, reasons, err := s.add(commands)
+		rejected = append(rejected, reasons...)
+		commands = failed
+		return err
+	})
+	if err == nil && len(rejected) > 0 {
+		return storage.Final(fmt.Errorf("%d records rejected, first: %s", len(rejected), rejected[0]))
+	}
+	return err
+}
+
+// add returns commands which are failed with temporary errors and reasons of commands rejected with permanent errors.
+func (s *Sink) add(commands [][]string) (failed [][]string, rejected []string, err error) {
+	if err := s.connect(); err != nil {
+		return commands, nil, err
+	}
+	replies, err := s.pipeline(commands)
+	firstErr := err
+	for i, reply := range replies {
+		replyErr, ok := reply.(redisError)
+		switch {
+		case !ok:
+			//pass
+		case replyErr.temporary():
+			failed = append(failed, commands[i])
+			if firstErr == nil {
+				firstErr = replyErr
+			}
+		default:
+			s.log.WithError(replyErr).WithField("stream", commands[i][1]).Error("Record rejected")
+			rejected = append(rejected, replyErr.Error())
+		}
+	}
+	if err != nil {
+		// commands without replies may be added, but it is unknown
+		s.disconnect()
+		failed = append(failed, commands[len(replies):]...)
+	}
+	if len(failed) > 0 {
+		return failed, rejected, fmt.Errorf("%d of %d records not added: %v", len(failed), len(commands), firstErr)
+	}
+	return nil, rejected, nil
+}
+
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.disconnect()
+	return nil
+}
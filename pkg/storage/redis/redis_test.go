@@ -0,0 +1,229 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/storage"
+)
+
+// testRedis is an in-memory Redis stand-in supporting commands used by sink.
+type testRedis struct {
+	listener net.Listener
+
+	mu      sync.Mutex
+	strings map[string]string
+	streams map[string][][]string
+	nextID  int
+	// loading is a number of XADD commands answered with LOADING error
+	loading int
+	xadds   int
+}
+
+func newTestRedis(t *testing.T) *testRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &testRedis{
+		listener: listener,
+		strings:  make(map[string]string),
+		streams:  make(map[string][][]string),
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *testRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r, w := bufio.NewReader(conn), bufio.NewWriter(conn)
+	for {
+		request, err := readReply(r)
+		if err != nil {
+			return
+		}
+		items, _ := request.([]interface{})
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i], _ = item.(string)
+		}
+		s.mu.Lock()
+		reply := s.execute(args)
+		s.mu.Unlock()
+		w.WriteString(reply)
+		if r.Buffered() == 0 {
+			w.Flush()
+		}
+	}
+}
+
+func (s *testRedis) execute(args []string) string {
+	if len(args) == 0 {
+		return "-ERR empty command\r\n"
+	}
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "AUTH", "SELECT":
+		return "+OK\r\n"
+	case "XADD":
+		return s.xadd(args[1:])
+	default:
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	}
+}
+
+// xadd executes "XADD key [MAXLEN [~] n] * field value...".
+func (s *testRedis) xadd(args []string) string {
+	s.xadds++
+	if s.loading > 0 {
+		s.loading--
+		return "-LOADING Redis is loading the dataset in memory\r\n"
+	}
+	if len(args) < 2 {
+		return "-ERR wrong number of arguments for 'xadd' command\r\n"
+	}
+	key, args := args[0], args[1:]
+	if _, ok := s.strings[key]; ok {
+		return "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+	}
+	maxLen := -1
+	if strings.ToUpper(args[0]) == "MAXLEN" {
+		args = args[1:]
+		if args[0] == "~" {
+			args = args[1:]
+		}
+		maxLen, _ = strconv.Atoi(args[0])
+		args = args[1:]
+	}
+	if args[0] != "*" || len(args[1:])%2 != 0 {
+		return "-ERR syntax error\r\n"
+	}
+	s.streams[key] = append(s.streams[key], args[1:])
+	if maxLen >= 0 && len(s.streams[key]) > maxLen {
+		s.streams[key] = s.streams[key][len(s.streams[key])-maxLen:]
+	}
+	s.nextID++
+	id := fmt.Sprintf("%d-0", s.nextID)
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(id), id)
+}
+
+// entries returns values of field in stream entries.
+func (s *testRedis) entries(key, field string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var values []string
+	for _, entry := range s.streams[key] {
+		for i := 0; i < len(entry); i += 2 {
+			if entry[i] == field {
+				values = append(values, entry[i+1])
+			}
+		}
+	}
+	return values
+}
+
+func newTestSink(t *testing.T, server *testRedis, maxLen int64) *Sink {
+	sink, err := NewSink(Config{
+		Addr:        server.listener.Addr().String(),
+		Password:    "secret",
+		MaxLen:      maxLen,
+		Timeout:     time.Second,
+		MaxAttempts: 3,
+		RetryDelay:  time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sink
+}
+
+func assertValues(t *testing.T, got []string, expected ...string) {
+	t.Helper()
+	if strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestAddTrimsStream(t *testing.T) {
+	server := newTestRedis(t)
+	defer server.listener.Close()
+	sink := newTestSink(t, server, 2)
+	defer sink.Close()
+
+	records := []kubeClientModel.Event{
+		{Name: "a"},
+		{Name: "b", Details: map[string]string{"reason": "BackOff"}},
+		{Name: "c"},
+	}
+	if err := sink.BulkInsert(records, "events"); err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, server.entries("kube-events:events", FieldName), "b", "c")
+	assertValues(t, server.entries("kube-events:events", FieldDetailsPrefix+"reason"), "BackOff")
+}
+
+func TestTemporaryErrorIsRetried(t *testing.T) {
+	server := newTestRedis(t)
+	defer server.listener.Close()
+	server.loading = 1
+	sink := newTestSink(t, server, 0)
+	defer sink.Close()
+
+	if err := sink.BulkInsert([]kubeClientModel.Event{{Name: "a"}, {Name: "b"}}, "events"); err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, server.entries("kube-events:events", FieldName), "b", "a")
+}
+
+func TestPermanentErrorIsNotRetried(t *testing.T) {
+	server := newTestRedis(t)
+	defer server.listener.Close()
+	server.strings["kube-events:deployments"] = "value"
+	sink := newTestSink(t, server, 0)
+	defer sink.Close()
+
+	err := sink.BulkInsert([]kubeClientModel.Event{{Name: "a"}, {Name: "b"}}, "deployments")
+	if !storage.IsFinal(err) || !strings.Contains(err.Error(), "WRONGTYPE") {
+		t.Fatalf("expected final WRONGTYPE error, got %v", err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.xadds != 2 {
+		t.Errorf("rejected records must not be added again, got %d XADD commands", server.xadds)
+	}
+}
+
+func TestLazyConnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// nothing listens on address
+	addr := listener.Addr().String()
+	listener.Close()
+
+	sink, err := NewSink(Config{Addr: addr, Timeout: time.Second, LazyConnect: true})
+	if err != nil {
+		t.Fatalf("lazy sink must not connect, got %v", err)
+	}
+	defer sink.Close()
+	if err := sink.BulkInsert([]kubeClientModel.Event{{Name: "a"}}, "events"); err == nil {
+		t.Error("expected connection error")
+	}
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Retry describes write attempts of storage. Delay between attempts is doubled starting from Delay up to MaxDelay (if set).
+type Retry struct {
+	// Attempts is a number of attempts. Write is attempted once if it is less than 1.
+	Attempts int
+	Delay    time.Duration
+	MaxDelay time.Duration
+}
+
+// retryAfterError carries delay requested by storage, i.e. in Retry-After header.
+type retryAfterError struct {
+	err   error
+	after time.Duration
+}
+
+func (err *retryAfterError) Error() string {
+	return err.err.Error()
+}
+
+// RetryAfter makes error which is retried after delay instead of Retry delay. Delay is limited by MaxDelay.
+func RetryAfter(err error, after time.Duration) error {
+	if err == nil || after <= 0 {
+		return err
+	}
+	return &retryAfterError{err: err, after: after}
+}
+
+// Do calls write until it succeeds, returns final error or attempts are exhausted.
+// Write keeps its own state, so it may retry only records which were not written.
+// Error after several attempts is returned as final, so write is not retried again by callers.
+func (r Retry) Do(logger *log.Entry, write func() error) error {
+	delay := r.Delay
+	for attempt := 1; ; attempt++ {
+		err := write()
+		wait := delay
+		if after, ok := err.(*retryAfterError); ok {
+			err, wait = after.err, after.after
+		}
+		if err == nil || IsFinal(err) {
+			return err
+		}
+		if attempt >= r.Attempts {
+			if attempt > 1 {
+				return Final(err)
+			}
+			return err
+		}
+		if r.MaxDelay > 0 && wait > r.MaxDelay {
+			wait = r.MaxDelay
+		}
+		logger.WithError(err).WithFields(log.Fields{
+			"attempt": attempt,
+			"wait":    wait,
+		}).Debug("Write failed, retrying")
+		time.Sleep(wait)
+		delay *= 2
+		if r.MaxDelay > 0 && delay > r.MaxDelay {
+			delay = r.MaxDelay
+		}
+	}
+}
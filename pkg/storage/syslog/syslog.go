@@ -0,0 +1,362 @@
+package syslog
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	log "github.com/sirupsen/logrus"
+)
+
+// Transports
+const (
+	NetworkUDP = "udp"
+	NetworkTCP = "tcp"
+	NetworkTLS = "tls"
+)
+
+// Severities (RFC 5424 section 6.2.1)
+const (
+	severityErr     = 3
+	severityWarning = 4
+	severityNotice  = 5
+	severityInfo    = 6
+)
+
+// sdID is a structured data ID with private enterprise number reserved for documentation (RFC 5612).
+const sdID = "kube@32473"
+
+// maxUDPMessage limits UDP datagram size, longer messages are truncated.
+const maxUDPMessage = 65000
+
+type Config struct {
+	Network string
+	Addr    string
+	// TLS is used with NetworkTLS.
+	TLS *tls.Config
+	// Facility is a syslog facility code, i.e. 1 (user) or 16 (local0).
+	Facility int
+	Hostname string
+	AppName  string
+
+	// QueueSize is a number of messages queued while connection is unavailable. Oldest messages are dropped if queue is full.
+	QueueSize      int
+	DialTimeout    time.Duration
+	WriteTimeout   time.Duration
+	ReconnectDelay time.Duration
+}
+
+// Sink writes records as RFC 5424 messages. It implements storage.EventBulkInserter.
+// Records are queued and written by background goroutine, which reconnects after failures.
+type Sink struct {
+	cfg Config
+
+	mu    sync.Mutex
+	cond  *sync.Cond
+	queue []string
+	// sending is true while first queued message is written
+	sending bool
+	closed  bool
+
+	conn net.Conn
+	// serverClosed is closed when stream connection is closed by server
+	serverClosed chan struct{}
+	done         chan struct{}
+	log          *log.Entry
+}
+
+func NewSink(cfg Config) (*Sink, error) {
+	switch cfg.Network {
+	case NetworkUDP, NetworkTCP, NetworkTLS:
+		//pass
+	default:
+		return nil, fmt.Errorf("unknown syslog network %q", cfg.Network)
+	}
+	if cfg.Addr == "" {
+		return nil, fmt.Errorf("syslog address is required")
+	}
+	if cfg.Facility < 0 || cfg.Facility > 23 {
+		return nil, fmt.Errorf("invalid syslog facility %d", cfg.Facility)
+	}
+	if cfg.Hostname == "" {
+		cfg.Hostname, _ = os.Hostname()
+	}
+	if cfg.AppName == "" {
+		cfg.AppName = "kube-events"
+	}
+	if cfg.QueueSize < 1 {
+		cfg.QueueSize = 1
+	}
+	s := &Sink{
+		cfg:  cfg,
+		done: make(chan struct{}),
+		log:  log.WithField("component", "syslog_sink"),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	go s.run()
+	return s, nil
+}
+
+func severity(kind kubeClientModel.EventKind) int {
+	switch kind {
+	case kubeClientModel.EventError:
+		return severityErr
+	case kubeClientModel.EventWarning:
+		return severityWarning
+	case kubeClientModel.EventInfo:
+		return severityInfo
+	default:
+		return severityNotice
+	}
+}
+
+// nilValue returns value or "-" if value is empty, truncated to max length of header field.
+func nilValue(value string, maxLen int) string {
+	value = strings.Map(func(r rune) rune {
+		if r <= 32 || r >= 127 {
+			return -1
+		}
+		return r
+	}, value)
+	if value == "" {
+		return "-"
+	}
+	if len(value) > maxLen {
+		value = value[:maxLen]
+	}
+	return value
+}
+
+var sdEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// format makes RFC 5424 message:
+//
+//	<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [kube@32473 collection="" namespace="" ...] MSG
+func (s *Sink) format(record kubeClientModel.Event, collection string) string {
+	timestamp := "-"
+	if t, err := time.Parse(time.RFC3339Nano, record.Time); err == nil {
+		timestamp = t.UTC().Format(time.RFC3339Nano)
+	} else if !record.DateAdded.IsZero() {
+		timestamp = record.DateAdded.UTC().Format(time.RFC3339Nano)
+	}
+
+	var sd strings.Builder
+	sd.WriteString("[" + sdID)
+	params := []struct{ name, value string }{
+		{"collection", collection},
+		{"namespace", record.ResourceNamespace},
+		{"resource_type", string(record.ResourceType)},
+		{"resource_name", record.ResourceName},
+		{"resource_uid", record.ResourceUID},
+		{"kind", string(record.Kind)},
+	}
+	for _, param := range params {
+		if param.value != "" {
+			sd.WriteString(" " + param.name + `="` + sdEscaper.Replace(param.value) + `"`)
+		}
+	}
+	sd.WriteString("]")
+
+	msg := fmt.Sprintf("<%d>1 %s %s %s %d %s %s",
+		s.cfg.Facility*8+severity(record.Kind),
+		timestamp,
+		nilValue(s.cfg.Hostname, 255),
+		nilValue(s.cfg.AppName, 48),
+		os.Getpid(),
+		nilValue(record.Name, 32),
+		sd.String())
+	if record.Message != "" {
+		msg += " " + record.Message
+	}
+	return msg
+}
+
+func (s *Sink) BulkInsert(r []kubeClientModel.Event, collection string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return fmt.Errorf("syslog sink is closed")
+	}
+	for _, record := range r {
+		s.queue = append(s.queue, s.format(record, collection))
+	}
+	if overflow := len(s.queue) - s.cfg.QueueSize; overflow > 0 {
+		if s.sending {
+			s.queue = append(s.queue[:1], s.queue[1+overflow:]...)
+		} else {
+			s.queue = s.queue[overflow:]
+		}
+		s.log.WithField("dropped", overflow).Error("Syslog queue is full, oldest messages dropped")
+	}
+	s.cond.Signal()
+	return nil
+}
+
+// Close writes queued messages if connection is available and closes connection.
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.cond.Signal()
+	s.mu.Unlock()
+	<-s.done
+	return nil
+}
+
+func (s *Sink) dial() error {
+	dialer := &net.Dialer{Timeout: s.cfg.DialTimeout}
+	network := s.cfg.Network
+	if network == NetworkTLS {
+		network = "tcp"
+	}
+	conn, err := dialer.Dial(network, s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	if s.cfg.Network == NetworkTLS {
+		tlsConn := tls.Client(conn, s.tlsConfig())
+		if s.cfg.DialTimeout > 0 {
+			tlsConn.SetDeadline(time.Now().Add(s.cfg.DialTimeout))
+		}
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return err
+		}
+		tlsConn.SetDeadline(time.Time{})
+		conn = tlsConn
+	}
+	s.conn = conn
+	if s.cfg.Network != NetworkUDP {
+		s.serverClosed = make(chan struct{})
+		go watch(conn, s.serverClosed)
+	}
+	return nil
+}
+
+// tlsConfig sets server name from address if it is not set, like tls.Dial does.
+func (s *Sink) tlsConfig() *tls.Config {
+	cfg := s.cfg.TLS
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+	if cfg.ServerName != "" {
+		return cfg
+	}
+	cfg = cfg.Clone()
+	cfg.ServerName = s.cfg.Addr
+	if host, _, err := net.SplitHostPort(s.cfg.Addr); err == nil {
+		cfg.ServerName = host
+	}
+	return cfg
+}
+
+// watch reads stream connection until it is closed, so message is not written to connection closed by server.
+// Syslog servers do not send data, read data is discarded.
+func watch(conn net.Conn, closed chan<- struct{}) {
+	defer close(closed)
+	io.Copy(ioutil.Discard, conn)
+}
+
+func (s *Sink) closedByServer() bool {
+	select {
+	case <-s.serverClosed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Sink) disconnect() {
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.conn, s.serverClosed = nil, nil
+}
+
+// frame adds octet-counting framing (RFC 6587) for stream transports.
+func (s *Sink) frame(msg string) []byte {
+	if s.cfg.Network == NetworkUDP {
+		if len(msg) > maxUDPMessage {
+			msg = msg[:maxUDPMessage]
+		}
+		return []byte(msg)
+	}
+	return []byte(strconv.Itoa(len(msg)) + " " + msg)
+}
+
+// next waits for queued message. It returns false if sink is closed and queue is empty.
+func (s *Sink) next() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.queue) == 0 && !s.closed {
+		s.cond.Wait()
+	}
+	if len(s.queue) == 0 {
+		return "", false
+	}
+	s.sending = true
+	return s.queue[0], true
+}
+
+// sent removes message from queue if it was written.
+func (s *Sink) sent(ok bool) {
+	s.mu.Lock()
+	if ok {
+		s.queue = s.queue[1:]
+	}
+	s.sending = false
+	s.mu.Unlock()
+}
+
+func (s *Sink) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *Sink) run() {
+	defer close(s.done)
+	defer s.disconnect()
+	for {
+		msg, ok := s.next()
+		if !ok {
+			return
+		}
+		if s.conn != nil && s.closedByServer() {
+			s.log.Info("Syslog connection closed by server, reconnecting")
+			s.disconnect()
+		}
+		if s.conn == nil {
+			if err := s.dial(); err != nil {
+				s.sent(false)
+				s.log.WithError(err).Error("Unable to connect to syslog server")
+				if s.isClosed() {
+					return
+				}
+				time.Sleep(s.cfg.ReconnectDelay)
+				continue
+			}
+		}
+		if s.cfg.WriteTimeout > 0 {
+			s.conn.SetWriteDeadline(time.Now().Add(s.cfg.WriteTimeout))
+		}
+		if _, err := s.conn.Write(s.frame(msg)); err != nil {
+			s.sent(false)
+			s.log.WithError(err).Error("Syslog write failed, reconnecting")
+			s.disconnect()
+			if s.isClosed() {
+				return
+			}
+			time.Sleep(s.cfg.ReconnectDelay)
+			continue
+		}
+		s.sent(true)
+	}
+}
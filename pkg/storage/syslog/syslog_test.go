@@ -0,0 +1,169 @@
+package syslog
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+)
+
+// testServer accepts stream connections and reads octet-counted messages.
+type testServer struct {
+	listener net.Listener
+	messages chan string
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func newTestServer(t *testing.T, listener net.Listener) *testServer {
+	server := &testServer{listener: listener, messages: make(chan string, 1000)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.mu.Lock()
+			server.conns = append(server.conns, conn)
+			server.mu.Unlock()
+			go server.read(t, conn)
+		}
+	}()
+	return server
+}
+
+func (s *testServer) read(t *testing.T, conn net.Conn) {
+	r := bufio.NewReader(conn)
+	for {
+		prefix, err := r.ReadString(' ')
+		if err != nil {
+			return
+		}
+		size, err := strconv.Atoi(strings.TrimSuffix(prefix, " "))
+		if err != nil {
+			t.Errorf("invalid frame length %q", prefix)
+			return
+		}
+		msg := make([]byte, size)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			return
+		}
+		s.messages <- string(msg)
+	}
+}
+
+// closeConns closes accepted connections, like server restart does.
+func (s *testServer) closeConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *testServer) receive(t *testing.T, expected int) []string {
+	t.Helper()
+	var messages []string
+	timeout := time.After(5 * time.Second)
+	for len(messages) < expected {
+		select {
+		case msg := <-s.messages:
+			messages = append(messages, msg)
+		case <-timeout:
+			t.Fatalf("received %d messages, expected %d", len(messages), expected)
+		}
+	}
+	return messages
+}
+
+func testTLSConfigs(t *testing.T) (server, client *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "syslog"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}},
+		&tls.Config{RootCAs: roots}
+}
+
+func TestStreamReconnectsAfterServerClose(t *testing.T) {
+	serverTLS, clientTLS := testTLSConfigs(t)
+	for _, network := range []string{NetworkTCP, NetworkTLS} {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if network == NetworkTLS {
+			listener = tls.NewListener(listener, serverTLS)
+		}
+		server := newTestServer(t, listener)
+		sink, err := NewSink(Config{
+			Network:        network,
+			Addr:           listener.Addr().String(),
+			TLS:            clientTLS,
+			AppName:        "test",
+			QueueSize:      1000,
+			DialTimeout:    time.Second,
+			ReconnectDelay: 10 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		records := make([]kubeClientModel.Event, 500)
+		for i := range records {
+			records[i] = kubeClientModel.Event{Name: "Created", Message: fmt.Sprint(i)}
+		}
+		sink.BulkInsert(records, "events")
+		for i, msg := range server.receive(t, len(records)) {
+			if !strings.HasSuffix(msg, "] "+fmt.Sprint(i)) {
+				t.Fatalf("%s: message %d is corrupted or out of order: %q", network, i, msg)
+			}
+		}
+
+		server.closeConns()
+		time.Sleep(50 * time.Millisecond)
+		sink.BulkInsert([]kubeClientModel.Event{{Name: "Deleted", Message: "after reconnect"}}, "events")
+		if msg := server.receive(t, 1)[0]; !strings.HasSuffix(msg, "] after reconnect") {
+			t.Errorf("%s: unexpected message after reconnect %q", network, msg)
+		}
+
+		sink.Close()
+		listener.Close()
+		server.closeConns()
+	}
+}
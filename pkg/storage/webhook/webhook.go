@@ -0,0 +1,199 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/signature"
+	"github.com/containerum/kube-events/pkg/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// Payload formats
+const (
+	// FormatJSON posts kubeClientModel.EventsList.
+	FormatJSON = "json"
+	// FormatNDJSON posts one kubeClientModel.Event per line.
+	FormatNDJSON = "ndjson"
+)
+
+// Request headers
+const (
+	CollectionHeader     = "X-Kube-Events-Collection"
+	IdempotencyKeyHeader = "Idempotency-Key"
+)
+
+type Config struct {
+	// URL receives records of collections which are not in CollectionURLs.
+	URL            string
+	CollectionURLs map[string]string
+	Format         string
+	// Secret (if set) is a key of payload HMAC-SHA256 signature sent in X-Kube-Events-Signature header.
+	Secret  string
+	Headers map[string]string
+	Timeout time.Duration
+
+	// MaxAttempts is a number of attempts of batch sending. Retry-After response header overrides RetryDelay.
+	MaxAttempts   int
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	// RetryStatuses are response status codes ("503") or classes ("5xx") which are retried.
+	RetryStatuses []string
+}
+
+// Sink posts batches of records to HTTP endpoints. It implements storage.EventBulkInserter.
+type Sink struct {
+	cfg    Config
+	client *http.Client
+	log    *log.Entry
+}
+
+func NewSink(cfg Config) (*Sink, error) {
+	switch cfg.Format {
+	case "":
+		cfg.Format = FormatJSON
+	case FormatJSON, FormatNDJSON:
+		//pass
+	default:
+		return nil, fmt.Errorf("unknown webhook format %q", cfg.Format)
+	}
+	if cfg.URL == "" && len(cfg.CollectionURLs) == 0 {
+		return nil, fmt.Errorf("webhook url is required")
+	}
+	for _, status := range cfg.RetryStatuses {
+		if !validRetryStatus(status) {
+			return nil, fmt.Errorf("invalid webhook retry status %q", status)
+		}
+	}
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	return &Sink{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		log:    log.WithField("component", "webhook_sink"),
+	}, nil
+}
+
+func validRetryStatus(status string) bool {
+	if len(status) != 3 {
+		return false
+	}
+	if strings.HasSuffix(status, "xx") {
+		return status[0] >= '1' && status[0] <= '5'
+	}
+	code, err := strconv.Atoi(status)
+	return err == nil && code >= 100 && code < 600
+}
+
+func (s *Sink) shouldRetry(code int) bool {
+	str := strconv.Itoa(code)
+	for _, status := range s.cfg.RetryStatuses {
+		if status == str || (strings.HasSuffix(status, "xx") && status[0] == str[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Sink) encode(records []kubeClientModel.Event) ([]byte, string, error) {
+	if s.cfg.Format == FormatJSON {
+		body, err := json.Marshal(kubeClientModel.EventsList{Events: records})
+		return body, "application/json", err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return nil, "", err
+		}
+	}
+	return buf.Bytes(), "application/x-ndjson", nil
+}
+
+// idempotencyKey is a payload hash, so batches resent by record buffer after failure have same key.
+func idempotencyKey(collection string, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, collection)
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (s *Sink) BulkInsert(records []kubeClientModel.Event, collection string) error {
+	url, ok := s.cfg.CollectionURLs[collection]
+	if !ok {
+		url = s.cfg.URL
+	}
+	if url == "" {
+		s.log.WithField("collection", collection).Debug("No webhook for collection, records skipped")
+		return nil
+	}
+	body, contentType, err := s.encode(records)
+	if err != nil {
+		return err
+	}
+	key := idempotencyKey(collection, body)
+
+	retry := storage.Retry{Attempts: s.cfg.MaxAttempts, Delay: s.cfg.RetryDelay, MaxDelay: s.cfg.MaxRetryDelay}
+	return retry.Do(s.log.WithField("collection", collection), func() error {
+		return s.post(url, collection, contentType, key, body)
+	})
+}
+
+// post returns final error if response status is not retried.
+func (s *Sink) post(url, collection, contentType, key string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return storage.Final(err)
+	}
+	for k, v := range s.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(CollectionHeader, collection)
+	req.Header.Set(IdempotencyKeyHeader, key)
+	if s.cfg.Secret != "" {
+		req.Header.Set(signature.Header, signature.Sign(s.cfg.Secret, body))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("webhook returned %s", resp.Status)
+	if !s.shouldRetry(resp.StatusCode) {
+		return storage.Final(err)
+	}
+	return storage.RetryAfter(err, parseRetryAfter(resp.Header.Get("Retry-After")))
+}
+
+// parseRetryAfter reads Retry-After header in seconds or HTTP-date format.
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
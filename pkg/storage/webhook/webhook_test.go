@@ -0,0 +1,165 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/signature"
+	"github.com/containerum/kube-events/pkg/storage"
+)
+
+// testEndpoint stores received requests and answers with statuses in order, then with 200.
+type testEndpoint struct {
+	mu       sync.Mutex
+	headers  []http.Header
+	bodies   [][]byte
+	statuses []int
+	// retryAfter is sent with failed responses
+	retryAfter string
+}
+
+func (e *testEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.headers = append(e.headers, r.Header)
+	e.bodies = append(e.bodies, body)
+	if len(e.statuses) > 0 {
+		status := e.statuses[0]
+		e.statuses = e.statuses[1:]
+		if e.retryAfter != "" {
+			w.Header().Set("Retry-After", e.retryAfter)
+		}
+		w.WriteHeader(status)
+	}
+}
+
+func (e *testEndpoint) requests() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.bodies)
+}
+
+func newTestSink(t *testing.T, endpoint *testEndpoint, cfg Config) (*Sink, func()) {
+	server := httptest.NewServer(endpoint)
+	cfg.URL = server.URL
+	sink, err := NewSink(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sink, server.Close
+}
+
+func TestSignatureAndIdempotencyKey(t *testing.T) {
+	endpoint := &testEndpoint{}
+	sink, stop := newTestSink(t, endpoint, Config{Format: FormatNDJSON, Secret: "secret"})
+	defer stop()
+
+	records := []kubeClientModel.Event{{Name: "a"}, {Name: "b"}}
+	for _, collection := range []string{"events", "events", "system"} {
+		if err := sink.BulkInsert(records, collection); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, header := range endpoint.headers {
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(endpoint.bodies[i])
+		if expected := "sha256=" + hex.EncodeToString(mac.Sum(nil)); header.Get(signature.Header) != expected {
+			t.Errorf("expected signature %s, got %s", expected, header.Get(signature.Header))
+		}
+		if ct := header.Get("Content-Type"); ct != "application/x-ndjson" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+	}
+	if lines := strings.Count(string(endpoint.bodies[0]), "\n"); lines != 2 {
+		t.Errorf("expected 2 lines, got %d", lines)
+	}
+	keys := make([]string, len(endpoint.headers))
+	for i, header := range endpoint.headers {
+		keys[i] = header.Get(IdempotencyKeyHeader)
+	}
+	if keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("key of the same batch is not stable: %v", keys)
+	}
+	if keys[0] == keys[2] {
+		t.Error("batches of different collections have the same key")
+	}
+	if collection := endpoint.headers[2].Get(CollectionHeader); collection != "system" {
+		t.Errorf("unexpected collection header %q", collection)
+	}
+}
+
+func TestRetryStatuses(t *testing.T) {
+	if _, err := NewSink(Config{URL: "http://localhost", RetryStatuses: []string{"6xx"}}); err == nil {
+		t.Error("expected invalid retry status error")
+	}
+
+	cfg := Config{MaxAttempts: 3, RetryDelay: time.Millisecond, RetryStatuses: []string{"5xx", "429"}}
+	for _, tc := range []struct {
+		status   int
+		retried  bool
+		requests int
+	}{
+		{status: http.StatusServiceUnavailable, retried: true, requests: 2},
+		{status: http.StatusTooManyRequests, retried: true, requests: 2},
+		{status: http.StatusNotFound, requests: 1},
+	} {
+		endpoint := &testEndpoint{statuses: []int{tc.status}}
+		sink, stop := newTestSink(t, endpoint, cfg)
+		err := sink.BulkInsert([]kubeClientModel.Event{{Name: "a"}}, "events")
+		stop()
+		if tc.retried && err != nil {
+			t.Errorf("%d: %v", tc.status, err)
+		}
+		if !tc.retried && !storage.IsFinal(err) {
+			t.Errorf("%d: expected final error, got %v", tc.status, err)
+		}
+		if endpoint.requests() != tc.requests {
+			t.Errorf("%d: expected %d requests, got %d", tc.status, tc.requests, endpoint.requests())
+		}
+	}
+}
+
+func TestRetryAfterIsCapped(t *testing.T) {
+	endpoint := &testEndpoint{statuses: []int{http.StatusServiceUnavailable}, retryAfter: "3600"}
+	sink, stop := newTestSink(t, endpoint, Config{
+		MaxAttempts:   2,
+		RetryDelay:    time.Millisecond,
+		MaxRetryDelay: 50 * time.Millisecond,
+		RetryStatuses: []string{"503"},
+	})
+	defer stop()
+
+	start := time.Now()
+	if err := sink.BulkInsert([]kubeClientModel.Event{{Name: "a"}}, "events"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > 5*time.Second {
+		t.Errorf("expected retry after capped delay, waited %v", elapsed)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if wait := parseRetryAfter("2"); wait != 2*time.Second {
+		t.Errorf("unexpected delay %v", wait)
+	}
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if wait := parseRetryAfter(date); wait <= 55*time.Second || wait > time.Minute {
+		t.Errorf("unexpected delay %v of %s", wait, date)
+	}
+	for _, header := range []string{"", "-1", "soon", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)} {
+		if wait := parseRetryAfter(header); wait != 0 {
+			t.Errorf("unexpected delay %v of %q", wait, header)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package stream
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	log "github.com/sirupsen/logrus"
+)
+
+// Record is a transformed event published to subscribers.
+type Record struct {
+	// ID is unique in hub lifetime and increases with every published record.
+	ID         string                `json:"id"`
+	Collection string                `json:"collection"`
+	Received   time.Time             `json:"-"`
+	Event      kubeClientModel.Event `json:"event"`
+
+	seq uint64
+}
+
+// Filter selects records for subscriber. Empty fields match all records.
+type Filter struct {
+	Collections   []string
+	Namespace     string
+	ResourceTypes []kubeClientModel.ResourceType
+	Kinds         []kubeClientModel.EventKind
+}
+
+func (f Filter) Match(r Record) bool {
+	if f.Namespace != "" && r.Event.ResourceNamespace != f.Namespace {
+		return false
+	}
+	if len(f.Collections) > 0 && !containsString(f.Collections, r.Collection) {
+		return false
+	}
+	if len(f.ResourceTypes) > 0 {
+		matched := false
+		for _, resourceType := range f.ResourceTypes {
+			matched = matched || resourceType == r.Event.ResourceType
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(f.Kinds) > 0 {
+		matched := false
+		for _, kind := range f.Kinds {
+			matched = matched || kind == r.Event.Kind
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Resume describes position to replay records from. Empty LastID and zero Since mean no replay.
+type Resume struct {
+	LastID string
+	Since  time.Time
+}
+
+type Subscription struct {
+	hub    *Hub
+	filter Filter
+	ch     chan Record
+
+	closed     bool
+	overflowed bool
+}
+
+// C returns channel of records. Channel is closed when subscription is cancelled or subscriber is too slow.
+func (s *Subscription) C() <-chan Record {
+	return s.ch
+}
+
+// Overflowed reports whether subscription was closed because subscriber did not read records in time.
+func (s *Subscription) Overflowed() bool {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.overflowed
+}
+
+func (s *Subscription) Cancel() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.removeLocked(s)
+}
+
+type HubConfig struct {
+	// History is a number of last records kept for resuming subscriptions.
+	History int
+	// SubscriberBuffer is a number of records which may be queued for subscriber before it will be disconnected.
+	SubscriberBuffer int
+}
+
+// Stats contains counters of hub lifetime.
+type Stats struct {
+	Published             uint64
+	Subscribers           uint64
+	DroppedSubscribers    uint64
+	PublishedByCollection map[string]uint64
+}
+
+// Hub distributes records to subscribers without blocking publishers.
+type Hub struct {
+	cfg   HubConfig
+	epoch string
+
+	mu                    sync.Mutex
+	seq                   uint64
+	history               []Record
+	subscribers           map[*Subscription]struct{}
+	droppedSubscribers    uint64
+	publishedByCollection map[string]uint64
+
+	log *log.Entry
+}
+
+func NewHub(cfg HubConfig) *Hub {
+	return &Hub{
+		cfg:                   cfg,
+		epoch:                 strconv.FormatInt(time.Now().UnixNano(), 36),
+		history:               make([]Record, 0, cfg.History),
+		subscribers:           make(map[*Subscription]struct{}),
+		publishedByCollection: make(map[string]uint64),
+		log:                   log.WithField("component", "stream_hub"),
+	}
+}
+
+func (h *Hub) Stats() Stats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	stats := Stats{
+		Published:             h.seq,
+		Subscribers:           uint64(len(h.subscribers)),
+		DroppedSubscribers:    h.droppedSubscribers,
+		PublishedByCollection: make(map[string]uint64, len(h.publishedByCollection)),
+	}
+	for collection, published := range h.publishedByCollection {
+		stats.PublishedByCollection[collection] = published
+	}
+	return stats
+}
+
+func (h *Hub) recordID(seq uint64) string {
+	return fmt.Sprintf("%s.%d", h.epoch, seq)
+}
+
+// parseID returns record sequence number if record was published by this hub.
+func (h *Hub) parseID(id string) (uint64, bool) {
+	parts := strings.SplitN(id, ".", 2)
+	if len(parts) != 2 || parts[0] != h.epoch {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(parts[1], 10, 64)
+	return seq, err == nil
+}
+
+func (h *Hub) Publish(collection string, event kubeClientModel.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	h.publishedByCollection[collection]++
+	record := Record{
+		ID:         h.recordID(h.seq),
+		Collection: collection,
+		Received:   time.Now(),
+		Event:      event,
+		seq:        h.seq,
+	}
+	if h.cfg.History > 0 {
+		if len(h.history) == h.cfg.History {
+			copy(h.history, h.history[1:])
+			h.history = h.history[:len(h.history)-1]
+		}
+		h.history = append(h.history, record)
+	}
+
+	for sub := range h.subscribers {
+		if !sub.filter.Match(record) {
+			continue
+		}
+		select {
+		case sub.ch <- record:
+		default:
+			h.log.Debug("Subscriber is too slow, disconnecting")
+			sub.overflowed = true
+			h.droppedSubscribers++
+			h.removeLocked(sub)
+		}
+	}
+}
+
+// Tee publishes records read from input and passes them to returned channel.
+func (h *Hub) Tee(collection string, input <-chan kubeClientModel.Event) <-chan kubeClientModel.Event {
+	outCh := make(chan kubeClientModel.Event)
+	go func() {
+		for event := range input {
+			h.Publish(collection, event)
+			outCh <- event
+		}
+		close(outCh)
+	}()
+	return outCh
+}
+
+// Subscribe registers subscriber and returns records from history after resume position.
+// Records after LastID are replayed if it is found in history, otherwise records received after Since are replayed.
+// Resumed is true if LastID is found in history, so replay contains all records after it.
+func (h *Hub) Subscribe(filter Filter, resume Resume) (sub *Subscription, replay []Record, resumed bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if resume.LastID != "" {
+		seq, ok := h.parseID(resume.LastID)
+		if ok && (len(h.history) == 0 || seq+1 >= h.history[0].seq) {
+			resumed = true
+			for _, record := range h.history {
+				if record.seq > seq && filter.Match(record) {
+					replay = append(replay, record)
+				}
+			}
+		}
+	}
+	if !resumed && !resume.Since.IsZero() {
+		for _, record := range h.history {
+			if record.Received.After(resume.Since) && filter.Match(record) {
+				replay = append(replay, record)
+			}
+		}
+	}
+
+	sub = &Subscription{
+		hub:    h,
+		filter: filter,
+		ch:     make(chan Record, h.cfg.SubscriberBuffer),
+	}
+	h.subscribers[sub] = struct{}{}
+	return sub, replay, resumed
+}
+
+func (h *Hub) removeLocked(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(h.subscribers, sub)
+	close(sub.ch)
+}
@@ -0,0 +1,270 @@
+package subscription
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/containerum/kube-events/pkg/signature"
+	"github.com/containerum/kube-events/pkg/stream"
+	log "github.com/sirupsen/logrus"
+)
+
+// Webhook request headers
+const (
+	SignatureHeader    = signature.Header
+	SubscriptionHeader = "X-Kube-Events-Subscription"
+	DeliveryHeader     = "X-Kube-Events-Delivery"
+)
+
+type DispatcherConfig struct {
+	Store Store
+	// Workers is a number of concurrent deliveries.
+	Workers   int
+	QueueSize int
+	// MaxAttempts is a number of delivery attempts before it is logged as failed.
+	MaxAttempts int
+	// RetryDelay is a delay before second attempt. Delay is doubled after every attempt up to MaxRetryDelay.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	// Timeout limits webhook request duration.
+	Timeout time.Duration
+	// RefreshPeriod is a period of reloading subscriptions from store.
+	RefreshPeriod time.Duration
+	// AllowPrivateTargets allows webhooks to loopback, link-local and cluster addresses.
+	AllowPrivateTargets bool
+}
+
+// Dispatcher matches records with subscriptions and delivers them to webhooks.
+type Dispatcher struct {
+	cfg    DispatcherConfig
+	client *http.Client
+
+	mu            sync.RWMutex
+	subscriptions []Subscription
+
+	queue chan job
+	// overflow contains jobs which did not fit queue, they are logged as failed by separate goroutine
+	overflow chan job
+	stop     chan struct{}
+	wg       sync.WaitGroup
+	log      *log.Entry
+}
+
+type job struct {
+	subscription Subscription
+	record       stream.Record
+}
+
+// webhookPayload is a body of webhook request.
+type webhookPayload struct {
+	SubscriptionID string `json:"subscription_id"`
+	stream.Record
+}
+
+func NewDispatcher(cfg DispatcherConfig) *Dispatcher {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	client := TargetClient(cfg.Timeout)
+	if cfg.AllowPrivateTargets {
+		client = &http.Client{Timeout: cfg.Timeout}
+	}
+	return &Dispatcher{
+		cfg:      cfg,
+		client:   client,
+		queue:    make(chan job, cfg.QueueSize),
+		overflow: make(chan job, cfg.QueueSize),
+		stop:     make(chan struct{}),
+		log:      log.WithField("component", "subscription_dispatcher"),
+	}
+}
+
+// Refresh reloads subscriptions from store. It should be called after subscriptions were changed.
+func (d *Dispatcher) Refresh() error {
+	subscriptions, err := d.cfg.Store.ListSubscriptions("")
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.subscriptions = subscriptions
+	d.mu.Unlock()
+	d.log.WithField("subscriptions", len(subscriptions)).Debug("Subscriptions refreshed")
+	return nil
+}
+
+// Process queues record delivery to matching subscriptions. If queue is full, delivery is logged as failed
+// in background, so slow delivery log does not delay records processing.
+func (d *Dispatcher) Process(record stream.Record) {
+	d.mu.RLock()
+	subscriptions := d.subscriptions
+	d.mu.RUnlock()
+	for _, sub := range subscriptions {
+		if !sub.Match(record) {
+			continue
+		}
+		j := job{subscription: sub, record: record}
+		select {
+		case d.queue <- j:
+			continue
+		default:
+		}
+		select {
+		case d.overflow <- j:
+		default:
+			d.log.WithFields(log.Fields{
+				"subscription": sub.ID,
+				"record":       record.ID,
+			}).Error("Delivery queue is full, delivery is not logged")
+		}
+	}
+}
+
+func (d *Dispatcher) overflowLogger() {
+	defer d.wg.Done()
+	for j := range d.overflow {
+		d.logDelivery(j, 0, 0, fmt.Errorf("delivery queue is full"))
+	}
+}
+
+func (d *Dispatcher) worker() {
+	defer d.wg.Done()
+	for j := range d.queue {
+		d.deliver(j)
+	}
+}
+
+// deliver sends record to webhook. Network errors, 5xx and 429 responses are retried with exponential backoff.
+func (d *Dispatcher) deliver(j job) {
+	deliveryID := NewID()
+	body, err := json.Marshal(webhookPayload{SubscriptionID: j.subscription.ID, Record: j.record})
+	if err != nil {
+		d.logDelivery(j, 0, 0, err)
+		return
+	}
+
+	delay := d.cfg.RetryDelay
+	var status int
+	for attempt := 1; ; attempt++ {
+		var retry bool
+		status, retry, err = d.post(j.subscription, deliveryID, body)
+		if err == nil || !retry || attempt >= d.cfg.MaxAttempts {
+			d.logDelivery(j, attempt, status, err)
+			return
+		}
+		d.log.WithError(err).WithFields(log.Fields{
+			"subscription": j.subscription.ID,
+			"attempt":      attempt,
+		}).Debug("Delivery failed, retrying")
+		select {
+		case <-time.After(delay):
+		case <-d.stop:
+			d.logDelivery(j, attempt, status, err)
+			return
+		}
+		delay *= 2
+		if d.cfg.MaxRetryDelay > 0 && delay > d.cfg.MaxRetryDelay {
+			delay = d.cfg.MaxRetryDelay
+		}
+	}
+}
+
+func (d *Dispatcher) post(sub Subscription, deliveryID string, body []byte) (status int, retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SubscriptionHeader, sub.ID)
+	req.Header.Set(DeliveryHeader, deliveryID)
+	req.Header.Set(SignatureHeader, signature.Sign(sub.Secret, body))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, false, nil
+	}
+	retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return resp.StatusCode, retry, fmt.Errorf("webhook returned %s", resp.Status)
+}
+
+func (d *Dispatcher) logDelivery(j job, attempts, status int, deliveryErr error) {
+	delivery := Delivery{
+		ID:                NewID(),
+		SubscriptionID:    j.subscription.ID,
+		Tenant:            j.subscription.Tenant,
+		RecordID:          j.record.ID,
+		Collection:        j.record.Collection,
+		EventName:         j.record.Event.Name,
+		EventKind:         j.record.Event.Kind,
+		ResourceType:      j.record.Event.ResourceType,
+		ResourceName:      j.record.Event.ResourceName,
+		ResourceNamespace: j.record.Event.ResourceNamespace,
+		Attempts:          attempts,
+		StatusCode:        status,
+		Success:           deliveryErr == nil,
+		Time:              time.Now(),
+	}
+	if deliveryErr != nil {
+		delivery.Error = deliveryErr.Error()
+		d.log.WithError(deliveryErr).WithFields(log.Fields{
+			"subscription": j.subscription.ID,
+			"attempts":     attempts,
+		}).Warn("Delivery failed")
+	}
+	if err := d.cfg.Store.LogDelivery(delivery); err != nil {
+		d.log.WithError(err).Error("Unable to log delivery")
+	}
+}
+
+// Run starts delivery workers and processes records from hub until stop is closed.
+// Subscription to hub is renewed if dispatcher does not keep up with records.
+func (d *Dispatcher) Run(hub *stream.Hub, stop <-chan struct{}) {
+	if err := d.Refresh(); err != nil {
+		d.log.WithError(err).Error("Unable to load subscriptions")
+	}
+	for i := 0; i < d.cfg.Workers; i++ {
+		d.wg.Add(1)
+		go d.worker()
+	}
+	d.wg.Add(1)
+	go d.overflowLogger()
+	refresh := time.NewTicker(d.cfg.RefreshPeriod)
+	defer refresh.Stop()
+	for {
+		sub, _, _ := hub.Subscribe(stream.Filter{}, stream.Resume{})
+	readLoop:
+		for {
+			select {
+			case record, ok := <-sub.C():
+				if !ok {
+					d.log.Error("Subscription dispatcher is too slow, records were skipped")
+					break readLoop
+				}
+				d.Process(record)
+			case <-refresh.C:
+				if err := d.Refresh(); err != nil {
+					d.log.WithError(err).Error("Unable to refresh subscriptions")
+				}
+			case <-stop:
+				sub.Cancel()
+				close(d.stop)
+				close(d.queue)
+				close(d.overflow)
+				d.wg.Wait()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package subscription
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	kubeClientModel "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/kube-events/pkg/stream"
+)
+
+var ErrNotFound = errors.New("subscription not found")
+
+// NewID returns random identifier of subscription or delivery.
+func NewID() string {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
+
+// Subscription delivers records of tenant namespace to webhook.
+type Subscription struct {
+	ID            string                         `json:"id" bson:"_id"`
+	Tenant        string                         `json:"-"`
+	Namespace     string                         `json:"namespace"`
+	ResourceTypes []kubeClientModel.ResourceType `json:"resource_types,omitempty"`
+	Kinds         []kubeClientModel.EventKind    `json:"event_kinds,omitempty"`
+	URL           string                         `json:"url"`
+	// Secret is a key of webhook body HMAC-SHA256 signature. It is returned to tenant only on creation.
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (s Subscription) Filter() stream.Filter {
+	return stream.Filter{
+		Namespace:     s.Namespace,
+		ResourceTypes: s.ResourceTypes,
+		Kinds:         s.Kinds,
+	}
+}
+
+func (s Subscription) Match(record stream.Record) bool {
+	return s.Filter().Match(record)
+}
+
+// Validate checks fields set by tenant.
+func (s Subscription) Validate() error {
+	if s.Namespace == "" {
+		return fmt.Errorf("namespace is required")
+	}
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %v", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be absolute http or https URL")
+	}
+	for _, kind := range s.Kinds {
+		switch kind {
+		case kubeClientModel.EventError, kubeClientModel.EventWarning, kubeClientModel.EventInfo:
+			//pass
+		default:
+			return fmt.Errorf("invalid event kind %q", kind)
+		}
+	}
+	return nil
+}
+
+// Delivery is a delivery log entry. It is written after last attempt of record delivery.
+type Delivery struct {
+	ID                string                       `json:"id" bson:"_id"`
+	SubscriptionID    string                       `json:"subscription_id"`
+	Tenant            string                       `json:"-"`
+	RecordID          string                       `json:"record_id"`
+	Collection        string                       `json:"collection"`
+	EventName         string                       `json:"event_name"`
+	EventKind         kubeClientModel.EventKind    `json:"event_kind"`
+	ResourceType      kubeClientModel.ResourceType `json:"resource_type"`
+	ResourceName      string                       `json:"resource_name"`
+	ResourceNamespace string                       `json:"resource_namespace"`
+	Attempts          int                          `json:"attempts"`
+	StatusCode        int                          `json:"status_code,omitempty"`
+	Error             string                       `json:"error,omitempty"`
+	Success           bool                         `json:"success"`
+	Time              time.Time                    `json:"time"`
+}
+
+// DeliveryQuery selects last deliveries of tenant subscription.
+type DeliveryQuery struct {
+	Tenant         string
+	SubscriptionID string
+	FailedOnly     bool
+	Limit          int
+}
+
+const (
+	DefaultDeliveryLimit = 50
+	MaxDeliveryLimit     = 500
+)
+
+// Store keeps subscriptions and delivery log. Tenant is checked by all methods which accept it.
+type Store interface {
+	CreateSubscription(sub Subscription) error
+	UpdateSubscription(sub Subscription) error
+	DeleteSubscription(tenant, id string) error
+	GetSubscription(tenant, id string) (Subscription, error)
+	// ListSubscriptions returns subscriptions of tenant or all subscriptions if tenant is empty.
+	ListSubscriptions(tenant string) ([]Subscription, error)
+
+	LogDelivery(delivery Delivery) error
+	ListDeliveries(query DeliveryQuery) ([]Delivery, error)
+}
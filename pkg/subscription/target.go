@@ -0,0 +1,99 @@
+package subscription
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// privateNetworks are used by cluster pods and services or are not routable from outside.
+var privateNetworks = func() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8",
+		"10.0.0.0/8",
+		"100.64.0.0/10",
+		"172.16.0.0/12",
+		"192.168.0.0/16",
+		"fc00::/7",
+	} {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}()
+
+// internalDomains are resolved to cluster or local addresses.
+var internalDomains = []string{".local", ".localhost", ".internal", ".svc", ".cluster.local"}
+
+// CheckTargetIP returns error if webhooks must not be sent to ip:
+// loopback, link-local, private and cluster addresses are rejected.
+func CheckTargetIP(ip net.IP) error {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("address %s is not allowed", ip)
+	}
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return fmt.Errorf("address %s is not allowed", ip)
+		}
+	}
+	return nil
+}
+
+// CheckTarget returns error if webhook URL host is internal address or name.
+// Names are checked again by TargetClient after resolution.
+func CheckTarget(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %v", err)
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if ip := net.ParseIP(host); ip != nil {
+		return CheckTargetIP(ip)
+	}
+	if host == "localhost" || !strings.Contains(host, ".") {
+		return fmt.Errorf("host %q is not allowed", host)
+	}
+	for _, domain := range internalDomains {
+		if strings.HasSuffix(host, domain) {
+			return fmt.Errorf("host %q is not allowed", host)
+		}
+	}
+	return nil
+}
+
+// TargetClient returns HTTP client which connects only to addresses allowed by CheckTargetIP.
+// Addresses are checked after name resolution, so names resolved to internal addresses are rejected too.
+// Proxy from environment is not used, because it would be checked instead of target.
+// Redirects are followed by the same transport, so they are checked too.
+func TargetClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil {
+				return fmt.Errorf("address %s is not allowed", host)
+			}
+			return CheckTargetIP(ip)
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+		},
+	}
+}
@@ -0,0 +1,54 @@
+package subscription
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckTarget(t *testing.T) {
+	allowed := []string{
+		"https://hooks.example.com/events",
+		"http://203.0.113.10:8080/hook",
+	}
+	for _, target := range allowed {
+		if err := CheckTarget(target); err != nil {
+			t.Errorf("%s: unexpected error %v", target, err)
+		}
+	}
+
+	rejected := []string{
+		"http://localhost/hook",
+		"http://127.0.0.1:8080/hook",
+		"http://[::1]/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://10.96.0.1/api",
+		"http://172.20.0.5/hook",
+		"http://192.168.1.1/hook",
+		"http://0.0.0.0/hook",
+		"http://[fd00::1]/hook",
+		"http://kube-events/hook",
+		"http://kube-events.default.svc/hook",
+		"http://kube-events.default.svc.cluster.local./hook",
+	}
+	for _, target := range rejected {
+		if err := CheckTarget(target); err == nil {
+			t.Errorf("%s: expected error", target)
+		}
+	}
+}
+
+func TestTargetClientRejectsLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	if _, err := TargetClient(time.Second).Get(server.URL); err == nil {
+		t.Fatal("expected loopback connection to be rejected")
+	}
+	resp, err := (&http.Client{Timeout: time.Second}).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,98 @@
+package transform
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type DropTracerConfig struct {
+	// Size is a number of last traces kept in memory.
+	Size int
+	// LogSample is a period of logging traces with debug level: every LogSample-th trace is logged. Logging is disabled if 0.
+	LogSample int
+}
+
+// DropTraceQuery selects traces. Empty fields match all traces.
+type DropTraceQuery struct {
+	Resource  string
+	Namespace string
+	Name      string
+	Predicate string
+	Since     time.Time
+	// Limit is a maximum number of returned traces. Number is not limited if 0.
+	Limit int
+}
+
+func (q DropTraceQuery) match(trace DropTrace) bool {
+	return (q.Resource == "" || q.Resource == trace.Resource) &&
+		(q.Namespace == "" || q.Namespace == trace.Namespace) &&
+		(q.Name == "" || q.Name == trace.Name) &&
+		(q.Predicate == "" || q.Predicate == trace.Predicate) &&
+		!trace.Time.Before(q.Since)
+}
+
+// DropTracer keeps last traces of dropped events in ring buffer.
+type DropTracer struct {
+	cfg DropTracerConfig
+
+	mu     sync.Mutex
+	ring   []DropTrace
+	next   int
+	traced uint64
+
+	log *log.Entry
+}
+
+func NewDropTracer(cfg DropTracerConfig) *DropTracer {
+	return &DropTracer{
+		cfg:  cfg,
+		ring: make([]DropTrace, 0, cfg.Size),
+		log:  log.WithField("component", "drop_tracer"),
+	}
+}
+
+// Trace adds trace to ring. It can be used as DropFunc.
+func (t *DropTracer) Trace(trace DropTrace) {
+	t.mu.Lock()
+	if len(t.ring) < t.cfg.Size {
+		t.ring = append(t.ring, trace)
+	} else if t.cfg.Size > 0 {
+		t.ring[t.next] = trace
+		t.next = (t.next + 1) % t.cfg.Size
+	}
+	t.traced++
+	sampled := t.cfg.LogSample > 0 && (t.traced-1)%uint64(t.cfg.LogSample) == 0
+	t.mu.Unlock()
+
+	if sampled {
+		t.log.WithFields(log.Fields{
+			"resource":  trace.Resource,
+			"verb":      trace.Verb,
+			"namespace": trace.Namespace,
+			"name":      trace.Name,
+			"reason":    trace.Reason,
+			"predicate": trace.Predicate,
+		}).Debug("Event dropped")
+	}
+}
+
+// Traces returns matching traces, newest first.
+func (t *DropTracer) Traces(query DropTraceQuery) []DropTrace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	traces := make([]DropTrace, 0)
+	for i := len(t.ring) - 1; i >= 0; i-- {
+		// oldest trace is at next index when ring is full
+		trace := t.ring[(t.next+i)%len(t.ring)]
+		if !query.match(trace) {
+			continue
+		}
+		traces = append(traces, trace)
+		if query.Limit > 0 && len(traces) == query.Limit {
+			break
+		}
+	}
+	return traces
+}
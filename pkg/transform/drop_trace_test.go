@@ -0,0 +1,87 @@
+package transform
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func testTraces(tracer *DropTracer, n int) {
+	start := time.Date(2018, 10, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		tracer.Trace(DropTrace{
+			Time:      start.Add(time.Duration(i) * time.Second),
+			Resource:  "Event",
+			Name:      fmt.Sprint(i),
+			Predicate: []string{"even", "odd"}[i%2],
+		})
+	}
+}
+
+func traceNames(traces []DropTrace) string {
+	names := make([]string, len(traces))
+	for i, trace := range traces {
+		names[i] = trace.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func TestDropTracerRing(t *testing.T) {
+	tracer := NewDropTracer(DropTracerConfig{Size: 3})
+	testTraces(tracer, 2)
+	if names := traceNames(tracer.Traces(DropTraceQuery{})); names != "1,0" {
+		t.Errorf("unexpected traces %s", names)
+	}
+	// ring wraps around and keeps newest traces
+	testTraces(tracer, 7)
+	if names := traceNames(tracer.Traces(DropTraceQuery{})); names != "6,5,4" {
+		t.Errorf("unexpected traces %s", names)
+	}
+
+	empty := NewDropTracer(DropTracerConfig{})
+	testTraces(empty, 3)
+	if traces := empty.Traces(DropTraceQuery{}); len(traces) != 0 {
+		t.Errorf("unexpected traces of zero size ring %v", traces)
+	}
+}
+
+func TestDropTraceQuery(t *testing.T) {
+	tracer := NewDropTracer(DropTracerConfig{Size: 10})
+	testTraces(tracer, 10)
+	for _, tc := range []struct {
+		query    DropTraceQuery
+		expected string
+	}{
+		{DropTraceQuery{Limit: 2}, "9,8"},
+		{DropTraceQuery{Predicate: "odd", Limit: 3}, "9,7,5"},
+		{DropTraceQuery{Name: "4"}, "4"},
+		{DropTraceQuery{Resource: "Pod"}, ""},
+		{DropTraceQuery{Since: time.Date(2018, 10, 1, 0, 0, 7, 0, time.UTC)}, "9,8,7"},
+	} {
+		if names := traceNames(tracer.Traces(tc.query)); names != tc.expected {
+			t.Errorf("%+v: expected traces %s, got %s", tc.query, tc.expected, names)
+		}
+	}
+}
+
+func TestDropTracerLogSample(t *testing.T) {
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	level := log.GetLevel()
+	log.SetLevel(log.DebugLevel)
+	defer func() {
+		log.SetOutput(os.Stderr)
+		log.SetLevel(level)
+	}()
+	tracer := NewDropTracer(DropTracerConfig{Size: 10, LogSample: 3})
+	testTraces(tracer, 7)
+	// 1st, 4th and 7th traces are logged
+	if logged := strings.Count(out.String(), "Event dropped"); logged != 3 {
+		t.Errorf("expected 3 logged traces, got %d:\n%s", logged, out.String())
+	}
+}
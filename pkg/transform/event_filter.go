@@ -2,25 +2,74 @@ package transform
 
 import (
 	"sync"
+	"time"
 
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/watch"
 )
 
 type EventFilterPredicate func(event watch.Event) bool
 
+// ExplainedPredicate returns false and reason if event should be dropped.
+type ExplainedPredicate func(event watch.Event) (pass bool, reason string)
+
+// NamedPredicate is a predicate with name reported in drop traces.
+type NamedPredicate struct {
+	Name   string
+	Filter ExplainedPredicate
+}
+
+// Named makes named predicate from predicate which doesn't explain drops.
+func Named(name string, pred EventFilterPredicate) NamedPredicate {
+	return NamedPredicate{
+		Name: name,
+		Filter: func(event watch.Event) (bool, string) {
+			return pred(event), ""
+		},
+	}
+}
+
+// DropTrace describes event dropped by filter.
+type DropTrace struct {
+	Time      time.Time       `json:"time"`
+	Resource  string          `json:"resource"`
+	Verb      watch.EventType `json:"verb"`
+	Namespace string          `json:"namespace,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Reason    string          `json:"reason,omitempty"`
+	Predicate string          `json:"predicate"`
+}
+
+// DropFunc is called for every event dropped by filter.
+type DropFunc func(trace DropTrace)
+
 type FilteredWatch struct {
 	inputWatch watch.Interface
 	resultChan chan watch.Event
 	onceStop   sync.Once
 	stop       chan struct{}
-	predicates []EventFilterPredicate
+	resource   string
+	predicates []NamedPredicate
+	onDrop     DropFunc
 }
 
+// NewFilteredWatch makes filtered watch. Dropped events are not traced, so predicates are not named.
 func NewFilteredWatch(inputWatch watch.Interface, predicates ...EventFilterPredicate) *FilteredWatch {
+	named := make([]NamedPredicate, 0, len(predicates))
+	for _, pred := range predicates {
+		named = append(named, Named("", pred))
+	}
+	return NewTracedFilteredWatch(inputWatch, "", nil, named...)
+}
+
+// NewTracedFilteredWatch makes filtered watch of resource which calls onDrop (if not nil) for every dropped event.
+func NewTracedFilteredWatch(inputWatch watch.Interface, resource string, onDrop DropFunc, predicates ...NamedPredicate) *FilteredWatch {
 	fw := &FilteredWatch{
 		inputWatch: inputWatch,
 		resultChan: make(chan watch.Event),
+		resource:   resource,
 		predicates: predicates,
+		onDrop:     onDrop,
 		stop:       make(chan struct{}),
 	}
 	go fw.readFilter()
@@ -31,7 +80,10 @@ func (fw *FilteredWatch) readFilter() {
 readLoop:
 	for event := range fw.inputWatch.ResultChan() {
 		for _, pred := range fw.predicates {
-			if !pred(event) {
+			if pass, reason := pred.Filter(event); !pass {
+				if fw.onDrop != nil {
+					fw.onDrop(fw.dropTrace(event, pred.Name, reason))
+				}
 				continue readLoop
 			}
 		}
@@ -40,6 +92,20 @@ readLoop:
 	close(fw.resultChan)
 }
 
+func (fw *FilteredWatch) dropTrace(event watch.Event, predicate, reason string) DropTrace {
+	trace := DropTrace{
+		Time:      time.Now().UTC(),
+		Resource:  fw.resource,
+		Verb:      event.Type,
+		Reason:    reason,
+		Predicate: predicate,
+	}
+	if obj, err := meta.Accessor(event.Object); err == nil {
+		trace.Namespace, trace.Name = obj.GetNamespace(), obj.GetName()
+	}
+	return trace
+}
+
 func (fw *FilteredWatch) ResultChan() <-chan watch.Event {
 	return fw.resultChan
 }
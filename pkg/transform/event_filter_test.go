@@ -0,0 +1,51 @@
+package transform
+
+import (
+	"testing"
+
+	apiCore "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestTracedFilteredWatch(t *testing.T) {
+	input := watch.NewFakeWithChanSize(3, false)
+	pod := func(name string) *apiCore.Pod {
+		return &apiCore.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name}}
+	}
+	input.Add(pod("recorded"))
+	input.Delete(pod("deleted"))
+	input.Modify(pod("skipped"))
+	input.Stop()
+
+	var traces []DropTrace
+	fw := NewTracedFilteredWatch(input, "Pod", func(trace DropTrace) {
+		traces = append(traces, trace)
+	},
+		NamedPredicate{Name: "verb", Filter: func(event watch.Event) (bool, string) {
+			return event.Type != watch.Deleted, "deletions are not recorded"
+		}},
+		Named("name", func(event watch.Event) bool {
+			return event.Object.(*apiCore.Pod).Name != "skipped"
+		}),
+	)
+
+	var passed []string
+	for event := range fw.ResultChan() {
+		passed = append(passed, event.Object.(*apiCore.Pod).Name)
+	}
+	if len(passed) != 1 || passed[0] != "recorded" {
+		t.Errorf("unexpected passed events %v", passed)
+	}
+	if len(traces) != 2 {
+		t.Fatalf("expected 2 traces, got %+v", traces)
+	}
+	deleted, skipped := traces[0], traces[1]
+	if deleted.Predicate != "verb" || deleted.Reason != "deletions are not recorded" || deleted.Verb != watch.Deleted ||
+		deleted.Resource != "Pod" || deleted.Namespace != "ns" || deleted.Name != "deleted" {
+		t.Errorf("unexpected trace %+v", deleted)
+	}
+	if skipped.Predicate != "name" || skipped.Reason != "" || skipped.Name != "skipped" {
+		t.Errorf("unexpected trace %+v", skipped)
+	}
+}
@@ -23,7 +23,7 @@ func (et *EventTransformer) Output(input <-chan watch.Event) <-chan kubeClientMo
 			ruleSelector := et.RuleSelector(event)
 			f, ok := et.Rules[ruleSelector]
 			if !ok {
-				log.Warn("Unsupported RuleSelector: %v", ruleSelector)
+				log.Warnf("Unsupported RuleSelector: %v", ruleSelector)
 				continue
 			}
 			outCh <- f(event)
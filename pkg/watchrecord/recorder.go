@@ -0,0 +1,150 @@
+package watchrecord
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// Line is a recorded watch event. Object kind is stored, so object can be decoded to its type on replay.
+type Line struct {
+	Time       time.Time       `json:"time"`
+	Resource   string          `json:"resource"`
+	Type       watch.EventType `json:"type"`
+	APIVersion string          `json:"api_version"`
+	Kind       string          `json:"kind"`
+	Object     json.RawMessage `json:"object"`
+}
+
+// Recorder writes events of watches to NDJSON file.
+type Recorder struct {
+	mu  sync.Mutex
+	w   *bufio.Writer
+	out io.Writer
+	log *log.Entry
+}
+
+func NewRecorder(out io.Writer) *Recorder {
+	return &Recorder{
+		w:   bufio.NewWriter(out),
+		out: out,
+		log: log.WithField("component", "watch_recorder"),
+	}
+}
+
+// Tee returns watch which passes events of input watch and records them with resource name.
+func (r *Recorder) Tee(resource string, input watch.Interface) watch.Interface {
+	tw := &teeWatch{
+		input:      input,
+		resultChan: make(chan watch.Event),
+		stopChan:   make(chan struct{}),
+	}
+	go func() {
+		defer close(tw.resultChan)
+		for {
+			select {
+			case event, ok := <-input.ResultChan():
+				if !ok {
+					return
+				}
+				if err := r.record(resource, event); err != nil {
+					r.log.WithError(err).WithField("resource", resource).Error("Unable to record watch event")
+				}
+				// consumer may stop watch instead of reading
+				select {
+				case tw.resultChan <- event:
+				case <-tw.stopChan:
+					return
+				}
+			case <-tw.stopChan:
+				return
+			}
+		}
+	}()
+	return tw
+}
+
+func (r *Recorder) record(resource string, event watch.Event) error {
+	line := Line{
+		Time:     time.Now().UTC(),
+		Resource: resource,
+		Type:     event.Type,
+	}
+	if event.Object != nil {
+		gvks, _, err := scheme.Scheme.ObjectKinds(event.Object)
+		if err != nil {
+			return err
+		}
+		line.APIVersion, line.Kind = gvks[0].ToAPIVersionAndKind()
+		if line.Object, err = json.Marshal(event.Object); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.w.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	// file is complete even if process is killed
+	return r.w.Flush()
+}
+
+// Close closes output if it implements io.Closer.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.w.Flush(); err != nil {
+		return err
+	}
+	if closer, ok := r.out.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+type teeWatch struct {
+	input      watch.Interface
+	resultChan chan watch.Event
+	stopChan   chan struct{}
+	onceStop   sync.Once
+}
+
+func (tw *teeWatch) ResultChan() <-chan watch.Event {
+	return tw.resultChan
+}
+
+// Stop stops input watch and drops further events.
+func (tw *teeWatch) Stop() {
+	tw.onceStop.Do(func() {
+		close(tw.stopChan)
+	})
+	tw.input.Stop()
+}
+
+// decodeObject decodes recorded object to its type.
+func decodeObject(line Line) (runtime.Object, error) {
+	if line.Kind == "" {
+		return nil, nil
+	}
+	obj, err := scheme.Scheme.New(schema.FromAPIVersionAndKind(line.APIVersion, line.Kind))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(line.Object, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
@@ -0,0 +1,34 @@
+package watchrecord
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	apiCore "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestTeeStopsWithoutReader(t *testing.T) {
+	var out bytes.Buffer
+	recorder := NewRecorder(&out)
+	// proxy watcher does not close result channel on stop
+	input := make(chan watch.Event, 1)
+	tee := recorder.Tee("pods", watch.NewProxyWatcher(input))
+	input <- watch.Event{Type: watch.Added, Object: &apiCore.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod"}}}
+	time.Sleep(10 * time.Millisecond)
+	tee.Stop()
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-tee.ResultChan():
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("tee goroutine is not stopped")
+		}
+	}
+}
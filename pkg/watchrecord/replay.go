@@ -0,0 +1,133 @@
+package watchrecord
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+type recordedEvent struct {
+	time     time.Time
+	resource string
+	event    watch.Event
+}
+
+// Replay emits recorded events to watches of resources in recorded order.
+// Events are emitted with original intervals if realtime is set or as fast as they are consumed otherwise.
+type Replay struct {
+	events   []recordedEvent
+	realtime bool
+
+	watches map[string]*replayWatch
+	done    chan struct{}
+	log     *log.Entry
+}
+
+// NewReplay reads recorded events. Objects are decoded immediately, so invalid recordings are rejected before replay.
+func NewReplay(in io.Reader, realtime bool) (*Replay, error) {
+	r := &Replay{
+		realtime: realtime,
+		watches:  make(map[string]*replayWatch),
+		done:     make(chan struct{}),
+		log:      log.WithField("component", "watch_replay"),
+	}
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, 16<<20)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var line Line
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("invalid recorded event on line %d: %v", lineNum, err)
+		}
+		obj, err := decodeObject(line)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode object on line %d: %v", lineNum, err)
+		}
+		r.events = append(r.events, recordedEvent{
+			time:     line.Time,
+			resource: line.Resource,
+			event:    watch.Event{Type: line.Type, Object: obj},
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Watch returns watch of recorded resource events. Watches must be created before Start.
+// Events of resources without watches are skipped.
+func (r *Replay) Watch(resource string) watch.Interface {
+	w, ok := r.watches[resource]
+	if !ok {
+		w = &replayWatch{
+			resultChan: make(chan watch.Event),
+			stopChan:   make(chan struct{}),
+		}
+		r.watches[resource] = w
+	}
+	return w
+}
+
+// Start starts replay. Result channels of watches are closed when all events are emitted.
+func (r *Replay) Start() {
+	go r.run()
+}
+
+// Done is closed when replay is finished.
+func (r *Replay) Done() <-chan struct{} {
+	return r.done
+}
+
+func (r *Replay) run() {
+	defer close(r.done)
+	defer func() {
+		for _, w := range r.watches {
+			close(w.resultChan)
+		}
+	}()
+
+	r.log.WithField("events", len(r.events)).Info("Replaying watch events")
+	started := time.Now()
+	for _, event := range r.events {
+		w, ok := r.watches[event.resource]
+		if !ok {
+			continue
+		}
+		if r.realtime {
+			if wait := event.time.Sub(r.events[0].time) - time.Since(started); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+		select {
+		case w.resultChan <- event.event:
+		case <-w.stopChan:
+		}
+	}
+	r.log.Info("Watch events replayed")
+}
+
+type replayWatch struct {
+	resultChan chan watch.Event
+	stopChan   chan struct{}
+	onceStop   sync.Once
+}
+
+func (w *replayWatch) ResultChan() <-chan watch.Event {
+	return w.resultChan
+}
+
+// Stop drops further events of watch.
+func (w *replayWatch) Stop() {
+	w.onceStop.Do(func() {
+		close(w.stopChan)
+	})
+}